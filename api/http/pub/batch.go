@@ -0,0 +1,101 @@
+package pub
+
+import (
+	"context"
+	"fmt"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"sync"
+	"time"
+)
+
+type batcher struct {
+	svc        Service
+	sizeMax    int
+	latencyMax time.Duration
+	lock       *sync.Mutex
+	pending    map[batchKey]*batch
+}
+
+type batchKey struct {
+	groupId string
+	userId  string
+}
+
+type batch struct {
+	evts    []*pb.CloudEvent
+	results []chan error
+	timer   *time.Timer
+}
+
+// NewBatcher returns the Service collecting the published events of the same group and user into batches. The batch
+// is sent when it reaches sizeMax events or when its first event waits for latencyMax, whichever comes first.
+// Publish blocks until the event's batch is sent, regardless of the context: the batch is shared with other callers and
+// can't be recalled, so returning earlier would make the caller retry the event that is still going to be published.
+func NewBatcher(svc Service, sizeMax uint32, latencyMax time.Duration) Service {
+	return batcher{
+		svc:        svc,
+		sizeMax:    int(sizeMax),
+		latencyMax: latencyMax,
+		lock:       &sync.Mutex{},
+		pending:    map[batchKey]*batch{},
+	}
+}
+
+func (bt batcher) Publish(ctx context.Context, evt *pb.CloudEvent, groupId, userId string) (err error) {
+	k := batchKey{
+		groupId: groupId,
+		userId:  userId,
+	}
+	result := make(chan error, 1)
+	bt.lock.Lock()
+	b, found := bt.pending[k]
+	if !found {
+		b = &batch{}
+		bt.pending[k] = b
+		b.timer = time.AfterFunc(bt.latencyMax, func() {
+			bt.lock.Lock()
+			current := bt.pending[k] == b
+			if current {
+				delete(bt.pending, k)
+			}
+			bt.lock.Unlock()
+			if current {
+				bt.send(k, b)
+			}
+		})
+	}
+	b.evts = append(b.evts, evt)
+	b.results = append(b.results, result)
+	full := len(b.evts) >= bt.sizeMax
+	if full {
+		delete(bt.pending, k)
+	}
+	bt.lock.Unlock()
+	if full {
+		b.timer.Stop() // when already fired, it finds the batch detached and doesn't send it
+		bt.send(k, b)
+	}
+	err = <-result
+	return
+}
+
+func (bt batcher) PublishBatch(ctx context.Context, evts []*pb.CloudEvent, groupId, userId string) (ackCount uint32, err error) {
+	return bt.svc.PublishBatch(ctx, evts, groupId, userId)
+}
+
+// send publishes the batch and reports the result to every event's publisher. The writer stops at the first event it
+// doesn't accept, so only this event gets the actual error, the events following it are not acknowledged and may be
+// retried.
+func (bt batcher) send(k batchKey, b *batch) {
+	ackCount, err := bt.svc.PublishBatch(context.Background(), b.evts, k.groupId, k.userId)
+	for i, result := range b.results {
+		switch {
+		case uint32(i) < ackCount:
+			result <- nil
+		case uint32(i) == ackCount && err != nil:
+			result <- err
+		default:
+			result <- fmt.Errorf("%w: %s", ErrNoAck, b.evts[i].Id)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package pub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+type batchStub struct {
+	lock     *sync.Mutex
+	batches  [][]string
+	ackCount map[int]uint32
+	err      error
+}
+
+func (bs *batchStub) Publish(ctx context.Context, evt *pb.CloudEvent, groupId, userId string) (err error) {
+	panic("not expected")
+}
+
+func (bs *batchStub) PublishBatch(ctx context.Context, evts []*pb.CloudEvent, groupId, userId string) (ackCount uint32, err error) {
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+	var ids []string
+	for _, evt := range evts {
+		ids = append(ids, userId+"/"+evt.Id)
+	}
+	ackCount = uint32(len(evts))
+	if n, found := bs.ackCount[len(bs.batches)]; found {
+		ackCount = n
+		err = fmt.Errorf("%w: partial", bs.err)
+	}
+	bs.batches = append(bs.batches, ids)
+	return
+}
+
+func TestBatcher_Publish(t *testing.T) {
+	cases := map[string]struct {
+		evts     []string
+		userIds  []string
+		ackCount map[int]uint32
+		err      error
+		batches  int
+		errCount int
+		ackErrs  int
+	}{
+		"full batch": {
+			evts:    []string{"evt0", "evt1", "evt2"},
+			userIds: []string{"user0", "user0", "user0"},
+			batches: 1,
+		},
+		"by latency": {
+			evts:    []string{"evt0"},
+			userIds: []string{"user0"},
+			batches: 1,
+		},
+		"by user": {
+			evts:    []string{"evt0", "evt1", "evt2"},
+			userIds: []string{"user0", "user1", "user0"},
+			batches: 2,
+		},
+		"partial ack": {
+			evts:     []string{"evt0", "evt1", "evt2"},
+			userIds:  []string{"user0", "user0", "user0"},
+			ackCount: map[int]uint32{0: 1},
+			err:      ErrLimitReached,
+			batches:  1,
+			errCount: 1,
+			ackErrs:  1,
+		},
+		"rejected": {
+			evts:     []string{"evt0", "evt1", "evt2"},
+			userIds:  []string{"user0", "user0", "user0"},
+			ackCount: map[int]uint32{0: 0},
+			err:      ErrInvalid,
+			batches:  1,
+			errCount: 1,
+			ackErrs:  2,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			stub := &batchStub{
+				lock:     &sync.Mutex{},
+				ackCount: c.ackCount,
+				err:      c.err,
+			}
+			bt := NewBatcher(stub, 3, 100*time.Millisecond)
+			errs := make(chan error, len(c.evts))
+			wg := &sync.WaitGroup{}
+			for i, id := range c.evts {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					errs <- bt.Publish(context.TODO(), &pb.CloudEvent{Id: id}, "group0", c.userIds[i])
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			var errCount, ackErrs int
+			for err := range errs {
+				switch {
+				case err == nil:
+				case errors.Is(err, ErrNoAck):
+					ackErrs++
+				default:
+					assert.ErrorIs(t, err, c.err)
+					errCount++
+				}
+			}
+			assert.Equal(t, c.errCount, errCount)
+			assert.Equal(t, c.ackErrs, ackErrs)
+			assert.Len(t, stub.batches, c.batches)
+			var total int
+			for _, b := range stub.batches {
+				total += len(b)
+			}
+			assert.Equal(t, len(c.evts), total)
+		})
+	}
+}
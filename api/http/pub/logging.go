@@ -25,3 +25,9 @@ func (l logging) Publish(ctx context.Context, evt *pb.CloudEvent, groupId, userI
 	l.log.Log(ctx, util.LogLevel(err), fmt.Sprintf("pub.Publish(%s, %s, %s): err=%s", evt.Id, groupId, userId, err))
 	return
 }
+
+func (l logging) PublishBatch(ctx context.Context, evts []*pb.CloudEvent, groupId, userId string) (ackCount uint32, err error) {
+	ackCount, err = l.svc.PublishBatch(ctx, evts, groupId, userId)
+	l.log.Log(ctx, util.LogLevel(err), fmt.Sprintf("pub.PublishBatch(%d, %s, %s): %d, err=%s", len(evts), groupId, userId, ackCount, err))
+	return
+}
@@ -19,3 +19,11 @@ func (m mock) Publish(ctx context.Context, evt *pb.CloudEvent, groupId, userId s
 	m.chEvt <- evt
 	return
 }
+
+func (m mock) PublishBatch(ctx context.Context, evts []*pb.CloudEvent, groupId, userId string) (ackCount uint32, err error) {
+	for _, evt := range evts {
+		m.chEvt <- evt
+		ackCount++
+	}
+	return
+}
@@ -15,6 +15,11 @@ import (
 
 type Service interface {
 	Publish(ctx context.Context, evt *pb.CloudEvent, groupId, userId string) (err error)
+
+	// PublishBatch publishes the events in a single request. The writer acknowledges the events in order, so the
+	// ackCount first events are accepted even when the error is returned. The error relates to the event following
+	// these, the rest of the events are not processed.
+	PublishBatch(ctx context.Context, evts []*pb.CloudEvent, groupId, userId string) (ackCount uint32, err error)
 }
 
 type service struct {
@@ -43,14 +48,47 @@ func NewService(clientHttp *http.Client, url, token string) Service {
 }
 
 func (svc service) Publish(ctx context.Context, evt *pb.CloudEvent, groupId, userId string) (err error) {
-
 	var reqData []byte
 	reqData, err = protojson.Marshal(evt)
-
-	var req *http.Request
+	var ackCount uint32
 	if err == nil {
-		req, err = http.NewRequestWithContext(ctx, http.MethodPost, svc.url, bytes.NewReader(reqData))
+		ackCount, err = svc.post(ctx, reqData, evt.Id, groupId, userId)
+	}
+	if err == nil && ackCount < 1 {
+		err = fmt.Errorf("%w: %s", ErrNoAck, evt.Id)
+	}
+	return
+}
+
+func (svc service) PublishBatch(ctx context.Context, evts []*pb.CloudEvent, groupId, userId string) (ackCount uint32, err error) {
+	if len(evts) == 0 {
+		return
 	}
+	reqData := []byte{'['}
+	for i, evt := range evts {
+		var evtData []byte
+		evtData, err = protojson.Marshal(evt)
+		if err != nil {
+			return
+		}
+		if i > 0 {
+			reqData = append(reqData, ',')
+		}
+		reqData = append(reqData, evtData...)
+	}
+	reqData = append(reqData, ']')
+	batchId := fmt.Sprintf("%s+%d", evts[0].Id, len(evts)-1)
+	ackCount, err = svc.post(ctx, reqData, batchId, groupId, userId)
+	if err == nil && ackCount < uint32(len(evts)) {
+		err = fmt.Errorf("%w: %s, acknowledged %d", ErrNoAck, batchId, ackCount)
+	}
+	return
+}
+
+func (svc service) post(ctx context.Context, reqData []byte, id, groupId, userId string) (ackCount uint32, err error) {
+
+	var req *http.Request
+	req, err = http.NewRequestWithContext(ctx, http.MethodPost, svc.url, bytes.NewReader(reqData))
 
 	var resp *http.Response
 	if err == nil {
@@ -62,34 +100,32 @@ func (svc service) Publish(ctx context.Context, evt *pb.CloudEvent, groupId, use
 		resp, err = svc.clientHttp.Do(req)
 	}
 
+	var respData []byte
+	if err == nil {
+		defer resp.Body.Close()
+		respData, err = io.ReadAll(resp.Body)
+	}
+
+	var p payloadResp
 	if err == nil {
 		switch resp.StatusCode {
+		case http.StatusOK:
+			err = sonic.Unmarshal(respData, &p)
 		case http.StatusServiceUnavailable:
-			err = fmt.Errorf("%w: %s", ErrNoAck, evt.Id)
+			err = fmt.Errorf("%w: %s", ErrNoAck, id)
 		case http.StatusUnauthorized:
 			err = ErrNoAuth
 		case http.StatusRequestTimeout:
-			err = fmt.Errorf("%w: %s", ErrNoAck, evt.Id)
+			err = fmt.Errorf("%w: %s", ErrNoAck, id)
 		case http.StatusBadRequest:
-			err = fmt.Errorf("%w: %s", ErrInvalid, evt.Id)
+			err = fmt.Errorf("%w: %s", ErrInvalid, id)
 		case http.StatusTooManyRequests:
-			err = fmt.Errorf("%w: %s", ErrLimitReached, evt.Id)
+			err = fmt.Errorf("%w: %s", ErrLimitReached, id)
 		}
-	}
-
-	var respData []byte
-	if err == nil {
-		defer resp.Body.Close()
-		respData, err = io.ReadAll(resp.Body)
-	}
-
-	var p payloadResp
-	if err == nil {
-		err = sonic.Unmarshal(respData, &p)
-	}
-
-	if err == nil && p.AckCount < 1 {
-		err = fmt.Errorf("%w: %s", ErrNoAck, evt.Id)
+		if err != nil && resp.StatusCode != http.StatusOK {
+			_ = sonic.Unmarshal(respData, &p) // the batch may be partially accepted before the failure
+		}
+		ackCount = p.AckCount
 	}
 
 	return
@@ -0,0 +1,74 @@
+package subscriptions
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+type mock struct {
+	urls map[string]map[string][]string
+}
+
+// NewMock returns the stub keeping the callback urls by user id and interest id in the given map.
+func NewMock(urls map[string]map[string][]string) Service {
+	return mock{
+		urls: urls,
+	}
+}
+
+func (m mock) Subscribe(ctx context.Context, interestId, groupId, userId, url string, interval time.Duration) (err error) {
+	if slices.Contains(m.urls[userId][interestId], url) {
+		err = ErrConflict
+		return
+	}
+	if m.urls[userId] == nil {
+		m.urls[userId] = map[string][]string{}
+	}
+	m.urls[userId][interestId] = append(m.urls[userId][interestId], url)
+	return
+}
+
+func (m mock) Subscription(ctx context.Context, interestId, groupId, userId, url string) (cb Subscription, err error) {
+	switch slices.Contains(m.urls[userId][interestId], url) {
+	case true:
+		cb.Url = url
+		cb.Format = FmtJson
+	default:
+		err = ErrNotFound
+	}
+	return
+}
+
+func (m mock) Unsubscribe(ctx context.Context, interestId, groupId, userId, url string) (err error) {
+	urls := m.urls[userId][interestId]
+	i := slices.Index(urls, url)
+	switch i {
+	case -1:
+		err = ErrNotFound
+	default:
+		m.urls[userId][interestId] = slices.Delete(urls, i, i+1)
+	}
+	return
+}
+
+func (m mock) InterestsByUrl(ctx context.Context, groupId, userId string, limit uint32, url, cursor string) (page []string, err error) {
+	for interestId, urls := range m.urls[userId] {
+		if interestId <= cursor {
+			continue
+		}
+		for _, u := range urls {
+			if strings.HasPrefix(u, url) {
+				page = append(page, interestId)
+				break
+			}
+		}
+	}
+	sort.Strings(page)
+	if uint32(len(page)) > limit {
+		page = page[:limit]
+	}
+	return
+}
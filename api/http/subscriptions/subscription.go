@@ -1,8 +1,11 @@
 package subscriptions
 
 import (
+	"errors"
+	"fmt"
 	"net/url"
 	"strconv"
+	"strings"
 )
 
 type Subscription struct {
@@ -10,10 +13,55 @@ type Subscription struct {
 	Format string `json:"fmt"`
 }
 
+const KeyUserId = "userId"
+const KeyThreadId = "threadId"
+
+var ErrCallbackUrl = errors.New("invalid callback url")
+
 func MakeCallbackUrl(urlBase string, chatId int64, userId string) (u string) {
 	u = urlBase + "/" + strconv.FormatInt(chatId, 10)
 	if userId != "" {
-		u += "?userId=" + url.QueryEscape(userId)
+		u += "?" + KeyUserId + "=" + url.QueryEscape(userId)
+	}
+	return
+}
+
+// MakeTopicCallbackUrl is the same as MakeCallbackUrl but binds the callback to the forum topic (message thread).
+func MakeTopicCallbackUrl(urlBase string, chatId int64, threadId int, userId string) (u string) {
+	u = MakeCallbackUrl(urlBase, chatId, userId)
+	if threadId != 0 {
+		switch userId {
+		case "":
+			u += "?"
+		default:
+			u += "&"
+		}
+		u += KeyThreadId + "=" + strconv.Itoa(threadId)
+	}
+	return
+}
+
+// ParseCallbackUrl is the reverse of MakeTopicCallbackUrl.
+func ParseCallbackUrl(urlBase, u string) (chatId int64, threadId int, userId string, err error) {
+	tail, found := strings.CutPrefix(u, urlBase+"/")
+	if !found {
+		err = fmt.Errorf("%w: %s", ErrCallbackUrl, u)
+		return
+	}
+	chatIdRaw, query, _ := strings.Cut(tail, "?")
+	chatId, err = strconv.ParseInt(chatIdRaw, 10, 64)
+	var params url.Values
+	if err == nil {
+		params, err = url.ParseQuery(query)
+	}
+	if err == nil {
+		userId = params.Get(KeyUserId)
+		if threadIdRaw := params.Get(KeyThreadId); threadIdRaw != "" {
+			threadId, err = strconv.Atoi(threadIdRaw)
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %s, %s", ErrCallbackUrl, u, err)
 	}
 	return
 }
@@ -0,0 +1,88 @@
+package subscriptions
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMakeTopicCallbackUrl(t *testing.T) {
+	cases := map[string]struct {
+		chatId   int64
+		threadId int
+		userId   string
+		out      string
+	}{
+		"legacy": {
+			chatId: -1001,
+			out:    "http://bot-telegram:8081/v1/chat/-1001",
+		},
+		"user": {
+			chatId: -1001,
+			userId: "tg://user?id=123",
+			out:    "http://bot-telegram:8081/v1/chat/-1001?userId=tg%3A%2F%2Fuser%3Fid%3D123",
+		},
+		"topic": {
+			chatId:   -1001,
+			threadId: 42,
+			userId:   "tg://user?id=123",
+			out:      "http://bot-telegram:8081/v1/chat/-1001?userId=tg%3A%2F%2Fuser%3Fid%3D123&threadId=42",
+		},
+		"topic w/o user": {
+			chatId:   -1001,
+			threadId: 42,
+			out:      "http://bot-telegram:8081/v1/chat/-1001?threadId=42",
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			out := MakeTopicCallbackUrl("http://bot-telegram:8081/v1/chat", c.chatId, c.threadId, c.userId)
+			assert.Equal(t, c.out, out)
+		})
+	}
+}
+
+func TestParseCallbackUrl(t *testing.T) {
+	cases := map[string]struct {
+		chatId   int64
+		threadId int
+		userId   string
+		err      error
+	}{
+		"http://bot-telegram:8081/v1/chat/-1001": {
+			chatId: -1001,
+		},
+		"http://bot-telegram:8081/v1/chat/-1001?userId=tg%3A%2F%2Fuser%3Fid%3D123": {
+			chatId: -1001,
+			userId: "tg://user?id=123",
+		},
+		"http://bot-telegram:8081/v1/chat/-1001?userId=tg%3A%2F%2Fuser%3Fid%3D123&threadId=42": {
+			chatId:   -1001,
+			threadId: 42,
+			userId:   "tg://user?id=123",
+		},
+		"http://bot-telegram:8081/v1/chat/-1001?threadId=42": {
+			chatId:   -1001,
+			threadId: 42,
+		},
+		"http://bot-telegram:8081/v1/chat/abc": {
+			err: ErrCallbackUrl,
+		},
+		"http://bot-telegram:8081/v1/chat/-1001?threadId=abc": {
+			err: ErrCallbackUrl,
+		},
+		"https://example.com/-1001": {
+			err: ErrCallbackUrl,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			chatId, threadId, userId, err := ParseCallbackUrl("http://bot-telegram:8081/v1/chat", k)
+			assert.ErrorIs(t, err, c.err)
+			if c.err == nil {
+				assert.Equal(t, c.chatId, chatId)
+				assert.Equal(t, c.threadId, threadId)
+				assert.Equal(t, c.userId, userId)
+			}
+		})
+	}
+}
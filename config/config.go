@@ -1,6 +1,7 @@
 package config
 
 import (
+	"github.com/awakari/bot-telegram/model/usage"
 	"github.com/kelseyhightower/envconfig"
 	"time"
 )
@@ -20,14 +21,22 @@ type Config struct {
 				ConnMax uint32 `envconfig:"API_TELEGRAM_WEBHOOK_CONN_MAX" default:"100"`
 				Token   string `envconfig:"API_TELEGRAM_WEBHOOK_TOKEN" default:"xxxxxxxxxx"`
 			}
-			SupportChatId               int64  `envconfig:"API_TELEGRAM_SUPPORT_CHAT_ID" required:"true"`
-			Token                       string `envconfig:"API_TELEGRAM_TOKEN" required:"true"`
-			PublicInterestChannelPrefix string `envconfig:"API_TELEGRAM_PUBLIC_INTEREST_CHANNEL_PREFIX" default:"awk_" required:"true"`
+			SupportChatId               int64   `envconfig:"API_TELEGRAM_SUPPORT_CHAT_ID" required:"true"`
+			AdminIds                    []int64 `envconfig:"API_TELEGRAM_ADMIN_IDS"`
+			Token                       string  `envconfig:"API_TELEGRAM_TOKEN" required:"true"`
+			PublicInterestChannelPrefix string  `envconfig:"API_TELEGRAM_PUBLIC_INTEREST_CHANNEL_PREFIX" default:"awk_" required:"true"`
 		}
 		Subscriptions SubscriptionsConfig
 		Queue         QueueConfig
 		Writer        struct {
-			Uri string `envconfig:"API_WRITER_URI" default:"http://pub:8080/v1/batch" required:"true"`
+			Uri   string `envconfig:"API_WRITER_URI" default:"http://pub:8080/v1/batch" required:"true"`
+			Batch struct {
+				// Size is the max count of the channel posts published in a single request.
+				Size uint32 `envconfig:"API_WRITER_BATCH_SIZE" default:"16" required:"true"`
+				// Latency is the max time the channel post waits for the batch to fill up.
+				Latency time.Duration `envconfig:"API_WRITER_BATCH_LATENCY" default:"100ms" required:"true"`
+			}
+			Outbox OutboxConfig
 		}
 		Interests struct {
 			Uri string `envconfig:"API_INTERESTS_URI" default:"http://interests-api:8080/v1" required:"true"`
@@ -44,13 +53,30 @@ type Config struct {
 				}
 				IdleTimeout time.Duration `envconfig:"API_USAGE_CONN_IDLE_TIMEOUT" default:"15m" required:"true"`
 			}
+			Tiers usage.Tiers `envconfig:"API_USAGE_TIERS" default:"[]"`
+			// Limits are the paid channel chat ids to the limits provided, e.g. "-1002672306001:10". Deprecated: kept
+			// for the existing deployments, the chat ids of the Tiers take precedence.
 			Limits struct {
-				Interests       map[int64]int64 `envconfig:"API_USAGE_LIMITS_INTERESTS" required:"true"`
-				InterestsPublic map[int64]int64 `envconfig:"API_USAGE_LIMITS_INTERESTS_PUBLIC" required:"true"`
-				Subscriptions   map[int64]int64 `envconfig:"API_USAGE_LIMITS_SUBSCRIPTIONS" required:"true"`
+				Interests       map[int64]int64 `envconfig:"API_USAGE_LIMITS_INTERESTS"`
+				InterestsPublic map[int64]int64 `envconfig:"API_USAGE_LIMITS_INTERESTS_PUBLIC"`
+				Subscriptions   map[int64]int64 `envconfig:"API_USAGE_LIMITS_SUBSCRIPTIONS"`
+			}
+			Reconcile struct {
+				Interval time.Duration `envconfig:"API_USAGE_RECONCILE_INTERVAL" default:"6h" required:"true"`
+				DryRun   bool          `envconfig:"API_USAGE_RECONCILE_DRY_RUN" default:"false" required:"true"`
+			}
+			Expiry struct {
+				Interval time.Duration `envconfig:"API_USAGE_EXPIRY_INTERVAL" default:"1h" required:"true"`
+				Ahead    time.Duration `envconfig:"API_USAGE_EXPIRY_AHEAD" default:"72h" required:"true"`
 			}
 		}
 	}
+	Storage struct {
+		Path  string `envconfig:"STORAGE_PATH" default:"/var/lib/bot-telegram" required:"true"`
+		Lease struct {
+			Ttl time.Duration `envconfig:"STORAGE_LEASE_TTL" default:"1m" required:"true"`
+		}
+	}
 	Log struct {
 		Level int `envconfig:"LOG_LEVEL" default:"-4" required:"true"`
 	}
@@ -76,12 +102,40 @@ type QueueConfig struct {
 	}
 }
 
+type OutboxConfig struct {
+	// Interval is the period of checking the pending events due for the next publishing attempt.
+	Interval time.Duration `envconfig:"API_WRITER_OUTBOX_INTERVAL" default:"5s" required:"true"`
+	Delay    struct {
+		Min time.Duration `envconfig:"API_WRITER_OUTBOX_DELAY_MIN" default:"10s" required:"true"`
+		Max time.Duration `envconfig:"API_WRITER_OUTBOX_DELAY_MAX" default:"10m" required:"true"`
+		// Limit is the delay before the next attempt when the publishing limit is reached.
+		Limit time.Duration `envconfig:"API_WRITER_OUTBOX_DELAY_LIMIT" default:"1h" required:"true"`
+	}
+	// AgeMax is the time after which the pending event is dropped.
+	AgeMax time.Duration `envconfig:"API_WRITER_OUTBOX_AGE_MAX" default:"24h" required:"true"`
+}
+
 type MessagesConfig struct {
 	Type    string `envconfig:"API_MESSAGES_TYPE" default:"com_awakari_bot_telegram_v1" required:"true"`
 	UriBase string `envconfig:"API_MESSAGES_URI_BASE" default:"https://awakari.com/pub-msg.html?id=" required:"true"`
+	// TagsStrip enables removing the hashtags and cashtags from the published text, these are kept as categories.
+	TagsStrip bool `envconfig:"API_MESSAGES_TAGS_STRIP" default:"false"`
+	Schedule  struct {
+		// Interval is the period of checking the scheduled messages due to publish.
+		Interval time.Duration `envconfig:"API_MESSAGES_SCHEDULE_INTERVAL" default:"1m" required:"true"`
+	}
+	Unfurl struct {
+		// Enabled turns on fetching the first link of the published message to fill the title and summary.
+		Enabled bool          `envconfig:"API_MESSAGES_UNFURL_ENABLED" default:"false"`
+		Timeout time.Duration `envconfig:"API_MESSAGES_UNFURL_TIMEOUT" default:"3s"`
+		SizeMax int64         `envconfig:"API_MESSAGES_UNFURL_SIZE_MAX" default:"1048576"`
+	}
 }
 
 func NewConfigFromEnv() (cfg Config, err error) {
 	err = envconfig.Process("", &cfg)
+	if err == nil && len(cfg.Api.Usage.Tiers) == 0 {
+		cfg.Api.Usage.Tiers = usage.TiersDefault
+	}
 	return
 }
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"github.com/awakari/bot-telegram/model/usage"
 	"github.com/stretchr/testify/assert"
 	"os"
 	"testing"
@@ -15,15 +16,59 @@ func TestConfig(t *testing.T) {
 	os.Setenv("REPLICA_RANGE", "2")
 	os.Setenv("REPLICA_NAME", "replica-0")
 	os.Setenv("API_TOKEN_INTERNAL", "foo")
-	os.Setenv("API_USAGE_LIMITS_INTERESTS", "")
-	os.Setenv("API_USAGE_LIMITS_INTERESTS_PUBLIC", "")
-	os.Setenv("API_USAGE_LIMITS_SUBSCRIPTIONS", "-1002672306001:10")
+	os.Setenv("API_USAGE_TIERS", `[{"subject":"subscriptions","limit":10,"price":"€2/month","url":"https://t.me/tribute/app?startapp=sv5Q","chatId":-1002672306001}]`)
 	cfg, err := NewConfigFromEnv()
 	assert.Nil(t, err)
 	assert.Equal(t, uint16(56789), cfg.Api.Telegram.Webhook.Port)
 	assert.Equal(t, uint16(45678), cfg.Api.Telegram.Bot.Port)
 	assert.Equal(t, 4, cfg.Log.Level)
-	assert.Equal(t, cfg.Api.Usage.Limits.Subscriptions, map[int64]int64{
-		-1002672306001: 10,
-	})
+	assert.Equal(t, usage.Tiers{
+		{
+			Subject: usage.SubjectSubscriptions,
+			Limit:   10,
+			Price:   "€2/month",
+			Url:     "https://t.me/tribute/app?startapp=sv5Q",
+			ChatId:  -1002672306001,
+		},
+	}, cfg.Api.Usage.Tiers)
+}
+
+func TestConfig_Tiers(t *testing.T) {
+	t.Setenv("API_TELEGRAM_SUPPORT_CHAT_ID", "12345")
+	t.Setenv("API_TELEGRAM_TOKEN", "yohoho")
+	t.Setenv("API_TOKEN_INTERNAL", "foo")
+	cases := map[string]struct {
+		tiers  string
+		limits string
+		out    usage.Tiers
+		legacy map[int64]int64
+		valid  bool
+	}{
+		"default": {
+			tiers:  `[]`,
+			limits: "-1002672306001:10",
+			out:    usage.TiersDefault,
+			legacy: map[int64]int64{
+				-1002672306001: 10,
+			},
+			valid: true,
+		},
+		"chat id only": {
+			tiers: `[{"subject":"subscriptions","limit":10,"chatId":-1002672306001}]`,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			t.Setenv("API_USAGE_TIERS", c.tiers)
+			t.Setenv("API_USAGE_LIMITS_SUBSCRIPTIONS", c.limits)
+			cfg, err := NewConfigFromEnv()
+			if c.valid {
+				assert.Nil(t, err)
+				assert.Equal(t, c.out, cfg.Api.Usage.Tiers)
+				assert.Equal(t, c.legacy, cfg.Api.Usage.Limits.Subscriptions)
+			} else {
+				assert.NotNil(t, err)
+			}
+		})
+	}
 }
@@ -12,12 +12,18 @@ import (
 	"github.com/awakari/bot-telegram/api/http/pub"
 	apiHttpSubs "github.com/awakari/bot-telegram/api/http/subscriptions"
 	"github.com/awakari/bot-telegram/config"
+	"github.com/awakari/bot-telegram/model/usage"
 	"github.com/awakari/bot-telegram/service"
+	"github.com/awakari/bot-telegram/service/admin"
 	"github.com/awakari/bot-telegram/service/chats"
 	"github.com/awakari/bot-telegram/service/limits"
 	"github.com/awakari/bot-telegram/service/messages"
+	"github.com/awakari/bot-telegram/service/outbox"
+	"github.com/awakari/bot-telegram/service/payments"
+	"github.com/awakari/bot-telegram/service/storage"
 	"github.com/awakari/bot-telegram/service/subscriptions"
 	"github.com/awakari/bot-telegram/service/support"
+	"github.com/awakari/bot-telegram/service/unfurl"
 	"github.com/awakari/bot-telegram/util"
 	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
 	"github.com/gin-gonic/gin"
@@ -29,6 +35,7 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -55,6 +62,12 @@ func main() {
 	svcInterests = interests.NewLogging(svcInterests, log)
 	log.Info("initialized the Awakari interests API client")
 
+	var svcUnfurl unfurl.Service
+	if cfg.Api.Messages.Unfurl.Enabled {
+		svcUnfurl = unfurl.NewService(unfurl.NewClientHttp(cfg.Api.Messages.Unfurl.Timeout), cfg.Api.Messages.Unfurl.SizeMax)
+		svcUnfurl = unfurl.NewLogging(svcUnfurl, log)
+	}
+
 	// init websub
 	clientHttp := http.Client{}
 	svcSubs := apiHttpSubs.NewService(&clientHttp, cfg.Api.Subscriptions.Uri, cfg.Api.Token.Internal)
@@ -66,6 +79,14 @@ func main() {
 		cfg.Api.Subscriptions.CallBack.Port,
 		cfg.Api.Subscriptions.CallBack.Path,
 	)
+	storageChatSubscribers, err := storage.NewFile[service.ChatSubscriber](filepath.Join(cfg.Storage.Path, "chat-subscribers.json"))
+	if err != nil {
+		panic(err)
+	}
+	chatSubscribers := service.ChatSubscribers{
+		Storage: storageChatSubscribers,
+	}
+	svcSubs = service.TrackSubscribers(svcSubs, chatSubscribers, urlCallbackBase)
 
 	// init queues
 	connQueue, err := grpc.NewClient(cfg.Api.Queue.Uri, grpc.WithTransportCredentials(insecure.NewCredentials()))
@@ -136,46 +157,182 @@ func main() {
 		UriEvtBase: cfg.Api.Messages.UriBase,
 	}
 
+	// init storage
+	storageChanSettings, err := storage.NewFile[messages.ChannelSettings](filepath.Join(cfg.Storage.Path, "channel-settings.json"))
+	if err != nil {
+		panic(err)
+	}
+	storageOutbox, err := storage.NewFile[outbox.Entry](filepath.Join(cfg.Storage.Path, "outbox.json"))
+	if err != nil {
+		panic(err)
+	}
+	storageSchedule, err := storage.NewFile[messages.Scheduled](filepath.Join(cfg.Storage.Path, "scheduled-messages.json"))
+	if err != nil {
+		panic(err)
+	}
+	storagePurchases, err := storage.NewFile[payments.Purchase](filepath.Join(cfg.Storage.Path, "purchases.json"))
+	if err != nil {
+		panic(err)
+	}
+	storagePaidMembers, err := storage.NewFile[service.PaidMember](filepath.Join(cfg.Storage.Path, "paid-members.json"))
+	if err != nil {
+		panic(err)
+	}
+	storageLimitRecords, err := storage.NewFile[limits.Record](filepath.Join(cfg.Storage.Path, "limit-records.json"))
+	if err != nil {
+		panic(err)
+	}
+	storageLimitReminded, err := storage.NewFile[time.Time](filepath.Join(cfg.Storage.Path, "limit-reminders.json"))
+	if err != nil {
+		panic(err)
+	}
+	storagePublishCounts, err := storage.NewFile[service.PublishCount](filepath.Join(cfg.Storage.Path, "publish-counts.json"))
+	if err != nil {
+		panic(err)
+	}
+	svcPubUser := service.CountPublished(svcPub, storagePublishCounts)
+	storageLeases, err := storage.NewFile[storage.LeaseHolder](filepath.Join(cfg.Storage.Path, "leases.json"))
+	if err != nil {
+		panic(err)
+	}
+	storageSupportTickets, err := storage.NewFile[support.Ticket](filepath.Join(cfg.Storage.Path, "support-tickets.json"))
+	if err != nil {
+		panic(err)
+	}
+	storageSupportMessages, err := storage.NewFile[string](filepath.Join(cfg.Storage.Path, "support-messages.json"))
+	if err != nil {
+		panic(err)
+	}
+	svcPubBatch := pub.NewBatcher(svcPub, cfg.Api.Writer.Batch.Size, cfg.Api.Writer.Batch.Latency)
+	obChanPosts := outbox.NewOutbox(svcPubBatch, storageOutbox, cfg.Api.Writer.Outbox, log)
+
 	// init handlers
 	groupId := cfg.Api.GroupId
+	limitReached := service.LimitReached{
+		SvcLimits: svcLimits,
+		Tiers:     cfg.Api.Usage.Tiers,
+		GroupId:   groupId,
+		Published: storagePublishCounts,
+	}
+	hUsage := service.Usage{
+		SvcLimits:       svcLimits,
+		SvcInterests:    svcInterests,
+		SvcSubs:         svcSubs,
+		Published:       storagePublishCounts,
+		UrlCallbackBase: urlCallbackBase,
+		GroupId:         groupId,
+	}
+	hAdmin := admin.Handler{
+		AdminIds:        cfg.Api.Telegram.AdminIds,
+		SvcLimits:       limits.NewRecorded(svcLimits, storageLimitRecords, limits.SourceAdmin),
+		SvcInterests:    svcInterests,
+		SvcSubs:         svcSubs,
+		ChatSubscribers: chatSubscribers,
+		UrlCallbackBase: urlCallbackBase,
+		GroupId:         groupId,
+		Stats: []admin.Stat{
+			{
+				Name:  "Outbox depth",
+				Value: obChanPosts.Depth,
+			},
+			{
+				Name: "Scheduled messages",
+				Value: func() int {
+					return storage.Count(storageSchedule)
+				},
+			},
+			{
+				Name: "Paid channel members",
+				Value: func() int {
+					return storage.Count(storagePaidMembers)
+				},
+			},
+			{
+				Name: "Purchases",
+				Value: func() int {
+					return storage.Count(storagePurchases)
+				},
+			},
+		},
+		Started: time.Now(),
+	}
+	hPayments := payments.Handler{
+		SvcLimits:     limits.NewRecorded(svcLimits, storageLimitRecords, limits.SourcePurchase),
+		Tiers:         cfg.Api.Usage.Tiers,
+		GroupId:       groupId,
+		Purchases:     storagePurchases,
+		SupportChatId: cfg.Api.Telegram.SupportChatId,
+		Log:           log,
+	}
 	supportHandler := support.Handler{
 		SupportChatId: cfg.Api.Telegram.SupportChatId,
+		Tickets:       storageSupportTickets,
+		Messages:      storageSupportMessages,
+		Log:           log,
 	}
 	chanPostHandler := messages.ChanPostHandler{
-		SvcPub:    svcPub,
+		SvcPub:    obChanPosts,
 		GroupId:   groupId,
 		Log:       log,
 		Channels:  map[string]time.Time{},
 		ChansLock: &sync.Mutex{},
 		CfgMsgs:   cfg.Api.Messages,
+		Settings:  storageChanSettings,
+		Unfurl:    svcUnfurl,
 	}
 
-	handlerSubscribe := subscriptions.StartHandler(svcInterests, svcSubs, svcLimits, urlCallbackBase, groupId)
+	handlerSubscribe := subscriptions.StartHandler(svcInterests, svcSubs, limitReached, urlCallbackBase, groupId)
+	handlerChanSubscribe := subscriptions.ChannelStartHandler(svcInterests, svcSubs, limitReached, groupId, urlCallbackBase)
 
 	callbackHandlers := map[string]service.ArgHandlerFunc{
 		subscriptions.CmdStart:             handlerSubscribe,
 		subscriptions.CmdStop:              subscriptions.Stop(svcSubs, urlCallbackBase, cfg.Api.GroupId),
 		subscriptions.CmdPageNext:          subscriptions.PageNext(svcInterests, svcSubs, groupId, urlCallbackBase),
 		subscriptions.CmdPageNextFollowing: subscriptions.PageNextFollowing(svcInterests, svcSubs, groupId, urlCallbackBase),
+		subscriptions.CmdChanSub:           handlerChanSubscribe,
+		subscriptions.CmdChanStop:          subscriptions.ChannelStop(svcSubs, groupId, urlCallbackBase),
+		subscriptions.CmdChanPageNext:      subscriptions.ChannelPageNext(svcInterests, svcSubs, groupId, urlCallbackBase),
+		subscriptions.CmdStopAll:           subscriptions.StopAll(svcSubs, chatSubscribers, urlCallbackBase, groupId),
+		subscriptions.CmdCopyAll:           subscriptions.CopyAllRequest,
+		subscriptions.CmdExport:            subscriptions.Export(svcInterests, svcSubs, chatSubscribers, urlCallbackBase, groupId),
+		subscriptions.CmdImport:            subscriptions.ImportRequest,
+		subscriptions.CmdFindNext:          subscriptions.FindPageNext(svcInterests, svcSubs, groupId, urlCallbackBase),
+		messages.CmdChanSettings:           messages.ChannelSettingsToggle(storageChanSettings),
+		messages.CmdPubSchedule:            messages.PublishSchedule,
+		messages.CmdPubCancel:              messages.ScheduledCancel(storageSchedule),
+		subscriptions.CmdFwdSub:            subscriptions.ForwardSubscribe(svcInterests, svcSubs, limitReached, urlCallbackBase, groupId),
+		service.CmdLimitBuy:                hPayments.Buy,
 	}
 	replyHandlers := map[string]service.ArgHandlerFunc{
-		subscriptions.ReqSubCreate: subscriptions.CreateBasicReplyHandlerFunc(svcInterests, groupId),
-		subscriptions.ReqStart:     handlerSubscribe,
-		messages.ReqMsgPub:         messages.PublishBasicReplyHandlerFunc(svcPub, groupId, cfg),
-		"support":                  supportHandler.Request,
+		subscriptions.ReqSubCreate:       subscriptions.CreateBasicReplyHandlerFunc(svcInterests, limitReached, groupId),
+		subscriptions.ReqStart:           handlerSubscribe,
+		subscriptions.ReqChanLink:        subscriptions.ChannelLinkReplyHandlerFunc(svcInterests, svcSubs, groupId, urlCallbackBase),
+		subscriptions.ReqChanSub:         handlerChanSubscribe,
+		subscriptions.ReqCopyAll:         subscriptions.CopyAllReplyHandlerFunc(svcSubs, chatSubscribers, limitReached, urlCallbackBase, groupId),
+		subscriptions.ReqImport:          subscriptions.ImportReplyHandlerFunc(svcSubs, limitReached, urlCallbackBase, groupId),
+		subscriptions.ReqInterestsImport: subscriptions.ImportInterestsReplyHandlerFunc(svcInterests, limitReached, groupId),
+		subscriptions.ReqFind:            subscriptions.FindReplyHandlerFunc(svcInterests, svcSubs, groupId, urlCallbackBase),
+		subscriptions.ReqFollow:          subscriptions.FollowReplyHandlerFunc(svcInterests, svcSubs, limitReached, urlCallbackBase, groupId),
+		messages.ReqMsgPub:               messages.PublishBasicReplyHandlerFunc(svcPubUser, limitReached, svcUnfurl, groupId, cfg),
+		messages.ReqMsgPubTime:           messages.PublishTimeReplyHandlerFunc,
+		messages.ReqMsgPubAt:             messages.PublishScheduledReplyHandlerFunc(storageSchedule, svcUnfurl, groupId, cfg),
+		"support":                        supportHandler.Request,
 	}
 	txtHandlers := map[string]telebot.HandlerFunc{}
 	hRoot := service.RootHandler{
-		ReplyHandlers: replyHandlers,
-		TxtHandlers:   txtHandlers,
+		ReplyHandlers:  replyHandlers,
+		ForwardHandler: subscriptions.ForwardHandlerFunc,
+		TxtHandlers:    txtHandlers,
 	}
 
 	hPaid := service.PaidChatMemberHandler{
 		GroupId:                      groupId,
-		LimitByChatIdSubscriptions:   cfg.Api.Usage.Limits.Subscriptions,
-		LimitByChatIdInterests:       cfg.Api.Usage.Limits.Interests,
-		LimitByChatIdInterestsPublic: cfg.Api.Usage.Limits.InterestsPublic,
-		SvcLimits:                    svcLimits,
+		LimitByChatIdSubscriptions:   cfg.Api.Usage.Tiers.LimitByChatId(usage.SubjectSubscriptions, cfg.Api.Usage.Limits.Subscriptions),
+		LimitByChatIdInterests:       cfg.Api.Usage.Tiers.LimitByChatId(usage.SubjectInterests, cfg.Api.Usage.Limits.Interests),
+		LimitByChatIdInterestsPublic: cfg.Api.Usage.Tiers.LimitByChatId(usage.SubjectInterestsPublic, cfg.Api.Usage.Limits.InterestsPublic),
+		SvcLimits:                    limits.NewRecorded(svcLimits, storageLimitRecords, limits.SourcePaidChannel),
+		Members:                      storagePaidMembers,
+		LimitRecords:                 storageLimitRecords,
 	}
 
 	// init Telegram bot
@@ -201,7 +358,9 @@ func main() {
 				"chosen_inline_result",
 				"inline_query",
 				"message",
+				"my_chat_member",
 				"poll",
+				"pre_checkout_query",
 			},
 		},
 		Token: cfg.Api.Telegram.Token,
@@ -225,10 +384,22 @@ func main() {
 			Text:        "pub",
 			Description: "Publish a simple message",
 		},
+		{
+			Text:        "scheduled",
+			Description: "List scheduled messages",
+		},
 		{
 			Text:        "sub",
 			Description: "Create a simple interest and subscribe",
 		},
+		{
+			Text:        "usage",
+			Description: "Show own usage and limits",
+		},
+		{
+			Text:        "purchases",
+			Description: "Show own purchases history",
+		},
 		{
 			Text:        "following",
 			Description: "List subscriptions in this chat",
@@ -237,6 +408,30 @@ func main() {
 			Text:        "interests",
 			Description: "List all available interests",
 		},
+		{
+			Text:        "find",
+			Description: "Find own and public interests by text",
+		},
+		{
+			Text:        "follow",
+			Description: "Follow a Telegram channel",
+		},
+		{
+			Text:        "channel",
+			Description: "Deliver interests to own channel",
+		},
+		{
+			Text:        "export",
+			Description: "Export own interests",
+		},
+		{
+			Text:        "import",
+			Description: "Import own interests",
+		},
+		{
+			Text:        "channelsettings",
+			Description: "Own channel publishing settings",
+		},
 		{
 			Text:        "donate",
 			Description: "Donate",
@@ -284,6 +479,7 @@ func main() {
 	b.Use(func(next telebot.HandlerFunc) telebot.HandlerFunc {
 		return service.LoggingHandlerFunc(next, log)
 	})
+	b.Use(service.TopicHandlerFunc)
 	subListHandlerFunc := subscriptions.ListOnGroupStartHandlerFunc(svcInterests, svcSubs, groupId, urlCallbackBase)
 	b.Handle(
 		"/start",
@@ -314,20 +510,40 @@ func main() {
 	b.Handle("/app", func(tgCtx telebot.Context) error {
 		return tgCtx.Send("<a href=\"https://awakari.com/login.html\">Link to App</a>", telebot.ModeHTML)
 	})
-	b.Handle("/pub", messages.PublishBasicRequest)
+	b.Handle("/pub", messages.PublishRequestHandlerFunc(hUsage))
+	b.Handle("/usage", service.ErrorHandlerFunc(hUsage.UsageHandlerFunc))
+	b.Handle("/admin", service.ErrorHandlerFunc(hAdmin.Only(hAdmin.Usage)))
+	b.Handle("/admin_limits", service.ErrorHandlerFunc(hAdmin.Only(hAdmin.Limits)))
+	b.Handle("/admin_limit_set", service.ErrorHandlerFunc(hAdmin.Only(hAdmin.LimitSet)))
+	b.Handle("/admin_limit_del", service.ErrorHandlerFunc(hAdmin.Only(hAdmin.LimitDelete)))
+	b.Handle("/admin_subs", service.ErrorHandlerFunc(hAdmin.Only(hAdmin.Subscriptions)))
+	b.Handle("/admin_unsub", service.ErrorHandlerFunc(hAdmin.Only(hAdmin.Unsubscribe)))
+	b.Handle("/admin_broadcast", service.ErrorHandlerFunc(hAdmin.Only(hAdmin.Broadcast)))
+	b.Handle("/admin_health", service.ErrorHandlerFunc(hAdmin.Only(hAdmin.Health)))
+	b.Handle("/purchases", service.ErrorHandlerFunc(hPayments.PurchasesHandlerFunc))
+	b.Handle("/refund", service.ErrorHandlerFunc(hPayments.RefundHandlerFunc))
+	b.Handle("/scheduled", messages.ScheduledListHandlerFunc(storageSchedule))
 	b.Handle("/sub", subscriptions.CreateBasicRequest)
 	b.Handle("/following", subscriptions.ListFollowing(svcInterests, svcSubs, groupId, urlCallbackBase))
 	b.Handle("/interests", subscriptions.ListPublicHandlerFunc(svcInterests, svcSubs, groupId, urlCallbackBase))
+	b.Handle("/find", service.ErrorHandlerFunc(subscriptions.FindHandlerFunc(svcInterests, svcSubs, groupId, urlCallbackBase)))
+	b.Handle("/follow", service.ErrorHandlerFunc(subscriptions.FollowHandlerFunc(svcInterests, svcSubs, limitReached, urlCallbackBase, groupId)))
+	b.Handle("/channel", service.ErrorHandlerFunc(subscriptions.ChannelLinkRequest))
+	b.Handle("/export", service.ErrorHandlerFunc(subscriptions.ExportInterests(svcInterests, groupId)))
+	b.Handle("/import", service.ErrorHandlerFunc(subscriptions.ImportInterestsRequest))
+	b.Handle("/channelsettings", service.ErrorHandlerFunc(messages.ChannelSettingsHandlerFunc(storageChanSettings)))
 	b.Handle("/donate", service.DonationHandler)
 	b.Handle("/help", func(tgCtx telebot.Context) error {
 		return tgCtx.Send("Open the <a href=\"https://awakari.com/#resources\">link</a>", telebot.ModeHTML)
 	})
 	b.Handle("/support", func(tgCtx telebot.Context) error {
-		_ = tgCtx.Send("Describe your issue in the reply to the next message")
+		_ = tgCtx.Send("Describe your issue in the reply to the next message, a screenshot or a file may be attached")
 		return tgCtx.Send("support", &telebot.ReplyMarkup{
 			ForceReply: true,
 		})
 	})
+	b.Handle("/tickets", service.ErrorHandlerFunc(supportHandler.ListHandlerFunc))
+	b.Handle("/ticket_close", service.ErrorHandlerFunc(supportHandler.CloseHandlerFunc))
 	b.Handle("/terms", func(tgCtx telebot.Context) error {
 		return tgCtx.Send("Open the <a href=\"https://awakari.com/tos.html\">terms link</a>", telebot.ModeHTML)
 	})
@@ -335,12 +551,12 @@ func main() {
 		return tgCtx.Send("Open the <a href=\"https://awakari.com/privacy.html\">privacy link</a>", telebot.ModeHTML)
 	})
 	b.Handle(telebot.OnCallback, service.ErrorHandlerFunc(service.Callback(callbackHandlers)))
-	b.Handle(telebot.OnText, service.ErrorHandlerFunc(hRoot.Handle))
-	b.Handle(telebot.OnPhoto, service.ErrorHandlerFunc(hRoot.Handle))
-	b.Handle(telebot.OnAudio, service.ErrorHandlerFunc(hRoot.Handle))
-	b.Handle(telebot.OnVideo, service.ErrorHandlerFunc(hRoot.Handle))
-	b.Handle(telebot.OnDocument, service.ErrorHandlerFunc(hRoot.Handle))
-	b.Handle(telebot.OnLocation, service.ErrorHandlerFunc(hRoot.Handle))
+	b.Handle(telebot.OnText, service.ErrorHandlerFunc(supportHandler.Relay(hRoot.Handle)))
+	b.Handle(telebot.OnPhoto, service.ErrorHandlerFunc(supportHandler.Relay(hRoot.Handle)))
+	b.Handle(telebot.OnAudio, service.ErrorHandlerFunc(supportHandler.Relay(hRoot.Handle)))
+	b.Handle(telebot.OnVideo, service.ErrorHandlerFunc(supportHandler.Relay(hRoot.Handle)))
+	b.Handle(telebot.OnDocument, service.ErrorHandlerFunc(supportHandler.Relay(hRoot.Handle)))
+	b.Handle(telebot.OnLocation, service.ErrorHandlerFunc(supportHandler.Relay(hRoot.Handle)))
 	//
 	b.Handle(telebot.OnChannelPost, func(tgCtx telebot.Context) (err error) {
 		txt := tgCtx.Text()
@@ -350,6 +566,8 @@ func main() {
 			// public interest channel created by Awakari
 			args := strings.Split(txt, " ")
 			err = handlerSubscribe(tgCtx, args...)
+		} else if strings.HasPrefix(txt, "/channelsettings") {
+			err = service.ErrorHandlerFunc(messages.ChannelSettingsHandlerFunc(storageChanSettings))(tgCtx)
 		} else {
 			err = chanPostHandler.Publish(tgCtx, chanUserName)
 		}
@@ -359,6 +577,25 @@ func main() {
 		// err = service.DonationMessagePin(tgCtx)
 		return service.ErrorHandlerFunc(subListHandlerFunc)(tgCtx)
 	})
+	b.Handle(telebot.OnMigration, func(tgCtx telebot.Context) error {
+		err := subscriptions.Migrate(svcSubs, chatSubscribers, urlCallbackBase, groupId)(tgCtx)
+		ll := util.LogLevel(err)
+		log.Log(context.TODO(), ll, fmt.Sprintf("subscriptions.Migrate(): %s", err))
+		return err
+	})
+	b.Handle(telebot.OnMyChatMember, func(tgCtx telebot.Context) error {
+		err := subscriptions.BotRemoved(svcSubs, chatSubscribers, urlCallbackBase, groupId)(tgCtx)
+		ll := util.LogLevel(err)
+		log.Log(context.TODO(), ll, fmt.Sprintf("subscriptions.BotRemoved(): %s", err))
+		return err
+	})
+	b.Handle(telebot.OnCheckout, func(tgCtx telebot.Context) error {
+		err := hPayments.PreCheckout(tgCtx)
+		ll := util.LogLevel(err)
+		log.Log(context.TODO(), ll, fmt.Sprintf("payments.Handler.PreCheckout(): %s", err))
+		return err
+	})
+	b.Handle(telebot.OnPayment, service.ErrorHandlerFunc(hPayments.Payment))
 	b.Handle(telebot.OnChatMember, func(tgCtx telebot.Context) error {
 		err = hPaid.Handle(tgCtx)
 		ll := util.LogLevel(err)
@@ -367,14 +604,59 @@ func main() {
 	})
 	//
 	go b.Start()
+	// the background workers run in a single bot instance at a time
+	hostname, _ := os.Hostname()
+	go storage.Lease{
+		Storage: storageLeases,
+		Name:    "workers",
+		Holder:  hostname,
+		Ttl:     cfg.Storage.Lease.Ttl,
+	}.Run(context.Background(), func(ctx context.Context) {
+		go obChanPosts.Run(ctx)
+		go messages.Scheduler{
+			SvcPub:   svcPubUser,
+			Schedule: storageSchedule,
+			Bot:      b,
+			Interval: cfg.Api.Messages.Schedule.Interval,
+			Log:      log,
+		}.Run(ctx)
+		go service.LimitReminder{
+			SvcLimits: svcLimits,
+			Tiers:     cfg.Api.Usage.Tiers,
+			GroupId:   groupId,
+			Records:   storageLimitRecords,
+			Reminded:  storageLimitReminded,
+			Bot:       b,
+			Interval:  cfg.Api.Usage.Expiry.Interval,
+			Ahead:     cfg.Api.Usage.Expiry.Ahead,
+			Log:       log,
+		}.Run(ctx)
+		go service.PaidLimitsReconciler{
+			Handler:       hPaid,
+			Bot:           b,
+			Interval:      cfg.Api.Usage.Reconcile.Interval,
+			DryRun:        cfg.Api.Usage.Reconcile.DryRun,
+			SupportChatId: cfg.Api.Telegram.SupportChatId,
+			Log:           log,
+		}.Run(ctx)
+	})
 
 	// chats websub handler (subscriber)
-	hChats := chats.NewHandler(cfg.Api.Subscriptions.Uri+"/v1", fmtMsg, urlCallbackBase, svcSubs, b, svcInterests, groupId)
+	hChats := chats.NewHandler(cfg.Api.Subscriptions.Uri+"/v1", fmtMsg, urlCallbackBase, svcSubs, chatSubscribers, b, svcInterests, groupId)
 	r := gin.Default()
 	r.
 		Group(cfg.Api.Subscriptions.CallBack.Path).
 		GET("/:chatId", hChats.Confirm).
 		POST("/:chatId", hChats.DeliverMessages)
+	r.GET("/metrics", func(ctx *gin.Context) {
+		ctx.String(
+			http.StatusOK,
+			"# HELP awakari_bot_telegram_outbox_depth Count of channel posts pending to publish.\n"+
+				"# TYPE awakari_bot_telegram_outbox_depth gauge\n"+
+				"awakari_bot_telegram_outbox_depth %d\n",
+			obChanPosts.Depth(),
+		)
+	})
 	err = r.Run(fmt.Sprintf(":%d", cfg.Api.Subscriptions.CallBack.Port))
 	if err != nil {
 		panic(err)
@@ -19,3 +19,6 @@ const CeKeyDescription = "description"
 const CeKeyTitle = "title"
 const CeKeySummary = "summary"
 const CeKeyHeadline = "headline"
+const CeKeySource = "source"
+const CeKeyLanguage = "language"
+const CeKeyObjectUrl = "objecturl"
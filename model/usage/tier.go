@@ -0,0 +1,152 @@
+package usage
+
+import (
+	"fmt"
+	"github.com/bytedance/sonic"
+	"maps"
+	"sort"
+)
+
+// Tier represents the paid option to increase the usage limit.
+type Tier struct {
+
+	// Subject represents the usage subject the Tier increases the limit of.
+	Subject Subject `json:"subject"`
+
+	// Limit represents the usage limit provided by the Tier.
+	Limit int64 `json:"limit"`
+
+	// Price represents the human-readable price label, e.g. "€2/month".
+	Price string `json:"price,omitempty"`
+
+	// Url represents the purchase link of the paid channel membership.
+	Url string `json:"url,omitempty"`
+
+	// ChatId represents the paid channel the Url sells the membership in, the channel members get the limit.
+	ChatId int64 `json:"chatId,omitempty"`
+
+	// Stars represents the price in Telegram Stars when the Tier is purchased in the bot, used when Url is not set.
+	Stars int `json:"stars,omitempty"`
+
+	// Days represents the duration of the limit purchased in the bot.
+	Days int `json:"days,omitempty"`
+}
+
+// Tiers is the list of Tier decoded from the JSON array, e.g.
+// [{"subject":"subscriptions","limit":5,"price":"€1/month","url":"https://t.me/tribute/app?startapp=svd8"}]
+type Tiers []Tier
+
+// TiersDefault are the subscriptions limit tiers sold by the paid channels, used when no tiers are configured.
+var TiersDefault = Tiers{
+	{
+		Subject: SubjectSubscriptions,
+		Limit:   5,
+		Url:     "https://t.me/tribute/app?startapp=svd8",
+	},
+	{
+		Subject: SubjectSubscriptions,
+		Limit:   10,
+		Url:     "https://t.me/tribute/app?startapp=sv5Q",
+	},
+	{
+		Subject: SubjectSubscriptions,
+		Limit:   20,
+		Url:     "https://t.me/tribute/app?startapp=svaR",
+	},
+}
+
+var subjectNames = map[Subject]string{
+	SubjectInterests:       "interests",
+	SubjectPublishHourly:   "publishHourly",
+	SubjectPublishDaily:    "publishDaily",
+	SubjectInterestsPublic: "interestsPublic",
+	SubjectSubscriptions:   "subscriptions",
+}
+
+func (s Subject) MarshalText() (txt []byte, err error) {
+	name, found := subjectNames[s]
+	if !found {
+		err = fmt.Errorf("invalid subject: %d", s)
+	}
+	txt = []byte(name)
+	return
+}
+
+func (s *Subject) UnmarshalText(txt []byte) (err error) {
+	for subj, name := range subjectNames {
+		if name == string(txt) {
+			*s = subj
+			return
+		}
+	}
+	err = fmt.Errorf("invalid subject: %s", txt)
+	return
+}
+
+// Decode implements the envconfig.Decoder.
+func (ts *Tiers) Decode(value string) (err error) {
+	if value != "" {
+		err = sonic.UnmarshalString(value, ts)
+	}
+	if err == nil {
+		err = ts.Validate()
+	}
+	return
+}
+
+// Validate returns the error when any tier can't be purchased: either the purchase url or the price in stars should be
+// set. The paid channel chat id alone is not enough, the channel membership should be purchased.
+func (ts Tiers) Validate() (err error) {
+	for _, t := range ts {
+		switch {
+		case t.Limit <= 0 || (t.Url == "" && t.Stars <= 0):
+			err = fmt.Errorf("invalid tier, the limit and either url or stars should be set: %+v", t)
+		case t.Stars > 0 && t.Days <= 0:
+			err = fmt.Errorf("invalid tier, the days should be set when the price is in stars: %+v", t)
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Upgrades returns the tiers of the subject providing the limit greater than the current one, ordered by the limit.
+func (ts Tiers) Upgrades(subj Subject, limitCurr int64) (upgrades []Tier) {
+	for _, t := range ts {
+		if t.Subject == subj && t.Limit > limitCurr {
+			upgrades = append(upgrades, t)
+		}
+	}
+	sort.Slice(upgrades, func(i, j int) bool {
+		return upgrades[i].Limit < upgrades[j].Limit
+	})
+	return
+}
+
+// Find returns the tier of the subject providing exactly the specified limit.
+func (ts Tiers) Find(subj Subject, limit int64) (t Tier, found bool) {
+	for _, t = range ts {
+		if t.Subject == subj && t.Limit == limit {
+			found = true
+			return
+		}
+	}
+	t = Tier{}
+	return
+}
+
+// LimitByChatId returns the limits provided by the paid channels for the subject. The channels from the tiers take
+// precedence over the given ones, the latter come from the legacy configuration.
+func (ts Tiers) LimitByChatId(subj Subject, limitByChatIdLegacy map[int64]int64) (limitByChatId map[int64]int64) {
+	limitByChatId = maps.Clone(limitByChatIdLegacy)
+	if limitByChatId == nil {
+		limitByChatId = map[int64]int64{}
+	}
+	for _, t := range ts {
+		if t.Subject == subj && t.ChatId != 0 {
+			limitByChatId[t.ChatId] = t.Limit
+		}
+	}
+	return
+}
@@ -0,0 +1,98 @@
+package usage
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTiers_Decode(t *testing.T) {
+	cases := map[string]struct {
+		in    string
+		out   Tiers
+		valid bool
+	}{
+		"empty": {
+			valid: true,
+		},
+		"ok": {
+			in: `[{"subject":"subscriptions","limit":5,"price":"€1/month","url":"https://t.me/tribute/app?startapp=svd8"},` +
+				`{"subject":"interests","limit":100,"url":"https://t.me/tribute/app?startapp=i100","chatId":-1002672306001}]`,
+			out: Tiers{
+				{
+					Subject: SubjectSubscriptions,
+					Limit:   5,
+					Price:   "€1/month",
+					Url:     "https://t.me/tribute/app?startapp=svd8",
+				},
+				{
+					Subject: SubjectInterests,
+					Limit:   100,
+					Url:     "https://t.me/tribute/app?startapp=i100",
+					ChatId:  -1002672306001,
+				},
+			},
+			valid: true,
+		},
+		"unknown subject": {
+			in: `[{"subject":"foo","limit":5,"url":"https://t.me/tribute/app?startapp=svd8"}]`,
+		},
+		"no url nor stars": {
+			in: `[{"subject":"subscriptions","limit":5}]`,
+		},
+		"chat id only": {
+			in: `[{"subject":"subscriptions","limit":5,"chatId":-1002672306001}]`,
+		},
+		"stars": {
+			in: `[{"subject":"publishDaily","limit":100,"price":"50 ⭐","stars":50,"days":30}]`,
+			out: Tiers{
+				{
+					Subject: SubjectPublishDaily,
+					Limit:   100,
+					Price:   "50 ⭐",
+					Stars:   50,
+					Days:    30,
+				},
+			},
+			valid: true,
+		},
+		"stars without days": {
+			in: `[{"subject":"publishDaily","limit":100,"stars":50}]`,
+		},
+		"no limit": {
+			in: `[{"subject":"subscriptions","url":"https://t.me/tribute/app?startapp=svd8"}]`,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			var ts Tiers
+			err := ts.Decode(c.in)
+			if c.valid {
+				assert.Nil(t, err)
+				assert.Equal(t, c.out, ts)
+			} else {
+				assert.NotNil(t, err)
+			}
+		})
+	}
+}
+
+func TestTiers_Upgrades(t *testing.T) {
+	ts := Tiers{
+		{Subject: SubjectSubscriptions, Limit: 20, Url: "u20"},
+		{Subject: SubjectSubscriptions, Limit: 5, Url: "u5"},
+		{Subject: SubjectInterests, Limit: 100, Url: "i100", ChatId: -1},
+		{Subject: SubjectSubscriptions, Limit: 10, Url: "u10"},
+	}
+	assert.Equal(t, []Tier{
+		{Subject: SubjectSubscriptions, Limit: 10, Url: "u10"},
+		{Subject: SubjectSubscriptions, Limit: 20, Url: "u20"},
+	}, ts.Upgrades(SubjectSubscriptions, 5))
+	assert.Nil(t, ts.Upgrades(SubjectSubscriptions, 20))
+	assert.Equal(t, map[int64]int64{-1: 100, -2: 50}, ts.LimitByChatId(SubjectInterests, map[int64]int64{-1: 10, -2: 50}))
+	tier, found := ts.Find(SubjectSubscriptions, 10)
+	assert.True(t, found)
+	assert.Equal(t, "u10", tier.Url)
+	_, found = ts.Find(SubjectSubscriptions, 15)
+	assert.False(t, found)
+	assert.Equal(t, map[int64]int64{}, ts.LimitByChatId(SubjectSubscriptions, nil))
+}
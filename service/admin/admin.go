@@ -0,0 +1,388 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/awakari/bot-telegram/api/http/interests"
+	"github.com/awakari/bot-telegram/api/http/subscriptions"
+	"github.com/awakari/bot-telegram/model"
+	"github.com/awakari/bot-telegram/model/usage"
+	"github.com/awakari/bot-telegram/service"
+	"github.com/awakari/bot-telegram/service/limits"
+	"github.com/awakari/bot-telegram/util"
+	"google.golang.org/grpc/metadata"
+	"gopkg.in/telebot.v3"
+	"html"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stat is the named bot health indicator, e.g. the outbox depth.
+type Stat struct {
+	Name  string
+	Value func() int
+}
+
+// Handler serves the operator commands available to the configured admin users only.
+type Handler struct {
+	AdminIds        []int64
+	SvcLimits       limits.Service
+	SvcInterests    interests.Service
+	SvcSubs         subscriptions.Service
+	ChatSubscribers service.ChatSubscribers
+	UrlCallbackBase string
+	GroupId         string
+	Stats           []Stat
+	Started         time.Time
+}
+
+const broadcastDelay = 50 * time.Millisecond // stay below the Telegram rate limit of 30 messages per second
+const subsCountMax = 100
+
+const msgUsage = "<b>Admin commands</b>\n" +
+	"/admin_limits <code>user</code> - show the user limits\n" +
+	"/admin_limit_set <code>user subject count [days]</code> - set the user limit, optionally expiring\n" +
+	"/admin_limit_del <code>user subject</code> - reset the user limit to default\n" +
+	"/admin_subs <code>chat</code> - list the chat subscriptions\n" +
+	"/admin_unsub <code>chat interest [user]</code> - unsubscribe the chat from the interest, for all the " +
+	"subscribers unless the user is specified\n" +
+	"/admin_broadcast <code>chat[,chat...]</code> followed by the notice text on the next lines\n" +
+	"/admin_health - show the bot health and queue stats\n" +
+	"The user is the numeric Telegram user id. The subject is one of: " +
+	"interests, publishHourly, publishDaily, interestsPublic, subscriptions."
+
+var errNotAdmin = errors.New("the command is available to the administrators only")
+var errArgs = errors.New("invalid arguments")
+
+// Only wraps the handler to reject the non-admin senders.
+func (h Handler) Only(f telebot.HandlerFunc) telebot.HandlerFunc {
+	return func(tgCtx telebot.Context) (err error) {
+		sender := tgCtx.Sender()
+		switch {
+		case sender == nil, !slices.Contains(h.AdminIds, sender.ID):
+			err = errNotAdmin
+		default:
+			err = f(tgCtx)
+		}
+		return
+	}
+}
+
+// Usage handles the "/admin" command listing the admin commands.
+func (h Handler) Usage(tgCtx telebot.Context) (err error) {
+	err = tgCtx.Send(msgUsage, telebot.ModeHTML)
+	return
+}
+
+// Limits handles the "/admin_limits <user>" command.
+func (h Handler) Limits(tgCtx telebot.Context) (err error) {
+	args := tgCtx.Args()
+	if len(args) != 1 {
+		err = fmt.Errorf("%w, expected: /admin_limits <user>", errArgs)
+		return
+	}
+	var userId string
+	userId, err = parseUserId(args[0])
+	if err != nil {
+		return
+	}
+	ctx := context.TODO()
+	var txt strings.Builder
+	txt.WriteString(fmt.Sprintf("<b>Limits of</b> <code>%s</code>\n", html.EscapeString(userId)))
+	for _, subj := range subjects {
+		l, errLim := h.SvcLimits.Get(ctx, h.GroupId, userId, subj)
+		switch {
+		case errLim != nil:
+			txt.WriteString(fmt.Sprintf("%s: %s\n", subj.Description(), html.EscapeString(errLim.Error())))
+		default:
+			txt.WriteString(fmt.Sprintf("%s: %d", subj.Description(), l.Count))
+			switch {
+			case l.UserId == "":
+				txt.WriteString(", default")
+			case !l.Expires.IsZero():
+				txt.WriteString(fmt.Sprintf(", expires %s", l.Expires.UTC().Format(time.RFC3339)))
+			}
+			txt.WriteString("\n")
+		}
+	}
+	err = tgCtx.Send(txt.String(), telebot.ModeHTML)
+	return
+}
+
+// LimitSet handles the "/admin_limit_set <user> <subject> <count> [days]" command.
+func (h Handler) LimitSet(tgCtx telebot.Context) (err error) {
+	args := tgCtx.Args()
+	if len(args) != 3 && len(args) != 4 {
+		err = fmt.Errorf("%w, expected: /admin_limit_set <user> <subject> <count> [days]", errArgs)
+		return
+	}
+	var userId string
+	userId, err = parseUserId(args[0])
+	var subj usage.Subject
+	if err == nil {
+		err = subj.UnmarshalText([]byte(args[1]))
+	}
+	var count int64
+	if err == nil {
+		count, err = strconv.ParseInt(args[2], 10, 64)
+		if err == nil && count < 0 {
+			err = fmt.Errorf("%w: negative count %d", errArgs, count)
+		}
+	}
+	var expires time.Time
+	if err == nil && len(args) == 4 {
+		var days int
+		days, err = strconv.Atoi(args[3])
+		switch {
+		case err != nil:
+		case days <= 0:
+			err = fmt.Errorf("%w: days should be positive: %d", errArgs, days)
+		default:
+			expires = time.Now().UTC().AddDate(0, 0, days)
+		}
+	}
+	if err == nil {
+		err = h.SvcLimits.Set(context.TODO(), h.GroupId, userId, subj, count, expires)
+	}
+	if err == nil {
+		txt := fmt.Sprintf("%s limit of %s set to %d", subj.Description(), userId, count)
+		if !expires.IsZero() {
+			txt += fmt.Sprintf(" until %s", expires.Format(time.RFC3339))
+		}
+		err = tgCtx.Send(txt)
+	}
+	return
+}
+
+// LimitDelete handles the "/admin_limit_del <user> <subject>" command.
+func (h Handler) LimitDelete(tgCtx telebot.Context) (err error) {
+	args := tgCtx.Args()
+	if len(args) != 2 {
+		err = fmt.Errorf("%w, expected: /admin_limit_del <user> <subject>", errArgs)
+		return
+	}
+	var userId string
+	userId, err = parseUserId(args[0])
+	var subj usage.Subject
+	if err == nil {
+		err = subj.UnmarshalText([]byte(args[1]))
+	}
+	if err == nil {
+		err = h.SvcLimits.Delete(context.TODO(), h.GroupId, userId, subj)
+	}
+	if err == nil {
+		err = tgCtx.Send(fmt.Sprintf("%s limit of %s reset to default", subj.Description(), userId))
+	}
+	return
+}
+
+// Subscriptions handles the "/admin_subs <chat>" command listing the interests the chat is subscribed to by any user.
+func (h Handler) Subscriptions(tgCtx telebot.Context) (err error) {
+	args := tgCtx.Args()
+	if len(args) != 1 {
+		err = fmt.Errorf("%w, expected: /admin_subs <chat>", errArgs)
+		return
+	}
+	var chatId int64
+	chatId, err = strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		err = fmt.Errorf("%w: chat id %s", errArgs, args[0])
+		return
+	}
+	groupIdCtx := metadata.AppendToOutgoingContext(context.TODO(), model.KeyGroupId, h.GroupId)
+	var lines []string
+	_, failed, errWalk := service.WalkChat(
+		h.SvcSubs, h.ChatSubscribers, h.UrlCallbackBase, h.GroupId, chatId,
+		func(ctx context.Context, userId, interestId, urlCallback string) (err error) {
+			descr := "?"
+			d, errRead := h.SvcInterests.Read(groupIdCtx, h.GroupId, userId, interestId)
+			if errRead == nil {
+				descr = d.Description
+			}
+			lines = append(lines, fmt.Sprintf(
+				"<code>%s</code> %s, subscriber: <code>%s</code>\n",
+				html.EscapeString(interestId), html.EscapeString(descr), html.EscapeString(userId),
+			))
+			return
+		},
+	)
+	if errWalk != nil && len(lines) == 0 && len(failed) == 0 {
+		err = errWalk
+		return
+	}
+	var txt strings.Builder
+	txt.WriteString(fmt.Sprintf("<b>Subscriptions of the chat</b> <code>%d</code>: %d\n", chatId, len(lines)))
+	for i, line := range lines {
+		if i == subsCountMax {
+			txt.WriteString("...\n")
+			break
+		}
+		txt.WriteString(line)
+	}
+	if len(failed) > 0 {
+		txt.WriteString(fmt.Sprintf("Failed to resolve: %s\n", html.EscapeString(strings.Join(failed, ", "))))
+	}
+	err = tgCtx.Send(txt.String(), telebot.ModeHTML)
+	return
+}
+
+// Unsubscribe handles the "/admin_unsub <chat> <interest> [user]" command. The chat is unsubscribed for every known
+// subscriber unless the user is specified, the latter is useful when the subscriber is not known to the bot yet.
+func (h Handler) Unsubscribe(tgCtx telebot.Context) (err error) {
+	args := tgCtx.Args()
+	if len(args) != 2 && len(args) != 3 {
+		err = fmt.Errorf("%w, expected: /admin_unsub <chat> <interest> [user]", errArgs)
+		return
+	}
+	var chatId int64
+	chatId, err = strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		err = fmt.Errorf("%w: chat id %s", errArgs, args[0])
+		return
+	}
+	var subscriberId string
+	if len(args) == 3 {
+		subscriberId, err = parseUserId(args[2])
+	}
+	if err != nil {
+		return
+	}
+	interestId := args[1]
+	var count int
+	var errUnsub error
+	unsub := func(ctx context.Context, userId, id, urlCallback string) (err error) {
+		if id == interestId {
+			err = h.SvcSubs.Unsubscribe(ctx, id, h.GroupId, userId, urlCallback)
+			switch err {
+			case nil:
+				count++
+			default:
+				errUnsub = errors.Join(errUnsub, err)
+			}
+		}
+		return
+	}
+	var errWalk error
+	switch subscriberId {
+	case "":
+		_, _, errWalk = service.WalkChat(h.SvcSubs, h.ChatSubscribers, h.UrlCallbackBase, h.GroupId, chatId, unsub)
+	default:
+		_, _, errWalk = service.WalkChatUser(
+			h.SvcSubs, h.ChatSubscribers, h.UrlCallbackBase, h.GroupId, subscriberId, chatId, 0, unsub,
+		)
+	}
+	switch {
+	case errUnsub != nil:
+		err = errUnsub
+	case count == 0:
+		err = errors.Join(fmt.Errorf("%w: chat %d, interest %s", subscriptions.ErrNotFound, chatId, interestId), errWalk)
+	default:
+		err = tgCtx.Send(fmt.Sprintf("Chat %d unsubscribed from the interest %s: %d", chatId, interestId, count))
+	}
+	return
+}
+
+// Broadcast handles the "/admin_broadcast <chat>[,<chat>...]" command followed by the notice text on the next lines.
+func (h Handler) Broadcast(tgCtx telebot.Context) (err error) {
+	// the command payload ends at the line break, so the text is taken from the message
+	cmd, txt, _ := strings.Cut(tgCtx.Message().Text, "\n")
+	_, head, _ := strings.Cut(cmd, " ")
+	txt = strings.TrimSpace(txt)
+	var chatIds []int64
+	chatIds, err = parseChatIds(head)
+	if err == nil && txt == "" {
+		err = fmt.Errorf("%w: empty notice text", errArgs)
+	}
+	if err != nil {
+		err = fmt.Errorf("%w, expected: /admin_broadcast <chat>[,<chat>...] followed by the notice text on the next lines", err)
+		return
+	}
+	bot := tgCtx.Bot()
+	var countOk int
+	var failed []string
+	for i, chatId := range chatIds {
+		if i > 0 {
+			time.Sleep(broadcastDelay)
+		}
+		_, errSend := bot.Send(telebot.ChatID(chatId), txt)
+		switch errSend {
+		case nil:
+			countOk++
+		default:
+			failed = append(failed, fmt.Sprintf("%d: %s", chatId, errSend))
+		}
+	}
+	report := fmt.Sprintf("Broadcast done, sent: %d, failed: %d", countOk, len(failed))
+	if len(failed) > 0 {
+		report += "\n" + strings.Join(failed, "\n")
+	}
+	err = tgCtx.Send(report)
+	return
+}
+
+// Health handles the "/admin_health" command.
+func (h Handler) Health(tgCtx telebot.Context) (err error) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	var txt strings.Builder
+	txt.WriteString("<b>Health</b>\n")
+	txt.WriteString(fmt.Sprintf("Uptime: %s\n", time.Since(h.Started).Round(time.Second)))
+	txt.WriteString(fmt.Sprintf("Goroutines: %d\n", runtime.NumGoroutine()))
+	txt.WriteString(fmt.Sprintf("Memory: %d MB\n", mem.Alloc>>20))
+	_, errLimits := h.SvcLimits.Get(context.TODO(), h.GroupId, util.SenderToUserId(tgCtx), usage.SubjectSubscriptions)
+	switch errLimits {
+	case nil:
+		txt.WriteString("Usage service: ok\n")
+	default:
+		txt.WriteString(fmt.Sprintf("Usage service: %s\n", html.EscapeString(errLimits.Error())))
+	}
+	for _, s := range h.Stats {
+		txt.WriteString(fmt.Sprintf("%s: %d\n", s.Name, s.Value()))
+	}
+	err = tgCtx.Send(txt.String(), telebot.ModeHTML)
+	return
+}
+
+var subjects = []usage.Subject{
+	usage.SubjectInterests,
+	usage.SubjectInterestsPublic,
+	usage.SubjectSubscriptions,
+	usage.SubjectPublishHourly,
+	usage.SubjectPublishDaily,
+}
+
+func parseUserId(arg string) (userId string, err error) {
+	var tgUserId int64
+	tgUserId, err = strconv.ParseInt(arg, 10, 64)
+	switch {
+	case err != nil, tgUserId <= 0:
+		err = fmt.Errorf("%w: user id should be the positive Telegram user id: %s", errArgs, arg)
+	default:
+		userId = util.TelegramToAwakariUserId(tgUserId)
+	}
+	return
+}
+
+func parseChatIds(arg string) (chatIds []int64, err error) {
+	for _, s := range strings.Split(arg, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		var chatId int64
+		chatId, err = strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			err = fmt.Errorf("%w: chat id %s", errArgs, s)
+			return
+		}
+		chatIds = append(chatIds, chatId)
+	}
+	if len(chatIds) == 0 {
+		err = fmt.Errorf("%w: no chat ids", errArgs)
+	}
+	return
+}
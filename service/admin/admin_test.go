@@ -0,0 +1,244 @@
+package admin
+
+import (
+	"context"
+	"github.com/awakari/bot-telegram/api/http/interests"
+	"github.com/awakari/bot-telegram/api/http/subscriptions"
+	"github.com/awakari/bot-telegram/model/interest"
+	"github.com/awakari/bot-telegram/service"
+	"github.com/awakari/bot-telegram/service/storage"
+	"github.com/bytedance/sonic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/telebot.v3"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+const urlCallbackBaseTest = "http://bot-telegram:8081/v1/chat"
+
+type interestsStub struct {
+	interests.Service
+}
+
+func (is interestsStub) Read(ctx context.Context, groupId, userId, subId string) (subData interest.Data, err error) {
+	subData.Description = "descr of " + subId
+	return
+}
+
+// newHandlerTest returns the admin handler for the chat -1001 subscriptions of the users 1 and 2 along with the bot
+// recording the sent texts.
+func newHandlerTest(t *testing.T) (h Handler, b *telebot.Bot, urls map[string]map[string][]string, sent func() []string) {
+	var lock sync.Mutex
+	var txts []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg struct {
+			Text string `json:"text"`
+		}
+		_ = sonic.ConfigDefault.NewDecoder(r.Body).Decode(&msg)
+		lock.Lock()
+		txts = append(txts, msg.Text)
+		lock.Unlock()
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":1,"chat":{"id":1}}}`))
+	}))
+	t.Cleanup(srv.Close)
+	b, err := telebot.NewBot(telebot.Settings{
+		URL:     srv.URL,
+		Offline: true,
+	})
+	require.Nil(t, err)
+	s, err := storage.NewFile[service.ChatSubscriber](filepath.Join(t.TempDir(), "chat-subscribers.json"))
+	require.Nil(t, err)
+	cs := service.ChatSubscribers{
+		Storage: s,
+	}
+	require.Nil(t, cs.Add(-1001, 0, "tg://user?id=1"))
+	require.Nil(t, cs.Add(-1001, 7, "tg://user?id=2"))
+	urls = map[string]map[string][]string{
+		"tg://user?id=1": {
+			"interest0": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, "tg://user?id=1")},
+			"interest1": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1002, "tg://user?id=1")},
+		},
+		"tg://user?id=2": {
+			"interest0": {subscriptions.MakeTopicCallbackUrl(urlCallbackBaseTest, -1001, 7, "tg://user?id=2")},
+			"interest2": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, "")},
+		},
+		"tg://user?id=3": {
+			"interest3": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, "tg://user?id=3")},
+		},
+	}
+	h = Handler{
+		AdminIds:        []int64{9},
+		SvcInterests:    interestsStub{},
+		SvcSubs:         subscriptions.NewMock(urls),
+		ChatSubscribers: cs,
+		UrlCallbackBase: urlCallbackBaseTest,
+		GroupId:         "group0",
+	}
+	sent = func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]string{}, txts...)
+	}
+	return
+}
+
+func newAdminContext(b *telebot.Bot, payload string) telebot.Context {
+	return b.NewContext(telebot.Update{
+		Message: &telebot.Message{
+			Sender:  &telebot.User{ID: 9},
+			Chat:    &telebot.Chat{ID: 9},
+			Payload: payload,
+		},
+	})
+}
+
+func TestHandler_Only(t *testing.T) {
+	b, err := telebot.NewBot(telebot.Settings{
+		Offline: true,
+	})
+	assert.Nil(t, err)
+	h := Handler{
+		AdminIds: []int64{1, 2},
+	}
+	cases := map[string]struct {
+		sender *telebot.User
+		err    error
+	}{
+		"admin": {
+			sender: &telebot.User{ID: 2},
+		},
+		"not admin": {
+			sender: &telebot.User{ID: 3},
+			err:    errNotAdmin,
+		},
+		"no sender": {
+			err: errNotAdmin,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			var called bool
+			f := h.Only(func(tgCtx telebot.Context) error {
+				called = true
+				return nil
+			})
+			tgCtx := b.NewContext(telebot.Update{
+				Message: &telebot.Message{
+					Sender: c.sender,
+					Chat:   &telebot.Chat{ID: 42},
+				},
+			})
+			err := f(tgCtx)
+			assert.ErrorIs(t, err, c.err)
+			assert.Equal(t, c.err == nil, called)
+		})
+	}
+}
+
+func TestParseUserId(t *testing.T) {
+	userId, err := parseUserId("12345")
+	assert.Nil(t, err)
+	assert.Equal(t, "tg://user?id=12345", userId)
+	_, err = parseUserId("-12345")
+	assert.ErrorIs(t, err, errArgs)
+	_, err = parseUserId("@johndoe")
+	assert.ErrorIs(t, err, errArgs)
+}
+
+func TestParseChatIds(t *testing.T) {
+	chatIds, err := parseChatIds("-1001, 2,")
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{-1001, 2}, chatIds)
+	_, err = parseChatIds("")
+	assert.ErrorIs(t, err, errArgs)
+	_, err = parseChatIds("1,@chat")
+	assert.ErrorIs(t, err, errArgs)
+}
+
+func TestHandler_Subscriptions(t *testing.T) {
+	h, b, _, sent := newHandlerTest(t)
+	err := h.Subscriptions(newAdminContext(b, "-1001"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"<b>Subscriptions of the chat</b> <code>-1001</code>: 3\n" +
+			"<code>interest0</code> descr of interest0, subscriber: <code>tg://user?id=1</code>\n" +
+			"<code>interest0</code> descr of interest0, subscriber: <code>tg://user?id=2</code>\n" +
+			"<code>interest2</code> descr of interest2, subscriber: <code>tg://user?id=2</code>\n",
+	}, sent())
+}
+
+func TestHandler_Unsubscribe(t *testing.T) {
+	cases := map[string]struct {
+		payload string
+		err     error
+		left    map[string]map[string][]string
+	}{
+		"all subscribers": {
+			payload: "-1001 interest0",
+			left: map[string]map[string][]string{
+				"tg://user?id=1": {
+					"interest0": {},
+					"interest1": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1002, "tg://user?id=1")},
+				},
+				"tg://user?id=2": {
+					"interest0": {},
+					"interest2": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, "")},
+				},
+				"tg://user?id=3": {
+					"interest3": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, "tg://user?id=3")},
+				},
+			},
+		},
+		"legacy": {
+			payload: "-1001 interest2",
+			left: map[string]map[string][]string{
+				"tg://user?id=1": {
+					"interest0": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, "tg://user?id=1")},
+					"interest1": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1002, "tg://user?id=1")},
+				},
+				"tg://user?id=2": {
+					"interest0": {subscriptions.MakeTopicCallbackUrl(urlCallbackBaseTest, -1001, 7, "tg://user?id=2")},
+					"interest2": {},
+				},
+				"tg://user?id=3": {
+					"interest3": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, "tg://user?id=3")},
+				},
+			},
+		},
+		"subscriber not known": {
+			payload: "-1001 interest3 3",
+			left: map[string]map[string][]string{
+				"tg://user?id=1": {
+					"interest0": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, "tg://user?id=1")},
+					"interest1": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1002, "tg://user?id=1")},
+				},
+				"tg://user?id=2": {
+					"interest0": {subscriptions.MakeTopicCallbackUrl(urlCallbackBaseTest, -1001, 7, "tg://user?id=2")},
+					"interest2": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, "")},
+				},
+				"tg://user?id=3": {
+					"interest3": {},
+				},
+			},
+		},
+		"other chat": {
+			payload: "-1001 interest1",
+			err:     subscriptions.ErrNotFound,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			h, b, urls, sent := newHandlerTest(t)
+			err := h.Unsubscribe(newAdminContext(b, c.payload))
+			assert.ErrorIs(t, err, c.err)
+			if c.err == nil {
+				assert.Equal(t, c.left, urls)
+				assert.Len(t, sent(), 1)
+			}
+		})
+	}
+}
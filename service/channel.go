@@ -0,0 +1,42 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/telebot.v3"
+	"strconv"
+)
+
+var errChanNotAdmin = errors.New("you should be an administrator of the channel")
+var errChanBotNotAdmin = errors.New("the bot should be an administrator of the channel allowed to post messages")
+var errChanNotChannel = errors.New("not a channel")
+
+func ChannelById(tgCtx telebot.Context, chanIdRaw string) (ch *telebot.Chat, err error) {
+	var chanId int64
+	chanId, err = strconv.ParseInt(chanIdRaw, 10, 64)
+	if err == nil {
+		ch, err = tgCtx.Bot().ChatByID(chanId)
+	}
+	return
+}
+
+// VerifyChannelAdmins ensures both the sender and the bot are the channel administrators.
+func VerifyChannelAdmins(tgCtx telebot.Context, ch *telebot.Chat) (err error) {
+	if ch.Type != telebot.ChatChannel && ch.Type != telebot.ChatChannelPrivate {
+		err = fmt.Errorf("%w: %s", errChanNotChannel, ch.Type)
+		return
+	}
+	bot := tgCtx.Bot()
+	var member *telebot.ChatMember
+	member, err = bot.ChatMemberOf(ch, tgCtx.Sender())
+	if err == nil && member.Role != telebot.Creator && member.Role != telebot.Administrator {
+		err = errChanNotAdmin
+	}
+	if err == nil {
+		member, err = bot.ChatMemberOf(ch, bot.Me)
+	}
+	if err == nil && (member.Role != telebot.Administrator || !member.CanPostMessages) {
+		err = errChanBotNotAdmin
+	}
+	return
+}
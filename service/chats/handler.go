@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/awakari/bot-telegram/api/http/interests"
 	apiHttpSubs "github.com/awakari/bot-telegram/api/http/subscriptions"
+	"github.com/awakari/bot-telegram/service"
 	"github.com/awakari/bot-telegram/service/messages"
 	"github.com/awakari/bot-telegram/util"
 	"github.com/bytedance/sonic"
@@ -33,6 +34,7 @@ type handler struct {
 	format          messages.Format
 	urlCallbackBase string
 	svcSubs         apiHttpSubs.Service
+	chatSubscribers service.ChatSubscribers
 	tgBot           *telebot.Bot
 	svcInterests    interests.Service
 	groupId         string
@@ -49,6 +51,7 @@ func NewHandler(
 	format messages.Format,
 	urlCallbackBase string,
 	svcSubs apiHttpSubs.Service,
+	chatSubscribers service.ChatSubscribers,
 	tgBot *telebot.Bot,
 	svcInterests interests.Service,
 	groupId string,
@@ -58,6 +61,7 @@ func NewHandler(
 		format:          format,
 		urlCallbackBase: urlCallbackBase,
 		svcSubs:         svcSubs,
+		chatSubscribers: chatSubscribers,
 		tgBot:           tgBot,
 		svcInterests:    svcInterests,
 		groupId:         groupId,
@@ -114,11 +118,22 @@ func (h handler) DeliverMessages(ctx *gin.Context) {
 		return
 	}
 
+	var threadId int
+	threadIdRaw := ctx.Query(apiHttpSubs.KeyThreadId)
+	if threadIdRaw != "" {
+		threadId, err = strconv.Atoi(threadIdRaw)
+		if err != nil {
+			ctx.String(http.StatusBadRequest, fmt.Sprintf("thread id parameter is not a valid integer: %s", threadIdRaw))
+			return
+		}
+	}
+
 	var interesDescr string
-	userId := ctx.Query("userId")
+	userId := ctx.Query(apiHttpSubs.KeyUserId)
 	if userId == "" {
 		userId = util.TelegramToAwakariUserId(chatId) // legacy way to determine the user id
 	}
+	_ = h.chatSubscribers.Add(chatId, threadId, userId) // learn the subscriptions created before the registry
 	i, _ := h.svcInterests.Read(context.TODO(), h.groupId, userId, interestId)
 	interesDescr = i.Description
 
@@ -131,7 +146,7 @@ func (h handler) DeliverMessages(ctx *gin.Context) {
 	}
 
 	var countAck uint32
-	countAck, err = h.deliver(ctx, evts, interestId, interesDescr, userId, chatId)
+	countAck, err = h.deliver(ctx, evts, interestId, interesDescr, userId, chatId, threadId)
 	if err == nil || countAck > 0 {
 		ctx.Writer.Header().Add(keyAckCount, strconv.FormatUint(uint64(countAck), 10))
 		ctx.Status(http.StatusOK)
@@ -149,6 +164,7 @@ func (h handler) deliver(
 	interestDescr string,
 	userId string,
 	chatId int64,
+	threadId int,
 ) (
 	countAck uint32,
 	err error,
@@ -160,6 +176,7 @@ func (h handler) deliver(
 			},
 		},
 	})
+	tgCtx = service.TopicContext(tgCtx, threadId)
 	for i, evt := range evts {
 		if i > 0 {
 			time.Sleep(deliveryInterval) // try to avoid hitting the telegram delivery limit
@@ -183,12 +200,12 @@ func (h handler) deliver(
 		if err != nil {
 			switch err.(type) {
 			case telebot.FloodError:
-				go h.handleFloodError(ctx, tgCtx, interestId, userId, chatId, err.(telebot.FloodError).RetryAfter)
+				go h.handleFloodError(ctx, tgCtx, interestId, userId, chatId, threadId, err.(telebot.FloodError).RetryAfter)
 			default:
 				errTb := &telebot.Error{}
 				if errors.As(err, &errTb) && errTb.Code == 403 {
 					fmt.Printf("Bot blocked: %s, removing the chat from the storage", err)
-					urlCallback := apiHttpSubs.MakeCallbackUrl(h.urlCallbackBase, chatId, userId)
+					urlCallback := apiHttpSubs.MakeTopicCallbackUrl(h.urlCallbackBase, chatId, threadId, userId)
 					err = h.svcSubs.Unsubscribe(ctx, interestId, h.groupId, userId, urlCallback)
 					if err != nil {
 						// legacy callbacks may be without user id parameter
@@ -205,7 +222,7 @@ func (h handler) deliver(
 		if err != nil {
 			switch err.(type) {
 			case telebot.FloodError:
-				go h.handleFloodError(ctx, tgCtx, interestId, userId, chatId, err.(telebot.FloodError).RetryAfter)
+				go h.handleFloodError(ctx, tgCtx, interestId, userId, chatId, threadId, err.(telebot.FloodError).RetryAfter)
 			default:
 				fmt.Printf("Failed to send message %+v in plain text mode, cause: %s\n", tgMsg, err)
 				tgMsg = h.format.Convert(evtProto, interestId, interestDescr, messages.FormatModeRaw)
@@ -219,7 +236,7 @@ func (h handler) deliver(
 		if err != nil {
 			switch err.(type) {
 			case telebot.FloodError:
-				go h.handleFloodError(ctx, tgCtx, interestId, userId, chatId, err.(telebot.FloodError).RetryAfter)
+				go h.handleFloodError(ctx, tgCtx, interestId, userId, chatId, threadId, err.(telebot.FloodError).RetryAfter)
 			default:
 				fmt.Printf("FATAL: failed to send message %+v in raw text mode, cause: %s\n", tgMsg, err)
 				countAck++ // to skip
@@ -230,8 +247,8 @@ func (h handler) deliver(
 	return
 }
 
-func (h handler) handleFloodError(ctx context.Context, tgCtx telebot.Context, interestId, userId string, chatId int64, retryAfter int) {
-	urlCallback := apiHttpSubs.MakeCallbackUrl(h.urlCallbackBase, chatId, userId)
+func (h handler) handleFloodError(ctx context.Context, tgCtx telebot.Context, interestId, userId string, chatId int64, threadId int, retryAfter int) {
+	urlCallback := apiHttpSubs.MakeTopicCallbackUrl(h.urlCallbackBase, chatId, threadId, userId)
 	err := h.svcSubs.Unsubscribe(ctx, interestId, h.groupId, userId, urlCallback)
 	if err != nil {
 		// legacy callbacks may be without user id parameter
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/awakari/bot-telegram/model/usage"
+	"github.com/awakari/bot-telegram/service/limits"
+	"github.com/awakari/bot-telegram/service/storage"
+	"gopkg.in/telebot.v3"
+	"time"
+)
+
+// LimitReached renders the "limit reached" message with the paid tiers available to increase the limit.
+type LimitReached struct {
+	SvcLimits limits.Service
+	Tiers     usage.Tiers
+	GroupId   string
+
+	// Published is used to tell the hourly publishing limit from the daily one, optional.
+	Published storage.Storage[PublishCount]
+}
+
+// CmdLimitBuy is the callback command to purchase the tier in the bot, followed by the subject name and the limit.
+const CmdLimitBuy = "limit_buy"
+
+func (lr LimitReached) Send(tgCtx telebot.Context, userId string, subj usage.Subject) (err error) {
+	txt := fmt.Sprintf("%s limit reached", subj.Description())
+	var upgrades []usage.Tier
+	if lr.SvcLimits != nil {
+		l, errLim := lr.SvcLimits.Get(context.TODO(), lr.GroupId, userId, subj)
+		if errLim == nil {
+			txt += fmt.Sprintf(": %d", l.Count)
+			upgrades = lr.Tiers.Upgrades(subj, l.Count)
+		}
+	}
+	m := &telebot.ReplyMarkup{}
+	var rows []telebot.Row
+	for _, t := range upgrades {
+		btn := telebot.Btn{
+			Text: fmt.Sprintf("Increase to %d", t.Limit),
+		}
+		if t.Price != "" {
+			btn.Text += " for " + t.Price
+		}
+		switch {
+		case t.Url != "":
+			btn.URL = t.Url
+		case t.Stars > 0:
+			btn.Data = LimitBuyData(t)
+		}
+		if btn.URL != "" || btn.Data != "" {
+			rows = append(rows, m.Row(btn))
+		}
+	}
+	switch len(rows) {
+	case 0:
+		err = tgCtx.Send(txt + ". Use /usage to see the details.")
+	default:
+		m.Inline(rows...)
+		err = tgCtx.Send(txt, m)
+	}
+	return
+}
+
+// SendPublish is Send for the publishing limit. The publishing service doesn't tell the hourly and the daily limits
+// apart, so the one having the greater share used via the bot is considered reached, the daily one by default.
+func (lr LimitReached) SendPublish(tgCtx telebot.Context, userId string) (err error) {
+	err = lr.Send(tgCtx, userId, lr.publishSubject(context.TODO(), userId, time.Now().UTC()))
+	return
+}
+
+func (lr LimitReached) publishSubject(ctx context.Context, userId string, now time.Time) (subj usage.Subject) {
+	subj = usage.SubjectPublishDaily
+	if lr.SvcLimits == nil || lr.Published == nil {
+		return
+	}
+	pc, _ := lr.Published.Get(userId)
+	var shareMax float64
+	for _, s := range []usage.Subject{usage.SubjectPublishDaily, usage.SubjectPublishHourly} {
+		l, err := lr.SvcLimits.Get(ctx, lr.GroupId, userId, s)
+		if err == nil && l.Count > 0 {
+			share := float64(pc.Count(s, now)) / float64(l.Count)
+			if share > shareMax {
+				shareMax = share
+				subj = s
+			}
+		}
+	}
+	return
+}
+
+// LimitBuyData returns the callback data to purchase the tier in the bot.
+func LimitBuyData(t usage.Tier) (data string) {
+	subjName, _ := t.Subject.MarshalText()
+	data = fmt.Sprintf("%s %s %d", CmdLimitBuy, subjName, t.Limit)
+	return
+}
@@ -0,0 +1,43 @@
+package limits
+
+import (
+	"context"
+	"github.com/awakari/bot-telegram/model/usage"
+	"time"
+)
+
+type mock struct {
+	limits map[usage.Subject]usage.Limit
+}
+
+// NewMock returns the stub keeping the limits by subject in the given map, a missing subject causes ErrNotFound.
+func NewMock(limits map[usage.Subject]usage.Limit) Service {
+	return mock{
+		limits: limits,
+	}
+}
+
+func (m mock) Get(ctx context.Context, groupId, userId string, subj usage.Subject) (l usage.Limit, err error) {
+	var found bool
+	l, found = m.limits[subj]
+	if !found {
+		err = ErrNotFound
+	}
+	return
+}
+
+func (m mock) Set(ctx context.Context, groupId, userId string, subj usage.Subject, count int64, expires time.Time) (err error) {
+	m.limits[subj] = usage.Limit{
+		Count:   count,
+		UserId:  userId,
+		Expires: expires,
+	}
+	return
+}
+
+func (m mock) Delete(ctx context.Context, groupId, userId string, subjs ...usage.Subject) (err error) {
+	for _, subj := range subjs {
+		delete(m.limits, subj)
+	}
+	return
+}
@@ -0,0 +1,94 @@
+package limits
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/awakari/bot-telegram/model/usage"
+	"github.com/awakari/bot-telegram/service/storage"
+	"time"
+)
+
+// Source tells where the user limit came from.
+type Source int
+
+const (
+	SourceUndefined Source = iota
+	SourceAdmin
+	SourcePurchase
+	SourcePaidChannel
+)
+
+// Record is the user limit set by the bot. The limits service neither lists the limits nor tells where a limit came
+// from, so the bot keeps its own records.
+type Record struct {
+	GroupId string        `json:"groupId"`
+	UserId  string        `json:"userId"`
+	Subject usage.Subject `json:"subject"`
+	Count   int64         `json:"count"`
+	Expires time.Time     `json:"expires"`
+	Source  Source        `json:"source"`
+	Updated time.Time     `json:"updated"`
+}
+
+type recorded struct {
+	svc     Service
+	records storage.Storage[Record]
+	src     Source
+}
+
+// NewRecorded returns the Service recording the limits set through it as coming from the specified source.
+func NewRecorded(svc Service, records storage.Storage[Record], src Source) Service {
+	return recorded{
+		svc:     svc,
+		records: records,
+		src:     src,
+	}
+}
+
+// RecordKey returns the key of the user limit Record.
+func RecordKey(groupId, userId string, subj usage.Subject) (k string) {
+	k = fmt.Sprintf("%s %s %d", groupId, userId, subj)
+	return
+}
+
+func (r recorded) Get(ctx context.Context, groupId, userId string, subj usage.Subject) (l usage.Limit, err error) {
+	return r.svc.Get(ctx, groupId, userId, subj)
+}
+
+// Set records the limit before setting it and restores the previous record when the limit fails to set.
+func (r recorded) Set(ctx context.Context, groupId, userId string, subj usage.Subject, count int64, expires time.Time) (err error) {
+	k := RecordKey(groupId, userId, subj)
+	prev, found := r.records.Get(k)
+	err = r.records.Set(k, Record{
+		GroupId: groupId,
+		UserId:  userId,
+		Subject: subj,
+		Count:   count,
+		Expires: expires,
+		Source:  r.src,
+		Updated: time.Now().UTC(),
+	})
+	if err == nil {
+		err = r.svc.Set(ctx, groupId, userId, subj, count, expires)
+		if err != nil {
+			switch found {
+			case true:
+				err = errors.Join(err, r.records.Set(k, prev))
+			default:
+				err = errors.Join(err, r.records.Delete(k))
+			}
+		}
+	}
+	return
+}
+
+func (r recorded) Delete(ctx context.Context, groupId, userId string, subjs ...usage.Subject) (err error) {
+	err = r.svc.Delete(ctx, groupId, userId, subjs...)
+	if err == nil {
+		for _, subj := range subjs {
+			err = errors.Join(err, r.records.Delete(RecordKey(groupId, userId, subj)))
+		}
+	}
+	return
+}
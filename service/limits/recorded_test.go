@@ -0,0 +1,35 @@
+package limits
+
+import (
+	"context"
+	"github.com/awakari/bot-telegram/model/usage"
+	"github.com/awakari/bot-telegram/service/storage"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecorded(t *testing.T) {
+	records, err := storage.NewFile[Record](filepath.Join(t.TempDir(), "limit-records.json"))
+	assert.Nil(t, err)
+	svc := NewRecorded(NewMock(map[usage.Subject]usage.Limit{}), records, SourcePaidChannel)
+	expires := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	//
+	assert.Nil(t, svc.Set(context.TODO(), "group0", "user0", usage.SubjectSubscriptions, 10, expires))
+	rec, found := records.Get(RecordKey("group0", "user0", usage.SubjectSubscriptions))
+	assert.True(t, found)
+	assert.Equal(t, SourcePaidChannel, rec.Source)
+	assert.Equal(t, int64(10), rec.Count)
+	assert.Equal(t, expires, rec.Expires)
+	//
+	svc = NewRecorded(NewMock(map[usage.Subject]usage.Limit{}), records, SourceAdmin)
+	assert.Nil(t, svc.Set(context.TODO(), "group0", "user0", usage.SubjectSubscriptions, 20, time.Time{}))
+	rec, _ = records.Get(RecordKey("group0", "user0", usage.SubjectSubscriptions))
+	assert.Equal(t, SourceAdmin, rec.Source)
+	assert.Equal(t, int64(20), rec.Count)
+	//
+	assert.Nil(t, svc.Delete(context.TODO(), "group0", "user0", usage.SubjectSubscriptions))
+	_, found = records.Get(RecordKey("group0", "user0", usage.SubjectSubscriptions))
+	assert.False(t, found)
+}
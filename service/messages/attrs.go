@@ -0,0 +1,95 @@
+package messages
+
+import (
+	"github.com/awakari/bot-telegram/model"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"gopkg.in/telebot.v3"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+)
+
+const titleLenMax = 128
+
+var multiSpaceRegex = regexp.MustCompile(`[ \t]{2,}`)
+
+// extractTextAttrs fills the categories from the message hashtags and cashtags, and the title and summary from the
+// first line and the rest of the text. The tags may be optionally removed from the text data.
+func extractTextAttrs(msg *telebot.Message, evt *pb.CloudEvent, stripTags bool) {
+	txt := evt.GetTextData()
+	if txt == "" {
+		return
+	}
+	entities := msg.Entities
+	if msg.Text == "" {
+		entities = msg.CaptionEntities
+	}
+	var cats []string
+	catsUniq := map[string]bool{}
+	var tagEntities []telebot.MessageEntity
+	for _, e := range entities {
+		if e.Type != telebot.EntityHashtag && e.Type != telebot.EntityCashtag {
+			continue
+		}
+		tagEntities = append(tagEntities, e)
+		cat := strings.TrimLeft(msg.EntityText(e), "#$")
+		if cat != "" && !catsUniq[strings.ToLower(cat)] && len(cats) < tagCountMax {
+			catsUniq[strings.ToLower(cat)] = true
+			cats = append(cats, cat)
+		}
+	}
+	if len(cats) > 0 {
+		setStringAttrIfMissing(evt, model.CeKeyCategories, strings.Join(cats, " "))
+	}
+	if stripTags && len(tagEntities) > 0 {
+		txt = stripEntities(txt, tagEntities)
+		evt.Data = &pb.CloudEvent_TextData{
+			TextData: txt,
+		}
+	}
+	first, rest, multiline := strings.Cut(txt, "\n")
+	first = strings.TrimSpace(first)
+	rest = strings.TrimSpace(rest)
+	if multiline && first != "" && rest != "" && len([]rune(first)) <= titleLenMax {
+		setStringAttrIfMissing(evt, model.CeKeyTitle, first)
+		summary := []rune(rest)
+		if len(summary) > fmtLenMaxBodyTxt {
+			summary = summary[:fmtLenMaxBodyTxt]
+		}
+		setStringAttrIfMissing(evt, model.CeKeySummary, strings.TrimSpace(string(summary)))
+	}
+}
+
+// stripEntities removes the entities text, the entity offsets are in UTF-16 code units.
+func stripEntities(txt string, entities []telebot.MessageEntity) string {
+	a := utf16.Encode([]rune(txt))
+	var b []uint16
+	var pos int
+	for _, e := range entities {
+		off, end := e.Offset, e.Offset+e.Length
+		if off < pos || end > len(a) {
+			continue
+		}
+		b = append(b, a[pos:off]...)
+		pos = end
+	}
+	b = append(b, a[pos:]...)
+	lines := strings.Split(string(utf16.Decode(b)), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSpace(multiSpaceRegex.ReplaceAllString(l, " "))
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func setStringAttrIfMissing(evt *pb.CloudEvent, k, v string) {
+	if evt.Attributes == nil {
+		evt.Attributes = map[string]*pb.CloudEventAttributeValue{}
+	}
+	if _, found := evt.Attributes[k]; !found {
+		evt.Attributes[k] = &pb.CloudEventAttributeValue{
+			Attr: &pb.CloudEventAttributeValue_CeString{
+				CeString: v,
+			},
+		}
+	}
+}
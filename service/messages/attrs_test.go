@@ -0,0 +1,78 @@
+package messages
+
+import (
+	"github.com/awakari/bot-telegram/model"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/telebot.v3"
+	"testing"
+)
+
+func TestExtractTextAttrs(t *testing.T) {
+	txtTagged := "Новости: запуск #Tesla\nАкции $TSLA растут #tesla #EV"
+	entitiesTagged := telebot.Entities{
+		{Type: telebot.EntityHashtag, Offset: 16, Length: 6},
+		{Type: telebot.EntityCashtag, Offset: 29, Length: 5},
+		{Type: telebot.EntityHashtag, Offset: 42, Length: 6},
+		{Type: telebot.EntityHashtag, Offset: 49, Length: 3},
+	}
+	cases := map[string]struct {
+		msg   *telebot.Message
+		strip bool
+		txt   string
+		attrs map[string]string
+	}{
+		"tags and title": {
+			msg: &telebot.Message{
+				Text:     txtTagged,
+				Entities: entitiesTagged,
+			},
+			txt: txtTagged,
+			attrs: map[string]string{
+				model.CeKeyCategories: "Tesla TSLA EV",
+				model.CeKeyTitle:      "Новости: запуск #Tesla",
+				model.CeKeySummary:    "Акции $TSLA растут #tesla #EV",
+			},
+		},
+		"tags stripped from caption": {
+			msg: &telebot.Message{
+				Caption:         txtTagged,
+				CaptionEntities: entitiesTagged,
+			},
+			strip: true,
+			txt:   "Новости: запуск\nАкции растут",
+			attrs: map[string]string{
+				model.CeKeyCategories: "Tesla TSLA EV",
+				model.CeKeyTitle:      "Новости: запуск",
+				model.CeKeySummary:    "Акции растут",
+			},
+		},
+		"single line": {
+			msg: &telebot.Message{
+				Text: "just a text",
+			},
+			txt:   "just a text",
+			attrs: map[string]string{},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			txt := c.msg.Text
+			if txt == "" {
+				txt = c.msg.Caption
+			}
+			evt := &pb.CloudEvent{
+				Data: &pb.CloudEvent_TextData{
+					TextData: txt,
+				},
+			}
+			extractTextAttrs(c.msg, evt, c.strip)
+			assert.Equal(t, c.txt, evt.GetTextData())
+			attrs := map[string]string{}
+			for k, v := range evt.Attributes {
+				attrs[k] = v.GetCeString()
+			}
+			assert.Equal(t, c.attrs, attrs)
+		})
+	}
+}
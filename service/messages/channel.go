@@ -5,13 +5,15 @@ import (
 	"fmt"
 	"github.com/awakari/bot-telegram/api/http/pub"
 	"github.com/awakari/bot-telegram/config"
-	"github.com/cenkalti/backoff/v4"
+	"github.com/awakari/bot-telegram/service/storage"
+	"github.com/awakari/bot-telegram/service/unfurl"
 	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
 	"github.com/segmentio/ksuid"
 	"gopkg.in/telebot.v3"
 	"log/slog"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -33,6 +35,8 @@ type ChanPostHandler struct {
 	Channels  map[string]time.Time
 	ChansLock *sync.Mutex
 	CfgMsgs   config.MessagesConfig
+	Settings  storage.Storage[ChannelSettings]
+	Unfurl    unfurl.Service
 }
 
 const tagNoBot = "#nobot"
@@ -56,6 +60,15 @@ func (cp ChanPostHandler) Publish(tgCtx telebot.Context, chanUserName string) (e
 		}
 	}
 
+	var settings ChannelSettings
+	if cp.Settings != nil {
+		settings, _ = cp.Settings.Get(strconv.FormatInt(ch.ID, 10))
+	}
+	if reason := settings.skipReason(tgMsg, txt); reason != "" {
+		cp.Log.Debug(fmt.Sprintf("Channel %s (%d) post %d skipped: %s", chanUserName, ch.ID, tgMsg.ID, reason))
+		return
+	}
+
 	chanUserId := fmt.Sprintf("@%s", chanUserName)
 	evt := pb.CloudEvent{
 		Id:          ksuid.New().String(),
@@ -65,22 +78,11 @@ func (cp ChanPostHandler) Publish(tgCtx telebot.Context, chanUserName string) (e
 	}
 	err = toCloudEvent(tgMsg, tgCtx.Text(), &evt)
 	if err == nil {
+		enrichLink(context.TODO(), cp.Unfurl, tgMsg, &evt)
+		extractTextAttrs(tgMsg, &evt, cp.CfgMsgs.TagsStrip)
+		settings.apply(&evt)
+		setLanguageAttr(&evt)
 		err = cp.SvcPub.Publish(context.TODO(), &evt, cp.GroupId, chanUserId)
-		if err != nil {
-			// retry with a backoff
-			b := backoff.NewExponentialBackOff()
-			b.InitialInterval = 100 * time.Millisecond
-			b.MaxElapsedTime = 10 * time.Second
-			err = backoff.RetryNotify(
-				func() error {
-					return cp.SvcPub.Publish(context.TODO(), &evt, cp.GroupId, chanUserId)
-				},
-				b,
-				func(err error, d time.Duration) {
-					cp.Log.Warn(fmt.Sprintf("Failed to write event %s, cause: %s, retrying in %s...", evt.Id, err, d))
-				},
-			)
-		}
 	}
 	return
 }
@@ -0,0 +1,168 @@
+package messages
+
+import (
+	"errors"
+	"fmt"
+	"github.com/awakari/bot-telegram/model"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const attrsHeaderSeparator = "---"
+const attrsCountMax = 16
+const attrValLenMax = 1024
+
+// msgAttrsHeaderUsage describes the structured publishing format to the user.
+const msgAttrsHeaderUsage = "Optionally, start the message with the attribute lines followed by the <code>---</code> line, e.g.:\n" +
+	"<pre>title: Used bike for sale\n" +
+	"categories: bikes sale\n" +
+	"price: 120\n" +
+	"objecturl: https://example.com/bike\n" +
+	"---\n" +
+	"Almost new, pick up in Berlin</pre>"
+
+var attrKeyRegex = regexp.MustCompile(`^[a-z0-9]{1,20}$`)
+
+// attrKeysReserved are set by the bot itself and can not be overridden.
+var attrKeysReserved = map[string]bool{
+	"id":                           true,
+	"source":                       true,
+	"specversion":                  true,
+	"type":                         true,
+	"datacontenttype":              true,
+	"dataschema":                   true,
+	model.CeKeyTime:                true,
+	ceKeyTgMessageId:               true,
+	model.CeKeyTgFileId:            true,
+	model.CeKeyTgFileUniqueId:      true,
+	model.CeKeyTgFileType:          true,
+	model.CeKeyTgFileMediaDuration: true,
+	model.CeKeyTgFileImgHeight:     true,
+	model.CeKeyTgFileImgWidth:      true,
+}
+
+// attrKeysText are always strings regardless of the value, e.g. the title may be a number.
+var attrKeysText = map[string]bool{
+	model.CeKeyCategories:  true,
+	model.CeKeyDescription: true,
+	model.CeKeyHeadline:    true,
+	model.CeKeyLanguage:    true,
+	model.CeKeyName:        true,
+	model.CeKeySnippet:     true,
+	model.CeKeySummary:     true,
+	model.CeKeyTitle:       true,
+}
+
+var errAttrsHeader = errors.New("invalid attributes")
+
+// parseAttrsHeader splits the optional "key: value" lines preceding the separator line from the message text. The
+// value kind is inferred: integers, booleans, RFC 3339 timestamps and absolute http(s) URLs are type-checked and
+// converted, everything else including the decimal numbers remains a string.
+func parseAttrsHeader(txt string) (attrs map[string]*pb.CloudEventAttributeValue, body string, err error) {
+	body = txt
+	head, rest, found := strings.Cut(txt, "\n"+attrsHeaderSeparator+"\n")
+	if !found {
+		head, found = strings.CutSuffix(txt, "\n"+attrsHeaderSeparator)
+	}
+	if !found {
+		return
+	}
+	lines := strings.Split(head, "\n")
+	kvs := make([][2]string, 0, len(lines))
+	for _, l := range lines {
+		k, v, ok := strings.Cut(l, ":")
+		k = strings.ToLower(strings.TrimSpace(k))
+		if !ok || !attrKeyRegex.MatchString(k) {
+			return // not a header, the separator is a part of the text
+		}
+		kvs = append(kvs, [2]string{k, strings.TrimSpace(v)})
+	}
+	if len(kvs) > attrsCountMax {
+		err = fmt.Errorf("%w: at most %d attributes are allowed", errAttrsHeader, attrsCountMax)
+		return
+	}
+	attrs = make(map[string]*pb.CloudEventAttributeValue, len(kvs))
+	for _, kv := range kvs {
+		k, v := kv[0], kv[1]
+		switch {
+		case attrKeysReserved[k]:
+			err = fmt.Errorf("%w: attribute %q is reserved", errAttrsHeader, k)
+		case v == "":
+			err = fmt.Errorf("%w: attribute %q value is empty", errAttrsHeader, k)
+		case len(v) > attrValLenMax:
+			err = fmt.Errorf("%w: attribute %q value is longer than %d", errAttrsHeader, k, attrValLenMax)
+		default:
+			attrs[k], err = attrValue(k, v)
+		}
+		if err != nil {
+			attrs = nil
+			return
+		}
+	}
+	body = strings.TrimSpace(rest)
+	return
+}
+
+func attrValue(k, v string) (a *pb.CloudEventAttributeValue, err error) {
+	if attrKeysText[k] {
+		a = &pb.CloudEventAttributeValue{
+			Attr: &pb.CloudEventAttributeValue_CeString{
+				CeString: v,
+			},
+		}
+		return
+	}
+	if strings.HasSuffix(k, "url") {
+		u, errUrl := url.Parse(v)
+		if errUrl != nil || !u.IsAbs() || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			err = fmt.Errorf("%w: attribute %q value is not a http(s) URL: %s", errAttrsHeader, k, v)
+			return
+		}
+		a = &pb.CloudEventAttributeValue{
+			Attr: &pb.CloudEventAttributeValue_CeUri{
+				CeUri: v,
+			},
+		}
+		return
+	}
+	if i, errInt := strconv.ParseInt(v, 10, 64); errInt == nil {
+		if i < math.MinInt32 || i > math.MaxInt32 {
+			err = fmt.Errorf("%w: attribute %q integer value is out of range: %d", errAttrsHeader, k, i)
+			return
+		}
+		a = &pb.CloudEventAttributeValue{
+			Attr: &pb.CloudEventAttributeValue_CeInteger{
+				CeInteger: int32(i),
+			},
+		}
+		return
+	}
+	if v == "true" || v == "false" {
+		a = &pb.CloudEventAttributeValue{
+			Attr: &pb.CloudEventAttributeValue_CeBoolean{
+				CeBoolean: v == "true",
+			},
+		}
+		return
+	}
+	if t, errTime := time.Parse(time.RFC3339, v); errTime == nil {
+		a = &pb.CloudEventAttributeValue{
+			Attr: &pb.CloudEventAttributeValue_CeTimestamp{
+				CeTimestamp: timestamppb.New(t),
+			},
+		}
+		return
+	}
+	a = &pb.CloudEventAttributeValue{
+		Attr: &pb.CloudEventAttributeValue_CeString{
+			CeString: v,
+		},
+	}
+	return
+}
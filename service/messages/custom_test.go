@@ -0,0 +1,91 @@
+package messages
+
+import (
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"testing"
+	"time"
+)
+
+func TestParseAttrsHeader(t *testing.T) {
+	cases := map[string]struct {
+		txt   string
+		attrs map[string]*pb.CloudEventAttributeValue
+		body  string
+		err   error
+	}{
+		"no header": {
+			txt:  "Note: just a text",
+			body: "Note: just a text",
+		},
+		"separator in text": {
+			txt:  "first part\n---\nsecond part",
+			body: "first part\n---\nsecond part",
+		},
+		"typed attributes": {
+			txt: "Title: 2024\nprice: 120\nused: true\nobjecturl: https://example.com/bike\n" +
+				"until: 2026-10-20T10:00:00Z\nweight: 7.5\n---\nAlmost new",
+			attrs: map[string]*pb.CloudEventAttributeValue{
+				"title": {
+					Attr: &pb.CloudEventAttributeValue_CeString{CeString: "2024"},
+				},
+				"price": {
+					Attr: &pb.CloudEventAttributeValue_CeInteger{CeInteger: 120},
+				},
+				"used": {
+					Attr: &pb.CloudEventAttributeValue_CeBoolean{CeBoolean: true},
+				},
+				"objecturl": {
+					Attr: &pb.CloudEventAttributeValue_CeUri{CeUri: "https://example.com/bike"},
+				},
+				"until": {
+					Attr: &pb.CloudEventAttributeValue_CeTimestamp{
+						CeTimestamp: timestamppb.New(time.Date(2026, 10, 20, 10, 0, 0, 0, time.UTC)),
+					},
+				},
+				"weight": {
+					Attr: &pb.CloudEventAttributeValue_CeString{CeString: "7.5"},
+				},
+			},
+			body: "Almost new",
+		},
+		"header only": {
+			txt: "categories: bikes\n---",
+			attrs: map[string]*pb.CloudEventAttributeValue{
+				"categories": {
+					Attr: &pb.CloudEventAttributeValue_CeString{CeString: "bikes"},
+				},
+			},
+		},
+		"reserved": {
+			txt: "time: 2026-10-20T10:00:00Z\n---\nfoo",
+			err: errAttrsHeader,
+		},
+		"invalid url": {
+			txt: "objecturl: example.com\n---\nfoo",
+			err: errAttrsHeader,
+		},
+		"integer out of range": {
+			txt: "price: 10000000000\n---\nfoo",
+			err: errAttrsHeader,
+		},
+		"empty value": {
+			txt: "price:\n---\nfoo",
+			err: errAttrsHeader,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			attrs, body, err := parseAttrsHeader(c.txt)
+			assert.ErrorIs(t, err, c.err)
+			if c.err == nil {
+				assert.Equal(t, len(c.attrs), len(attrs))
+				for k, v := range c.attrs {
+					assert.Equal(t, v.String(), attrs[k].String())
+				}
+				assert.Equal(t, c.body, body)
+			}
+		})
+	}
+}
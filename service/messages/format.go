@@ -89,6 +89,10 @@ func (f Format) convert(evt *pb.CloudEvent, interestId, descr string, mode Forma
 	}
 
 	txtData := evt.GetTextData()
+	if _, msgFromTg := evt.Attributes[ceKeyTgMessageId]; attrs && msgFromTg {
+		// the title extracted from the message is rendered in the header already
+		txtData = trimTitleLine(txtData, evt.Attributes[model.CeKeyTitle].GetCeString())
+	}
 	if txtData != "" {
 		switch mode {
 		case FormatModeHtml:
@@ -148,7 +152,7 @@ func (f Format) convert(evt *pb.CloudEvent, interestId, descr string, mode Forma
 		}
 	}
 	if addrOrig == "" || (!strings.HasPrefix(addrOrig, "https://") && !strings.HasPrefix(addrOrig, "http://")) {
-		objAttr, objAttrFound = evt.Attributes["objecturl"]
+		objAttr, objAttrFound = evt.Attributes[model.CeKeyObjectUrl]
 		if objAttrFound {
 			switch objAttr.Attr.(type) {
 			case *pb.CloudEventAttributeValue_CeString:
@@ -220,7 +224,7 @@ func (f Format) header(evt *pb.CloudEvent, mode FormatMode) (txt string) {
 	attrSummary, attrSummaryFound := evt.Attributes[model.CeKeySummary]
 	if attrSummaryFound {
 		v := attrSummary.GetCeString()
-		if v != attrTitle.GetCeString() && v != attrDescr.GetCeString() && v != evt.GetTextData() {
+		if v != attrTitle.GetCeString() && v != attrDescr.GetCeString() && !summaryInText(evt, v) {
 			switch mode {
 			case FormatModeHtml:
 				v = f.HtmlPolicy.Sanitize(v)
@@ -239,6 +243,27 @@ func (f Format) header(evt *pb.CloudEvent, mode FormatMode) (txt string) {
 	return
 }
 
+// trimTitleLine removes the first line of the text when it's the title.
+func trimTitleLine(txt, title string) string {
+	first, rest, _ := strings.Cut(txt, "\n")
+	if title != "" && strings.TrimSpace(first) == title {
+		txt = rest
+	}
+	return txt
+}
+
+// summaryInText reports whether the summary is the text itself or, for the message published by the bot, the beginning
+// of the text following the title line, i.e. the summary extracted from the message text.
+func summaryInText(evt *pb.CloudEvent, summary string) (in bool) {
+	txt := evt.GetTextData()
+	in = summary == txt
+	if _, msgFromTg := evt.Attributes[ceKeyTgMessageId]; !in && msgFromTg {
+		_, rest, _ := strings.Cut(txt, "\n")
+		in = strings.HasPrefix(strings.TrimSpace(rest), summary)
+	}
+	return
+}
+
 func truncateStringUtf8(s string, lenMax int) string {
 	s = strings.TrimSpace(s)
 	if len(s) <= lenMax {
@@ -157,6 +157,69 @@ If you did not publish messages, <a href="https://awakari.com/pub.html?own=true"
 			out: `
 <a href="https://t.me/rabota_razrabotchika">Origin</a> | <a href="https://awakari.com/sub-details.html?id=sub1">Interest</a> | <a href="2yMTtfDHfZHnpTEdSVe8J90Qc6r&interestId=sub1">Match</a>`,
 		},
+		"title is not trimmed mid-word": {
+			in: &pb.CloudEvent{
+				Id:          "evt1",
+				Source:      "https://example.com/feed.xml",
+				SpecVersion: "1.0",
+				Type:        "com_awakari_feeds_v1",
+				Attributes: map[string]*pb.CloudEventAttributeValue{
+					"title": {
+						Attr: &pb.CloudEventAttributeValue_CeString{
+							CeString: "Tesla",
+						},
+					},
+					"summary": {
+						Attr: &pb.CloudEventAttributeValue_CeString{
+							CeString: "sold out",
+						},
+					},
+				},
+				Data: &pb.CloudEvent_TextData{
+					TextData: "Teslas are sold out\nagain",
+				},
+			},
+			out: `<b>Tesla</b>
+
+sold out
+
+Teslas are sold out
+again
+
+<a href="https://example.com/feed.xml">Origin</a> | <a href="https://awakari.com/sub-details.html?id=sub1">Interest</a> | <a href="evt1&interestId=sub1">Match</a>`,
+		},
+		"title line of the message published by the bot": {
+			in: &pb.CloudEvent{
+				Id:          "evt2",
+				Source:      "https://t.me/channel0",
+				SpecVersion: "1.0",
+				Type:        "com_awakari_bot_telegram_v1",
+				Attributes: map[string]*pb.CloudEventAttributeValue{
+					"title": {
+						Attr: &pb.CloudEventAttributeValue_CeString{
+							CeString: "Tesla",
+						},
+					},
+					"summary": {
+						Attr: &pb.CloudEventAttributeValue_CeString{
+							CeString: "Teslas are sold out",
+						},
+					},
+					"tgmessageid": {
+						Attr: &pb.CloudEventAttributeValue_CeString{
+							CeString: "42",
+						},
+					},
+				},
+				Data: &pb.CloudEvent_TextData{
+					TextData: "Tesla\nTeslas are sold out",
+				},
+			},
+			out: `<b>Tesla</b>
+
+Teslas are sold out
+<a href="https://t.me/channel0">Origin</a> | <a href="https://awakari.com/sub-details.html?id=sub1">Interest</a> | <a href="evt2&interestId=sub1">Match</a>`,
+		},
 	}
 	for k, c := range cases {
 		t.Run(k, func(t *testing.T) {
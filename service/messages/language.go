@@ -0,0 +1,165 @@
+package messages
+
+import (
+	"github.com/awakari/bot-telegram/model"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"strings"
+	"unicode"
+)
+
+// langLettersMin is the minimum count of letters to detect the language, shorter texts are too ambiguous.
+const langLettersMin = 16
+
+// langStopWordsMin is the minimum count of the stop words matches to detect a language using the Latin script.
+const langStopWordsMin = 2
+
+var langStopWords = map[string][]string{
+	"en": {"the", "and", "is", "are", "of", "to", "in", "that", "it", "for", "with", "this", "was", "on", "you", "be", "have", "not"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "mit", "auf", "den", "ich", "sie", "es", "von", "zu", "sich", "auch"},
+	"fr": {"le", "la", "les", "et", "est", "des", "une", "un", "du", "pour", "dans", "que", "qui", "pas", "sur", "avec", "au", "ce"},
+	"es": {"el", "los", "las", "y", "es", "que", "una", "por", "para", "con", "del", "se", "no", "como", "pero", "su", "al", "lo"},
+	"it": {"il", "di", "che", "è", "e", "per", "una", "sono", "della", "con", "non", "gli", "anche", "nel", "come", "ma", "più", "lo"},
+	"pt": {"o", "os", "que", "e", "é", "um", "uma", "não", "para", "com", "do", "da", "em", "por", "mais", "as", "se", "na"},
+	"nl": {"de", "het", "een", "en", "van", "is", "dat", "niet", "op", "te", "zijn", "met", "voor", "ik", "je", "ook", "maar", "wat"},
+	"pl": {"i", "w", "nie", "na", "się", "jest", "to", "że", "z", "do", "jak", "ale", "co", "tak", "od", "po", "jego", "przez"},
+	"tr": {"ve", "bir", "bu", "da", "de", "için", "ile", "çok", "ne", "daha", "gibi", "olarak", "ama", "var", "değil", "olan", "sonra", "kadar"},
+	"id": {"yang", "dan", "di", "ini", "itu", "dengan", "untuk", "tidak", "dari", "dalam", "akan", "pada", "juga", "ada", "ke", "kami", "bisa", "saya"},
+}
+
+// setLanguageAttr sets the detected language attribute unless it's already set, e.g. by the channel settings.
+func setLanguageAttr(evt *pb.CloudEvent) {
+	if lang := detectLanguage(evt.GetTextData()); lang != "" {
+		setStringAttrIfMissing(evt, model.CeKeyLanguage, lang)
+	}
+}
+
+var langStopWordsIdx = func() (idx map[string][]string) {
+	idx = map[string][]string{}
+	for lang, words := range langStopWords {
+		for _, w := range words {
+			idx[w] = append(idx[w], lang)
+		}
+	}
+	return
+}()
+
+// detectLanguage returns the ISO 639-1 code of the text language or empty string when not sure. The script is detected
+// by the letters first, the languages sharing the Latin script are told apart by the stop words.
+func detectLanguage(txt string) (lang string) {
+	var letters int
+	scripts := map[string]int{}
+	for _, r := range txt {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			scripts["latin"]++
+		case unicode.Is(unicode.Cyrillic, r):
+			scripts["cyrillic"]++
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			scripts["ja"]++
+		case unicode.Is(unicode.Hangul, r):
+			scripts["ko"]++
+		case unicode.Is(unicode.Han, r):
+			scripts["zh"]++
+		case unicode.Is(unicode.Arabic, r):
+			scripts["arabic"]++
+		case unicode.Is(unicode.Hebrew, r):
+			scripts["he"]++
+		case unicode.Is(unicode.Greek, r):
+			scripts["el"]++
+		case unicode.Is(unicode.Devanagari, r):
+			scripts["hi"]++
+		case unicode.Is(unicode.Thai, r):
+			scripts["th"]++
+		case unicode.Is(unicode.Armenian, r):
+			scripts["hy"]++
+		case unicode.Is(unicode.Georgian, r):
+			scripts["ka"]++
+		}
+	}
+	if letters < langLettersMin {
+		return
+	}
+	var script string
+	var scriptCount int
+	for s, c := range scripts {
+		if c > scriptCount || (c == scriptCount && s < script) {
+			script, scriptCount = s, c
+		}
+	}
+	if scriptCount*2 < letters {
+		return // no dominant script
+	}
+	switch script {
+	case "latin":
+		lang = detectLanguageLatin(txt)
+	case "cyrillic":
+		lang = detectLanguageCyrillic(txt)
+	case "arabic":
+		lang = "ar"
+		if strings.ContainsAny(txt, "پچژگ") {
+			lang = "fa"
+		}
+	case "zh":
+		if scripts["ja"] > 0 {
+			lang = "ja" // kanji along with kana
+		} else {
+			lang = "zh"
+		}
+	default:
+		lang = script
+	}
+	return
+}
+
+func detectLanguageLatin(txt string) (lang string) {
+	scores := map[string]int{}
+	for _, f := range strings.Fields(strings.ToLower(txt)) {
+		if strings.Contains(f, "://") || strings.HasPrefix(f, "@") || strings.HasPrefix(f, "#") {
+			continue // links, mentions and tags are not the words
+		}
+		words := strings.FieldsFunc(f, func(r rune) bool {
+			return !unicode.IsLetter(r)
+		})
+		for _, w := range words {
+			for _, l := range langStopWordsIdx[w] {
+				scores[l]++
+			}
+		}
+	}
+	var scoreTop int
+	for l, score := range scores {
+		switch {
+		case score > scoreTop:
+			lang, scoreTop = l, score
+		case score == scoreTop:
+			lang = "" // ambiguous unless a higher score follows
+		}
+	}
+	if scoreTop < langStopWordsMin {
+		lang = ""
+	}
+	return
+}
+
+func detectLanguageCyrillic(txt string) (lang string) {
+	t := strings.ToLower(txt)
+	switch {
+	case strings.ContainsAny(t, "әғқңөұ"):
+		lang = "kk"
+	case strings.ContainsRune(t, 'ў'):
+		lang = "be"
+	case strings.ContainsAny(t, "їєґ"), strings.ContainsRune(t, 'і') && !strings.ContainsAny(t, "ыэё"):
+		lang = "uk"
+	case strings.ContainsAny(t, "јљњћђџ"):
+		lang = "sr"
+	case strings.ContainsRune(t, 'ъ') && !strings.ContainsAny(t, "ыэё"):
+		lang = "bg"
+	default:
+		lang = "ru"
+	}
+	return
+}
@@ -0,0 +1,73 @@
+package messages
+
+import (
+	"github.com/awakari/bot-telegram/model"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	cases := map[string]struct {
+		txt  string
+		lang string
+	}{
+		"en": {
+			txt:  "The launch of the new rocket is scheduled for this week and it is expected to be visible",
+			lang: "en",
+		},
+		"de": {
+			txt:  "Die Regierung hat sich auf den neuen Haushalt geeinigt, und das ist nicht das Ende",
+			lang: "de",
+		},
+		"es": {
+			txt:  "El gobierno anunció que los precios de la energía bajarán para las familias",
+			lang: "es",
+		},
+		"fr": {
+			txt:  "Le gouvernement a annoncé que les prix de l'énergie vont baisser pour les familles",
+			lang: "fr",
+		},
+		"ru": {
+			txt:  "Правительство объявило о снижении цен на электроэнергию для семей",
+			lang: "ru",
+		},
+		"uk": {
+			txt:  "Уряд оголосив про зниження цін на електроенергію для сімей",
+			lang: "uk",
+		},
+		"ja": {
+			txt:  "政府は家庭向けの電気料金を引き下げると発表しました",
+			lang: "ja",
+		},
+		"zh": {
+			txt:  "政府宣布将降低家庭的电力价格以减轻负担",
+			lang: "zh",
+		},
+		"too short": {
+			txt: "Hello world",
+		},
+		"no stop words": {
+			txt: "Bitcoin Ethereum Solana Cardano Polkadot",
+		},
+		"links only": {
+			txt: "https://example.com/foo https://example.com/bar",
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			assert.Equal(t, c.lang, detectLanguage(c.txt))
+		})
+	}
+}
+
+func TestSetLanguageAttr(t *testing.T) {
+	evt := &pb.CloudEvent{
+		Data: &pb.CloudEvent_TextData{
+			TextData: "Правительство объявило о снижении цен на электроэнергию для семей",
+		},
+	}
+	ChannelSettings{Language: "be"}.apply(evt)
+	setLanguageAttr(evt)
+	assert.Equal(t, "be", evt.Attributes[model.CeKeyLanguage].GetCeString())
+}
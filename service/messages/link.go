@@ -0,0 +1,60 @@
+package messages
+
+import (
+	"context"
+	"github.com/awakari/bot-telegram/model"
+	"github.com/awakari/bot-telegram/service/unfurl"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"gopkg.in/telebot.v3"
+	"strings"
+)
+
+const ceKeyImageUrl = "imageurl"
+
+// enrichLink sets the object url, title and summary attributes from the preview of the first link in the message.
+// The failure to unfurl the link is not an error: the message is published as is.
+func enrichLink(ctx context.Context, svcUnfurl unfurl.Service, msg *telebot.Message, evt *pb.CloudEvent) {
+	if svcUnfurl == nil {
+		return
+	}
+	addr := firstLink(msg)
+	if addr == "" {
+		return
+	}
+	p, err := svcUnfurl.Unfurl(ctx, addr)
+	if err != nil {
+		return
+	}
+	setStringAttrIfMissing(evt, model.CeKeyObjectUrl, p.Url)
+	if p.Title != "" {
+		setStringAttrIfMissing(evt, model.CeKeyTitle, truncateStringUtf8(p.Title, titleLenMax))
+	}
+	if p.Description != "" {
+		setStringAttrIfMissing(evt, model.CeKeySummary, truncateStringUtf8(p.Description, fmtLenMaxBodyTxt))
+	}
+	if strings.HasPrefix(p.Image, "https://") {
+		setStringAttrIfMissing(evt, ceKeyImageUrl, p.Image)
+	}
+}
+
+func firstLink(msg *telebot.Message) (addr string) {
+	entities := msg.Entities
+	if msg.Text == "" {
+		entities = msg.CaptionEntities
+	}
+	for _, e := range entities {
+		switch e.Type {
+		case telebot.EntityURL:
+			addr = msg.EntityText(e)
+		case telebot.EntityTextLink:
+			addr = e.URL
+		default:
+			continue
+		}
+		if !strings.HasPrefix(addr, "https://") && !strings.HasPrefix(addr, "http://") {
+			addr = "https://" + addr // telegram recognizes the links without the scheme
+		}
+		break
+	}
+	return
+}
@@ -0,0 +1,91 @@
+package messages
+
+import (
+	"context"
+	"github.com/awakari/bot-telegram/model"
+	"github.com/awakari/bot-telegram/service/unfurl"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/telebot.v3"
+	"testing"
+)
+
+func TestEnrichLink(t *testing.T) {
+	svcUnfurl := unfurl.NewMock(map[string]unfurl.Preview{
+		"https://example.com/article": {
+			Url:         "https://example.com/article",
+			Title:       "Article title",
+			Description: "Article description",
+			Image:       "https://example.com/img.png",
+		},
+	})
+	cases := map[string]struct {
+		svc   unfurl.Service
+		msg   *telebot.Message
+		attrs map[string]string
+	}{
+		"url without scheme": {
+			svc: svcUnfurl,
+			msg: &telebot.Message{
+				Text: "worth reading example.com/article",
+				Entities: telebot.Entities{
+					{Type: telebot.EntityURL, Offset: 14, Length: 19},
+				},
+			},
+			attrs: map[string]string{
+				model.CeKeyObjectUrl: "https://example.com/article",
+				model.CeKeyTitle:     "Article title",
+				model.CeKeySummary:   "Article description",
+				ceKeyImageUrl:        "https://example.com/img.png",
+			},
+		},
+		"text link in caption": {
+			svc: svcUnfurl,
+			msg: &telebot.Message{
+				Caption: "worth reading",
+				CaptionEntities: telebot.Entities{
+					{Type: telebot.EntityBold, Offset: 0, Length: 5},
+					{Type: telebot.EntityTextLink, Offset: 6, Length: 7, URL: "https://example.com/article"},
+				},
+			},
+			attrs: map[string]string{
+				model.CeKeyObjectUrl: "https://example.com/article",
+				model.CeKeyTitle:     "Article title",
+				model.CeKeySummary:   "Article description",
+				ceKeyImageUrl:        "https://example.com/img.png",
+			},
+		},
+		"unfurl fails": {
+			svc: svcUnfurl,
+			msg: &telebot.Message{
+				Text: "https://example.com/missing",
+				Entities: telebot.Entities{
+					{Type: telebot.EntityURL, Offset: 0, Length: 27},
+				},
+			},
+			attrs: map[string]string{},
+		},
+		"disabled": {
+			msg: &telebot.Message{
+				Text: "example.com/article",
+				Entities: telebot.Entities{
+					{Type: telebot.EntityURL, Offset: 0, Length: 19},
+				},
+			},
+			attrs: map[string]string{},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			evt := &pb.CloudEvent{
+				Attributes: map[string]*pb.CloudEventAttributeValue{},
+			}
+			enrichLink(context.TODO(), c.svc, c.msg, evt)
+			attrs := map[string]string{}
+			for k, v := range evt.Attributes {
+				attrs[k] = v.GetCeString()
+			}
+			assert.Equal(t, c.attrs, attrs)
+		})
+	}
+}
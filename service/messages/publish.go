@@ -8,6 +8,7 @@ import (
 	"github.com/awakari/bot-telegram/config"
 	"github.com/awakari/bot-telegram/model"
 	"github.com/awakari/bot-telegram/service"
+	"github.com/awakari/bot-telegram/service/unfurl"
 	"github.com/awakari/bot-telegram/util"
 	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
 	"github.com/segmentio/ksuid"
@@ -23,7 +24,6 @@ const ceKeyTgMessageId = "tgmessageid"
 const attrValSpecVersion = "1.0"
 const msgBusy = "Busy, please retry later"
 const msgFmtPublished = "Message published, id: <pre>%s</pre>"
-const msgLimitReached = "Message daily publishing limit reached. Payment is required to proceed. The message is being kept for 1 week"
 const msgFmtPublishMissing = "message to publish is missing: %s"
 const msgFmtRunOnceFailed = "failed to publish event: %s, cause: %s, retrying in: %s"
 
@@ -43,34 +43,66 @@ var publishBasicMarkup = &telebot.ReplyMarkup{
 }
 
 func PublishBasicRequest(tgCtx telebot.Context) (err error) {
-	_ = tgCtx.Send("Reply with your message to publish:")
+	_ = tgCtx.Send("Reply with your message to publish. "+msgAttrsHeaderUsage, telebot.ModeHTML)
 	err = tgCtx.Send(ReqMsgPub, publishBasicMarkup)
 	return
 }
 
 func PublishBasicReplyHandlerFunc(
 	svcPub pub.Service,
+	limitReached service.LimitReached,
+	svcUnfurl unfurl.Service,
 	groupId string,
 	cfg config.Config,
 ) service.ArgHandlerFunc {
 	return func(tgCtx telebot.Context, args ...string) (err error) {
 		userId := util.SenderToUserId(tgCtx)
-		evt := pb.CloudEvent{
-			Id:          ksuid.New().String(),
-			Source:      "https://t.me/" + tgCtx.Chat().Username,
-			SpecVersion: attrValSpecVersion,
-			Type:        cfg.Api.Messages.Type,
-		}
-		if err == nil {
-			err = toCloudEvent(tgCtx.Message(), args[1], &evt)
-		}
+		var evt *pb.CloudEvent
+		evt, err = newUserEvent(tgCtx, args[len(args)-1], svcUnfurl, cfg)
 		if err == nil {
-			err = publish(tgCtx, svcPub, &evt, groupId, userId)
+			err = publish(tgCtx, svcPub, limitReached, evt, groupId, userId)
 		}
 		return
 	}
 }
 
+// newUserEvent converts the message sent by user to the event with the attributes extracted from the message.
+func newUserEvent(tgCtx telebot.Context, txt string, svcUnfurl unfurl.Service, cfg config.Config) (evt *pb.CloudEvent, err error) {
+	evt = &pb.CloudEvent{
+		Id:          ksuid.New().String(),
+		Source:      "https://t.me/" + tgCtx.Chat().Username,
+		SpecVersion: attrValSpecVersion,
+		Type:        cfg.Api.Messages.Type,
+	}
+	msg := tgCtx.Message()
+	if txt == "" {
+		txt = msg.Caption
+	}
+	var attrs map[string]*pb.CloudEventAttributeValue
+	attrs, txt, err = parseAttrsHeader(txt)
+	if err == nil {
+		err = toCloudEvent(msg, txt, evt)
+	}
+	if err == nil && attrs != nil {
+		evt.Data = nil // the caption may still contain the attributes header
+		if txt != "" {
+			evt.Data = &pb.CloudEvent_TextData{
+				TextData: txt,
+			}
+		}
+		for k, v := range attrs {
+			evt.Attributes[k] = v
+		}
+	}
+	if err == nil {
+		enrichLink(context.TODO(), svcUnfurl, msg, evt)
+		// the entities offsets don't match the text without the attributes header
+		extractTextAttrs(msg, evt, cfg.Api.Messages.TagsStrip && attrs == nil)
+		setLanguageAttr(evt)
+	}
+	return
+}
+
 func toCloudEvent(msg *telebot.Message, txt string, evt *pb.CloudEvent) (err error) {
 	evt.Attributes = map[string]*pb.CloudEventAttributeValue{
 		ceKeyTgMessageId: {
@@ -188,13 +220,14 @@ func toCloudEvent(msg *telebot.Message, txt string, evt *pb.CloudEvent) (err err
 func publish(
 	tgCtx telebot.Context,
 	svcPub pub.Service,
+	limitReached service.LimitReached,
 	evt *pb.CloudEvent,
 	groupId, userId string,
 ) (err error) {
 	err = svcPub.Publish(context.TODO(), evt, groupId, userId)
 	switch {
 	case errors.Is(err, pub.ErrLimitReached):
-		err = errors.New(fmt.Sprintf("Message daily publishing limit reached. Consider to increase."))
+		err = limitReached.SendPublish(tgCtx, userId)
 	default:
 		err = tgCtx.Send(fmt.Sprintf(msgFmtPublished, html.EscapeString(evt.Id)), telebot.ModeHTML)
 	}
@@ -0,0 +1,452 @@
+package messages
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/awakari/bot-telegram/api/http/pub"
+	"github.com/awakari/bot-telegram/config"
+	"github.com/awakari/bot-telegram/model"
+	"github.com/awakari/bot-telegram/model/usage"
+	"github.com/awakari/bot-telegram/service"
+	"github.com/awakari/bot-telegram/service/storage"
+	"github.com/awakari/bot-telegram/service/unfurl"
+	"github.com/awakari/bot-telegram/util"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/segmentio/ksuid"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gopkg.in/telebot.v3"
+	"html"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Scheduled is the message to publish later on behalf of the user, optionally repeated with the period.
+type Scheduled struct {
+	Event    string        `json:"event"`
+	GroupId  string        `json:"groupId"`
+	UserId   string        `json:"userId"`
+	ChatId   int64         `json:"chatId"`
+	Next     time.Time     `json:"next"`
+	Period   time.Duration `json:"period,omitempty"`
+	Attempts int           `json:"attempts,omitempty"`
+}
+
+const CmdPubSchedule = "pub_sched"
+const CmdPubCancel = "pub_cancel"
+const ReqMsgPubTime = "msg_pub_time"
+const ReqMsgPubAt = "msg_pub_at"
+
+const schedNow = "now"
+const schedHour = "1h"
+const schedAt = "at"
+const schedDaily = "daily"
+const schedWeekly = "weekly"
+const schedCountMax = 10
+const schedAheadMax = 365 * 24 * time.Hour
+const schedRetryDelay = 10 * time.Minute
+const schedAttemptsMax = 6
+const schedTimeLayout = "2006-01-02 15:04"
+const schedTimeOfDayLayout = "15:04"
+const schedPeriodDaily = 24 * time.Hour
+const schedPeriodWeekly = 7 * schedPeriodDaily
+const schedPreviewLenMax = 40
+
+var errSchedule = errors.New("invalid schedule")
+
+// PublishRequestHandlerFunc handles the "/pub" command offering to choose when to publish the message. The user is
+// warned first when close to the publishing limits.
+func PublishRequestHandlerFunc(u service.Usage) telebot.HandlerFunc {
+	return func(tgCtx telebot.Context) (err error) {
+		warn := u.Warning(
+			context.TODO(), util.SenderToUserId(tgCtx), time.Now().UTC(),
+			usage.SubjectPublishHourly, usage.SubjectPublishDaily,
+		)
+		if warn != "" {
+			_ = tgCtx.Send(warn)
+		}
+		err = publishRequest(tgCtx)
+		return
+	}
+}
+
+func publishRequest(tgCtx telebot.Context) (err error) {
+	m := &telebot.ReplyMarkup{}
+	m.Inline(
+		m.Row(
+			telebot.Btn{
+				Text: "Now",
+				Data: CmdPubSchedule + " " + schedNow,
+			},
+			telebot.Btn{
+				Text: "In 1 hour",
+				Data: CmdPubSchedule + " " + schedHour,
+			},
+			telebot.Btn{
+				Text: "At time...",
+				Data: CmdPubSchedule + " " + schedAt,
+			},
+		),
+		m.Row(
+			telebot.Btn{
+				Text: "🔁 Daily",
+				Data: CmdPubSchedule + " " + schedDaily,
+			},
+			telebot.Btn{
+				Text: "🔁 Weekly",
+				Data: CmdPubSchedule + " " + schedWeekly,
+			},
+		),
+	)
+	err = tgCtx.Send("When to publish the message?\nUse /scheduled to list and cancel the scheduled messages.", m)
+	return
+}
+
+// PublishSchedule handles the "/pub" options and requests the message to publish.
+func PublishSchedule(tgCtx telebot.Context, args ...string) (err error) {
+	if len(args) != 1 {
+		err = fmt.Errorf("%w: %+v", errSchedule, args)
+		return
+	}
+	now := time.Now().UTC()
+	switch args[0] {
+	case schedNow:
+		err = PublishBasicRequest(tgCtx)
+	case schedHour:
+		err = publishScheduledRequest(tgCtx, now.Add(time.Hour), 0)
+	case schedDaily:
+		err = publishTimeRequest(tgCtx, "the time of day", now.Format(schedTimeOfDayLayout), schedPeriodDaily)
+	case schedWeekly:
+		err = publishTimeRequest(tgCtx, "the weekday and time", now.Format("Monday "+schedTimeOfDayLayout), schedPeriodWeekly)
+	case schedAt:
+		err = publishTimeRequest(tgCtx, "the time", now.Add(time.Hour).Format(schedTimeLayout), 0)
+	default:
+		err = fmt.Errorf("%w: unknown option %q", errSchedule, args[0])
+	}
+	return
+}
+
+func publishTimeRequest(tgCtx telebot.Context, what, example string, period time.Duration) (err error) {
+	_ = tgCtx.Send(fmt.Sprintf("Reply with %s in UTC to publish at, for example: <code>%s</code>", what, example), telebot.ModeHTML)
+	err = tgCtx.Send(fmt.Sprintf("%s %s", ReqMsgPubTime, period), &telebot.ReplyMarkup{
+		ForceReply:  true,
+		Placeholder: example,
+	})
+	return
+}
+
+// PublishTimeReplyHandlerFunc handles the time reply and requests the message to publish at this time. For the
+// repeated publishing, the time of day (and the weekday) is expected instead of the exact time.
+func PublishTimeReplyHandlerFunc(tgCtx telebot.Context, args ...string) (err error) {
+	var period time.Duration
+	if len(args) > 2 {
+		period, err = time.ParseDuration(args[1])
+	}
+	var at time.Time
+	if err == nil {
+		at, err = parseScheduleTime(time.Now().UTC(), strings.TrimSpace(args[len(args)-1]), period)
+	}
+	if err == nil {
+		err = publishScheduledRequest(tgCtx, at, period)
+	}
+	return
+}
+
+// parseScheduleTime returns the first publishing time: the exact time for the single publishing, the next time of day
+// for the daily one and the next weekday and time for the weekly one.
+func parseScheduleTime(now time.Time, txt string, period time.Duration) (at time.Time, err error) {
+	switch period {
+	case 0:
+		at, err = time.Parse(schedTimeLayout, txt)
+		if err != nil {
+			at, err = time.Parse(time.RFC3339, txt)
+		}
+		switch {
+		case err != nil:
+			err = fmt.Errorf("%w: expected the time like %s", errSchedule, now.Format(schedTimeLayout))
+		case !at.After(now):
+			err = fmt.Errorf("%w: the time is in the past", errSchedule)
+		case at.Sub(now) > schedAheadMax:
+			err = fmt.Errorf("%w: the time is more than a year ahead", errSchedule)
+		}
+		at = at.UTC()
+	case schedPeriodDaily:
+		var tod time.Time
+		tod, err = time.Parse(schedTimeOfDayLayout, txt)
+		switch err {
+		case nil:
+			at = nextTimeOfDay(now, tod, 0, period)
+		default:
+			err = fmt.Errorf("%w: expected the time of day like %s", errSchedule, now.Format(schedTimeOfDayLayout))
+		}
+	case schedPeriodWeekly:
+		weekday, todTxt, _ := strings.Cut(txt, " ")
+		var tod time.Time
+		tod, err = time.Parse(schedTimeOfDayLayout, strings.TrimSpace(todTxt))
+		var days int
+		if err == nil {
+			days, err = daysToWeekday(now.Weekday(), weekday)
+		}
+		switch err {
+		case nil:
+			at = nextTimeOfDay(now, tod, days, period)
+		default:
+			err = fmt.Errorf("%w: expected the weekday and time like %s", errSchedule, now.Format("Monday "+schedTimeOfDayLayout))
+		}
+	default:
+		err = fmt.Errorf("%w: unsupported period %s", errSchedule, period)
+	}
+	return
+}
+
+// nextTimeOfDay returns the time of day in the specified days count after now, one period later when it's passed.
+func nextTimeOfDay(now, tod time.Time, days int, period time.Duration) (at time.Time) {
+	at = time.Date(now.Year(), now.Month(), now.Day()+days, tod.Hour(), tod.Minute(), 0, 0, time.UTC)
+	if !at.After(now) {
+		at = at.Add(period)
+	}
+	return
+}
+
+func daysToWeekday(from time.Weekday, name string) (days int, err error) {
+	name = strings.ToLower(name)
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		full := strings.ToLower(d.String())
+		if name != "" && (name == full || name == full[:3]) {
+			days = (int(d) - int(from) + 7) % 7
+			return
+		}
+	}
+	err = fmt.Errorf("%w: unknown weekday %q", errSchedule, name)
+	return
+}
+
+func publishScheduledRequest(tgCtx telebot.Context, at time.Time, period time.Duration) (err error) {
+	_ = tgCtx.Send(fmt.Sprintf("Reply with your message to publish %s. %s", fmtSchedule(at, period), msgAttrsHeaderUsage), telebot.ModeHTML)
+	err = tgCtx.Send(fmt.Sprintf("%s %s %s", ReqMsgPubAt, at.Format(time.RFC3339), period), publishBasicMarkup)
+	return
+}
+
+// PublishScheduledReplyHandlerFunc stores the replied message to publish it later by the Scheduler.
+func PublishScheduledReplyHandlerFunc(
+	schedule storage.Storage[Scheduled],
+	svcUnfurl unfurl.Service,
+	groupId string,
+	cfg config.Config,
+) service.ArgHandlerFunc {
+	return func(tgCtx telebot.Context, args ...string) (err error) {
+		if len(args) < 4 {
+			err = fmt.Errorf("%w: %+v", errSchedule, args)
+			return
+		}
+		userId := util.SenderToUserId(tgCtx)
+		var at time.Time
+		at, err = time.Parse(time.RFC3339, args[1])
+		var period time.Duration
+		if err == nil {
+			period, err = time.ParseDuration(args[2])
+		}
+		if err == nil && schedCount(schedule, userId) >= schedCountMax {
+			err = fmt.Errorf("%w: at most %d scheduled messages are allowed, use /scheduled to cancel some", errSchedule, schedCountMax)
+		}
+		var evt *pb.CloudEvent
+		if err == nil {
+			evt, err = newUserEvent(tgCtx, args[len(args)-1], svcUnfurl, cfg)
+		}
+		var data []byte
+		if err == nil {
+			data, err = protojson.Marshal(evt)
+		}
+		if err == nil {
+			err = schedule.Set(ksuid.New().String(), Scheduled{
+				Event:   string(data),
+				GroupId: groupId,
+				UserId:  userId,
+				ChatId:  tgCtx.Chat().ID,
+				Next:    at.UTC(),
+				Period:  period,
+			})
+		}
+		if err == nil {
+			err = tgCtx.Send(fmt.Sprintf("Message scheduled to publish %s. Use /scheduled to list or cancel.", fmtSchedule(at, period)))
+		}
+		return
+	}
+}
+
+// ScheduledListHandlerFunc handles the "/scheduled" command listing the user's scheduled messages.
+func ScheduledListHandlerFunc(schedule storage.Storage[Scheduled]) telebot.HandlerFunc {
+	return func(tgCtx telebot.Context) error {
+		txt, m := renderScheduled(schedule, util.SenderToUserId(tgCtx))
+		return tgCtx.Send(txt, m, telebot.ModeHTML)
+	}
+}
+
+// ScheduledCancel handles the scheduled message cancel button.
+func ScheduledCancel(schedule storage.Storage[Scheduled]) service.ArgHandlerFunc {
+	return func(tgCtx telebot.Context, args ...string) (err error) {
+		if len(args) != 1 {
+			err = fmt.Errorf("%w: %+v", errSchedule, args)
+			return
+		}
+		userId := util.SenderToUserId(tgCtx)
+		s, found := schedule.Get(args[0])
+		if found && s.UserId == userId {
+			err = schedule.Delete(args[0])
+		}
+		if err == nil {
+			txt, m := renderScheduled(schedule, userId)
+			err = tgCtx.Edit(txt, m, telebot.ModeHTML)
+		}
+		return
+	}
+}
+
+func schedCount(schedule storage.Storage[Scheduled], userId string) (n int) {
+	schedule.Each(func(_ string, s Scheduled) bool {
+		if s.UserId == userId {
+			n++
+		}
+		return true
+	})
+	return
+}
+
+func renderScheduled(schedule storage.Storage[Scheduled], userId string) (txt string, m *telebot.ReplyMarkup) {
+	m = &telebot.ReplyMarkup{}
+	var rows []telebot.Row
+	schedule.Each(func(id string, s Scheduled) bool {
+		if s.UserId != userId {
+			return true
+		}
+		n := len(rows) + 1
+		txt += fmt.Sprintf("%d. %s: %s\n", n, fmtSchedule(s.Next, s.Period), html.EscapeString(s.preview()))
+		rows = append(rows, m.Row(telebot.Btn{
+			Text: fmt.Sprintf("❌ Cancel %d", n),
+			Data: CmdPubCancel + " " + id,
+		}))
+		return true
+	})
+	switch len(rows) {
+	case 0:
+		txt = "No scheduled messages. Use /pub to schedule one."
+	default:
+		txt = "Scheduled messages:\n" + txt
+		m.Inline(rows...)
+	}
+	return
+}
+
+func (s Scheduled) preview() (txt string) {
+	var evt pb.CloudEvent
+	_ = protojson.Unmarshal([]byte(s.Event), &evt)
+	txt = strings.Join(strings.Fields(evt.GetTextData()), " ")
+	switch {
+	case txt == "":
+		txt = "(media)"
+	case len([]rune(txt)) > schedPreviewLenMax:
+		txt = string([]rune(txt)[:schedPreviewLenMax]) + "..."
+	}
+	return
+}
+
+func fmtSchedule(at time.Time, period time.Duration) (txt string) {
+	switch period {
+	case 0:
+		txt = "at " + at.UTC().Format(schedTimeLayout) + " UTC"
+	case 24 * time.Hour:
+		txt = "daily at " + at.UTC().Format("15:04") + " UTC"
+	case 7 * 24 * time.Hour:
+		txt = "weekly on " + at.UTC().Format("Monday 15:04") + " UTC"
+	default:
+		txt = fmt.Sprintf("every %s starting %s UTC", period, at.UTC().Format(schedTimeLayout))
+	}
+	return
+}
+
+// Scheduler publishes the scheduled messages when due with the users' own ids.
+type Scheduler struct {
+	SvcPub   pub.Service
+	Schedule storage.Storage[Scheduled]
+	Bot      service.Sender
+	Interval time.Duration
+	Log      *slog.Logger
+}
+
+func (sc Scheduler) Run(ctx context.Context) {
+	t := time.NewTicker(sc.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			sc.publishDue(ctx, time.Now().UTC())
+		}
+	}
+}
+
+func (sc Scheduler) publishDue(ctx context.Context, now time.Time) {
+	sc.Schedule.Each(func(id string, s Scheduled) bool {
+		if s.Next.After(now) {
+			return true
+		}
+		var evt pb.CloudEvent
+		err := protojson.Unmarshal([]byte(s.Event), &evt)
+		if err == nil {
+			evt.Id = ksuid.New().String() // the repeated message is a new event every time
+			if evt.Attributes == nil {
+				evt.Attributes = map[string]*pb.CloudEventAttributeValue{}
+			}
+			evt.Attributes[model.CeKeyTime] = &pb.CloudEventAttributeValue{
+				Attr: &pb.CloudEventAttributeValue_CeTimestamp{
+					CeTimestamp: timestamppb.New(now),
+				},
+			}
+			err = sc.SvcPub.Publish(ctx, &evt, s.GroupId, s.UserId)
+		}
+		var msg string
+		switch {
+		case err == nil:
+			msg = fmt.Sprintf("Scheduled message published, id: <pre>%s</pre>", evt.Id)
+			s.Attempts = 0
+			err = sc.next(id, s, now)
+		case s.Period > 0:
+			msg = fmt.Sprintf("Failed to publish the scheduled message, skipping until the next time, cause: %s", html.EscapeString(err.Error()))
+			s.Attempts = 0
+			err = sc.next(id, s, now)
+		case errors.Is(err, pub.ErrInvalid), errors.Is(err, pub.ErrNoAuth), s.Attempts+1 >= schedAttemptsMax:
+			msg = fmt.Sprintf("Failed to publish the scheduled message, cancelled, cause: %s", html.EscapeString(err.Error()))
+			err = sc.Schedule.Delete(id)
+		default:
+			msg = fmt.Sprintf("Failed to publish the scheduled message, retrying in %s, cause: %s", schedRetryDelay, html.EscapeString(err.Error()))
+			s.Attempts++
+			s.Next = now.Add(schedRetryDelay)
+			err = sc.Schedule.Set(id, s)
+		}
+		if err != nil {
+			sc.Log.Error(fmt.Sprintf("Scheduled message %s update failure: %s", id, err))
+		}
+		_, err = sc.Bot.Send(telebot.ChatID(s.ChatId), msg, telebot.ModeHTML)
+		if err != nil {
+			sc.Log.Warn(fmt.Sprintf("Failed to notify the chat %d about the scheduled message %s: %s", s.ChatId, id, err))
+		}
+		return ctx.Err() == nil
+	})
+}
+
+// next either removes the one-time message or moves the repeated message to the next future time.
+func (sc Scheduler) next(id string, s Scheduled, now time.Time) (err error) {
+	switch s.Period {
+	case 0:
+		err = sc.Schedule.Delete(id)
+	default:
+		for !s.Next.After(now) {
+			s.Next = s.Next.Add(s.Period)
+		}
+		err = sc.Schedule.Set(id, s)
+	}
+	return
+}
@@ -0,0 +1,260 @@
+package messages
+
+import (
+	"context"
+	"fmt"
+	"github.com/awakari/bot-telegram/api/http/pub"
+	"github.com/awakari/bot-telegram/service/storage"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protojson"
+	"gopkg.in/telebot.v3"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type senderStub struct {
+	msgs *[]string
+}
+
+func (ss senderStub) Send(to telebot.Recipient, what interface{}, opts ...interface{}) (*telebot.Message, error) {
+	*ss.msgs = append(*ss.msgs, to.Recipient()+": "+what.(string))
+	return nil, nil
+}
+
+type pubFailStub struct {
+	err error
+}
+
+func (ps pubFailStub) Publish(ctx context.Context, evt *pb.CloudEvent, groupId, userId string) error {
+	return ps.err
+}
+
+func (ps pubFailStub) PublishBatch(ctx context.Context, evts []*pb.CloudEvent, groupId, userId string) (uint32, error) {
+	return 0, ps.err
+}
+
+func TestScheduler_PublishDue(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	data, err := protojson.Marshal(&pb.CloudEvent{
+		Id:          "evt0",
+		Source:      "https://t.me/",
+		SpecVersion: attrValSpecVersion,
+		Type:        "type0",
+		Attributes:  map[string]*pb.CloudEventAttributeValue{},
+		Data: &pb.CloudEvent_TextData{
+			TextData: "foo",
+		},
+	})
+	assert.Nil(t, err)
+	cases := map[string]struct {
+		svcPub  pub.Service
+		in      Scheduled
+		found   bool
+		next    time.Time
+		publish bool
+		notify  string
+	}{
+		"not due": {
+			in: Scheduled{
+				Next: now.Add(time.Minute),
+			},
+			found: true,
+			next:  now.Add(time.Minute),
+		},
+		"once": {
+			in: Scheduled{
+				Next: now.Add(-time.Minute),
+			},
+			publish: true,
+			notify:  "published",
+		},
+		"daily": {
+			in: Scheduled{
+				Next:   now.Add(-49 * time.Hour),
+				Period: 24 * time.Hour,
+			},
+			found:   true,
+			next:    now.Add(23 * time.Hour),
+			publish: true,
+			notify:  "published",
+		},
+		"retry": {
+			svcPub: pubFailStub{err: pub.ErrLimitReached},
+			in: Scheduled{
+				Next: now.Add(-time.Minute),
+			},
+			found:  true,
+			next:   now.Add(schedRetryDelay),
+			notify: "retrying",
+		},
+		"give up": {
+			svcPub: pubFailStub{err: pub.ErrNoAck},
+			in: Scheduled{
+				Next:     now.Add(-time.Minute),
+				Attempts: schedAttemptsMax - 1,
+			},
+			notify: "cancelled",
+		},
+		"invalid": {
+			svcPub: pubFailStub{err: pub.ErrInvalid},
+			in: Scheduled{
+				Next: now.Add(-time.Minute),
+			},
+			notify: "cancelled",
+		},
+		"escaped cause": {
+			svcPub: pubFailStub{err: fmt.Errorf("%w: <evt0>", pub.ErrInvalid)},
+			in: Scheduled{
+				Next: now.Add(-time.Minute),
+			},
+			notify: "cause: invalid request: &lt;evt0&gt;",
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			schedule, err := storage.NewFile[Scheduled](filepath.Join(t.TempDir(), "scheduled.json"))
+			assert.Nil(t, err)
+			c.in.Event = string(data)
+			c.in.ChatId = 42
+			assert.Nil(t, schedule.Set("sched0", c.in))
+			chEvt := make(chan *pb.CloudEvent, 1)
+			svcPub := c.svcPub
+			if svcPub == nil {
+				svcPub = pub.NewMock(chEvt)
+			}
+			var msgs []string
+			sc := Scheduler{
+				SvcPub:   svcPub,
+				Schedule: schedule,
+				Bot:      senderStub{msgs: &msgs},
+				Log:      slog.New(slog.NewTextHandler(os.Stdout, nil)),
+			}
+			sc.publishDue(context.TODO(), now)
+			s, found := schedule.Get("sched0")
+			assert.Equal(t, c.found, found)
+			if c.found {
+				assert.Equal(t, c.next, s.Next)
+			}
+			assert.Equal(t, c.publish, len(chEvt) == 1)
+			if c.publish {
+				evt := <-chEvt
+				assert.NotEqual(t, "evt0", evt.Id)
+				assert.Equal(t, now, evt.Attributes["time"].GetCeTimestamp().AsTime())
+			}
+			switch c.notify {
+			case "":
+				assert.Empty(t, msgs)
+			default:
+				assert.Len(t, msgs, 1)
+				assert.True(t, strings.HasPrefix(msgs[0], "42: "))
+				assert.Contains(t, msgs[0], c.notify)
+			}
+		})
+	}
+}
+
+func TestRenderScheduled(t *testing.T) {
+	schedule, err := storage.NewFile[Scheduled](filepath.Join(t.TempDir(), "scheduled.json"))
+	assert.Nil(t, err)
+	txt, m := renderScheduled(schedule, "user0")
+	assert.Equal(t, "No scheduled messages. Use /pub to schedule one.", txt)
+	assert.Empty(t, m.InlineKeyboard)
+	//
+	data, _ := protojson.Marshal(&pb.CloudEvent{
+		Data: &pb.CloudEvent_TextData{
+			TextData: "Lorem ipsum dolor sit amet, consectetur adipiscing elit <b>",
+		},
+	})
+	assert.Nil(t, schedule.Set("sched0", Scheduled{
+		Event:  string(data),
+		UserId: "user0",
+		Next:   time.Date(2026, 10, 20, 9, 30, 0, 0, time.UTC),
+		Period: 24 * time.Hour,
+	}))
+	assert.Nil(t, schedule.Set("sched1", Scheduled{
+		Event:  "{}",
+		UserId: "user0",
+		Next:   time.Date(2026, 10, 21, 9, 30, 0, 0, time.UTC),
+	}))
+	assert.Nil(t, schedule.Set("sched2", Scheduled{
+		UserId: "user1",
+	}))
+	txt, m = renderScheduled(schedule, "user0")
+	assert.Equal(
+		t,
+		"Scheduled messages:\n"+
+			"1. daily at 09:30 UTC: Lorem ipsum dolor sit amet, consectetur ...\n"+
+			"2. at 2026-10-21 09:30 UTC: (media)\n",
+		txt,
+	)
+	assert.Len(t, m.InlineKeyboard, 2)
+	assert.Equal(t, CmdPubCancel+" sched1", m.InlineKeyboard[1][0].Data)
+}
+
+func TestParseScheduleTime(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC) // Monday
+	cases := map[string]struct {
+		txt    string
+		period time.Duration
+		at     time.Time
+		err    error
+	}{
+		"once": {
+			txt: "2026-10-20 09:30",
+			at:  time.Date(2026, 10, 20, 9, 30, 0, 0, time.UTC),
+		},
+		"once in the past": {
+			txt: "2026-10-19 09:30",
+			err: errSchedule,
+		},
+		"daily later today": {
+			txt:    "18:15",
+			period: schedPeriodDaily,
+			at:     time.Date(2026, 10, 19, 18, 15, 0, 0, time.UTC),
+		},
+		"daily tomorrow": {
+			txt:    "09:30",
+			period: schedPeriodDaily,
+			at:     time.Date(2026, 10, 20, 9, 30, 0, 0, time.UTC),
+		},
+		"daily invalid": {
+			txt:    "9.30",
+			period: schedPeriodDaily,
+			err:    errSchedule,
+		},
+		"weekly": {
+			txt:    "Wednesday 09:30",
+			period: schedPeriodWeekly,
+			at:     time.Date(2026, 10, 21, 9, 30, 0, 0, time.UTC),
+		},
+		"weekly short name": {
+			txt:    "sun 09:30",
+			period: schedPeriodWeekly,
+			at:     time.Date(2026, 10, 25, 9, 30, 0, 0, time.UTC),
+		},
+		"weekly same day passed": {
+			txt:    "Monday 09:30",
+			period: schedPeriodWeekly,
+			at:     time.Date(2026, 10, 26, 9, 30, 0, 0, time.UTC),
+		},
+		"weekly unknown weekday": {
+			txt:    "Someday 09:30",
+			period: schedPeriodWeekly,
+			err:    errSchedule,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			at, err := parseScheduleTime(now, c.txt, c.period)
+			assert.ErrorIs(t, err, c.err)
+			if c.err == nil {
+				assert.Equal(t, c.at, at)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package messages
+
+import (
+	"errors"
+	"fmt"
+	"github.com/awakari/bot-telegram/model"
+	"github.com/awakari/bot-telegram/service"
+	"github.com/awakari/bot-telegram/service/storage"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"gopkg.in/telebot.v3"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ChannelSettings is the channel administrators' publishing policy for the channel posts.
+type ChannelSettings struct {
+	Paused     bool   `json:"paused,omitempty"`
+	NoMedia    bool   `json:"noMedia,omitempty"`
+	TaggedOnly bool   `json:"taggedOnly,omitempty"`
+	Language   string `json:"language,omitempty"`
+	Categories string `json:"categories,omitempty"`
+}
+
+const CmdChanSettings = "chan_set"
+const tagAwakari = "#awakari"
+const chanSettingPause = "pause"
+const chanSettingMedia = "media"
+const chanSettingTagged = "tagged"
+const chanSettingLanguage = "language"
+const chanSettingCategories = "categories"
+const chanSettingOff = "off"
+const chanCategoriesCountMax = 8
+const msgChanSettingsUsage = "\nThe language is detected automatically, to override it or to set the default categories use the command text:\n" +
+	"<pre>/channelsettings [@channel] language en</pre>\n" +
+	"<pre>/channelsettings [@channel] categories tech news</pre>\n" +
+	"Use <code>off</code> as the value to reset."
+
+var errChanSettings = errors.New("invalid channel settings command")
+var chanSettingsLangRegex = regexp.MustCompile(`^[a-z]{2,3}$`)
+
+// skipReason returns a non-empty reason when the channel post should not be published.
+func (cs ChannelSettings) skipReason(msg *telebot.Message, txt string) (reason string) {
+	switch {
+	case cs.Paused:
+		reason = "publishing is paused"
+	case cs.NoMedia && msg.Media() != nil:
+		reason = "media posts are excluded"
+	case cs.TaggedOnly && !containsTag(txt, tagAwakari):
+		reason = "the post is not tagged with " + tagAwakari
+	}
+	return
+}
+
+// apply sets the channel's default attributes to the event unless these are already set.
+func (cs ChannelSettings) apply(evt *pb.CloudEvent) {
+	if cs.Language != "" {
+		setStringAttrIfMissing(evt, model.CeKeyLanguage, cs.Language)
+	}
+	if cs.Categories != "" {
+		setStringAttrIfMissing(evt, model.CeKeyCategories, cs.Categories)
+	}
+}
+
+func containsTag(txt, tag string) bool {
+	for _, w := range strings.Fields(txt) {
+		if strings.EqualFold(w, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// ChannelSettingsHandlerFunc handles the "/channelsettings" command posted in the channel itself or sent to the bot
+// in private chat followed by the channel @username.
+func ChannelSettingsHandlerFunc(settings storage.Storage[ChannelSettings]) telebot.HandlerFunc {
+	return func(tgCtx telebot.Context) (err error) {
+		args := strings.Fields(tgCtx.Text())
+		if len(args) > 0 {
+			args = args[1:] // skip the command itself
+		}
+		var ch *telebot.Chat
+		switch tgCtx.Chat().Type {
+		case telebot.ChatChannel, telebot.ChatChannelPrivate:
+			ch = tgCtx.Chat() // only administrators may post in the channel
+		case telebot.ChatPrivate:
+			if len(args) == 0 || !strings.HasPrefix(args[0], "@") {
+				err = fmt.Errorf("%w: specify the channel, for example /channelsettings @channel", errChanSettings)
+				break
+			}
+			ch, err = tgCtx.Bot().ChatByUsername(args[0])
+			if err == nil {
+				err = service.VerifyChannelAdmins(tgCtx, ch)
+			}
+			args = args[1:]
+		default:
+			err = fmt.Errorf("%w: use it in the channel or in the private chat with the bot", errChanSettings)
+		}
+		var cs ChannelSettings
+		if err == nil {
+			cs, _ = settings.Get(strconv.FormatInt(ch.ID, 10))
+			if len(args) > 0 {
+				cs, err = cs.update(args[0], args[1:])
+				if err == nil {
+					err = settings.Set(strconv.FormatInt(ch.ID, 10), cs)
+				}
+			}
+		}
+		if err == nil {
+			err = tgCtx.Send(cs.render(ch), cs.markup(ch.ID), telebot.ModeHTML)
+		}
+		return
+	}
+}
+
+// ChannelSettingsToggle handles the settings message buttons, these are visible to every channel reader, so the sender
+// is verified to be the channel administrator.
+func ChannelSettingsToggle(settings storage.Storage[ChannelSettings]) service.ArgHandlerFunc {
+	return func(tgCtx telebot.Context, args ...string) (err error) {
+		if len(args) < 2 {
+			err = fmt.Errorf("%w: %+v", errChanSettings, args)
+			return
+		}
+		var ch *telebot.Chat
+		ch, err = service.ChannelById(tgCtx, args[0])
+		if err == nil {
+			err = service.VerifyChannelAdmins(tgCtx, ch)
+		}
+		var cs ChannelSettings
+		if err == nil {
+			cs, _ = settings.Get(args[0])
+			cs, err = cs.update(args[1], nil)
+		}
+		if err == nil {
+			err = settings.Set(args[0], cs)
+		}
+		if err == nil {
+			err = tgCtx.Edit(cs.render(ch), cs.markup(ch.ID), telebot.ModeHTML)
+		}
+		return
+	}
+}
+
+func (cs ChannelSettings) update(key string, vals []string) (dst ChannelSettings, err error) {
+	dst = cs
+	switch key {
+	case chanSettingPause:
+		dst.Paused = !cs.Paused
+	case chanSettingMedia:
+		dst.NoMedia = !cs.NoMedia
+	case chanSettingTagged:
+		dst.TaggedOnly = !cs.TaggedOnly
+	case chanSettingLanguage:
+		switch {
+		case len(vals) == 1 && vals[0] == chanSettingOff:
+			dst.Language = ""
+		case len(vals) == 1 && chanSettingsLangRegex.MatchString(vals[0]):
+			dst.Language = vals[0]
+		default:
+			err = fmt.Errorf("%w: expected a single ISO 639 language code, e.g. \"en\"", errChanSettings)
+		}
+	case chanSettingCategories:
+		switch {
+		case len(vals) == 1 && vals[0] == chanSettingOff:
+			dst.Categories = ""
+		case len(vals) > 0 && len(vals) <= chanCategoriesCountMax:
+			for i, v := range vals {
+				vals[i] = strings.TrimPrefix(v, "#")
+			}
+			dst.Categories = strings.Join(vals, " ")
+		default:
+			err = fmt.Errorf("%w: expected 1 to %d categories", errChanSettings, chanCategoriesCountMax)
+		}
+	default:
+		err = fmt.Errorf("%w: unknown setting %q", errChanSettings, key)
+	}
+	return
+}
+
+func (cs ChannelSettings) render(ch *telebot.Chat) (txt string) {
+	txt = fmt.Sprintf("Channel %s publishing settings:\n", html.EscapeString(ch.Title))
+	txt += "Publishing: " + onOff(!cs.Paused, "on", "paused") + "\n"
+	txt += "Media posts: " + onOff(!cs.NoMedia, "included", "excluded") + "\n"
+	txt += "Only posts tagged " + tagAwakari + ": " + onOff(cs.TaggedOnly, "yes", "no") + "\n"
+	txt += "Language: " + onOff(cs.Language != "", cs.Language, "auto") + "\n"
+	txt += "Categories: " + onOff(cs.Categories != "", html.EscapeString(cs.Categories), "none") + "\n"
+	txt += msgChanSettingsUsage
+	return
+}
+
+func (cs ChannelSettings) markup(chanId int64) (m *telebot.ReplyMarkup) {
+	m = &telebot.ReplyMarkup{}
+	m.Inline(
+		m.Row(
+			telebot.Btn{
+				Text: onOff(cs.Paused, "▶ Resume", "⏸ Pause"),
+				Data: fmt.Sprintf("%s %d %s", CmdChanSettings, chanId, chanSettingPause),
+			},
+			telebot.Btn{
+				Text: onOff(cs.NoMedia, "Include media", "Exclude media"),
+				Data: fmt.Sprintf("%s %d %s", CmdChanSettings, chanId, chanSettingMedia),
+			},
+		),
+		m.Row(
+			telebot.Btn{
+				Text: onOff(cs.TaggedOnly, "All posts", "Only "+tagAwakari),
+				Data: fmt.Sprintf("%s %d %s", CmdChanSettings, chanId, chanSettingTagged),
+			},
+		),
+	)
+	return
+}
+
+func onOff(cond bool, on, off string) string {
+	if cond {
+		return on
+	}
+	return off
+}
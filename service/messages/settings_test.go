@@ -0,0 +1,125 @@
+package messages
+
+import (
+	"github.com/awakari/bot-telegram/model"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/telebot.v3"
+	"testing"
+)
+
+func TestChannelSettings_Update(t *testing.T) {
+	cases := map[string]struct {
+		src  ChannelSettings
+		key  string
+		vals []string
+		dst  ChannelSettings
+		err  error
+	}{
+		"pause": {
+			key: chanSettingPause,
+			dst: ChannelSettings{Paused: true},
+		},
+		"resume": {
+			src: ChannelSettings{Paused: true, NoMedia: true},
+			key: chanSettingPause,
+			dst: ChannelSettings{NoMedia: true},
+		},
+		"language": {
+			key:  chanSettingLanguage,
+			vals: []string{"en"},
+			dst:  ChannelSettings{Language: "en"},
+		},
+		"language off": {
+			src:  ChannelSettings{Language: "en"},
+			key:  chanSettingLanguage,
+			vals: []string{"off"},
+		},
+		"language invalid": {
+			key:  chanSettingLanguage,
+			vals: []string{"English"},
+			err:  errChanSettings,
+		},
+		"categories": {
+			key:  chanSettingCategories,
+			vals: []string{"#tech", "news"},
+			dst:  ChannelSettings{Categories: "tech news"},
+		},
+		"unknown": {
+			key: "foo",
+			err: errChanSettings,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			dst, err := c.src.update(c.key, c.vals)
+			assert.ErrorIs(t, err, c.err)
+			if c.err == nil {
+				assert.Equal(t, c.dst, dst)
+			}
+		})
+	}
+}
+
+func TestChannelSettings_SkipReason(t *testing.T) {
+	photo := &telebot.Message{
+		Photo: &telebot.Photo{},
+	}
+	cases := map[string]struct {
+		cs   ChannelSettings
+		msg  *telebot.Message
+		txt  string
+		skip bool
+	}{
+		"default": {
+			msg: photo,
+			txt: "foo",
+		},
+		"paused": {
+			cs:   ChannelSettings{Paused: true},
+			msg:  &telebot.Message{},
+			skip: true,
+		},
+		"media excluded": {
+			cs:   ChannelSettings{NoMedia: true},
+			msg:  photo,
+			skip: true,
+		},
+		"text when media excluded": {
+			cs:  ChannelSettings{NoMedia: true},
+			msg: &telebot.Message{},
+			txt: "foo",
+		},
+		"untagged": {
+			cs:   ChannelSettings{TaggedOnly: true},
+			msg:  &telebot.Message{},
+			txt:  "foo #awakarinews",
+			skip: true,
+		},
+		"tagged": {
+			cs:  ChannelSettings{TaggedOnly: true},
+			msg: &telebot.Message{},
+			txt: "foo #Awakari",
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			assert.Equal(t, c.skip, c.cs.skipReason(c.msg, c.txt) != "")
+		})
+	}
+}
+
+func TestChannelSettings_Apply(t *testing.T) {
+	evt := &pb.CloudEvent{
+		Attributes: map[string]*pb.CloudEventAttributeValue{
+			model.CeKeyCategories: {
+				Attr: &pb.CloudEventAttributeValue_CeString{
+					CeString: "own",
+				},
+			},
+		},
+	}
+	ChannelSettings{Language: "de", Categories: "tech news"}.apply(evt)
+	assert.Equal(t, "de", evt.Attributes[model.CeKeyLanguage].GetCeString())
+	assert.Equal(t, "own", evt.Attributes[model.CeKeyCategories].GetCeString())
+}
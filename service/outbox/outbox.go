@@ -0,0 +1,184 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/awakari/bot-telegram/api/http/pub"
+	"github.com/awakari/bot-telegram/config"
+	"github.com/awakari/bot-telegram/service/storage"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"log/slog"
+	"time"
+)
+
+// Outbox persists the events failed to publish, so these are not lost when the writer is unavailable or the publishing
+// limit is reached. The persisted events are retried in the background until expired.
+type Outbox interface {
+	pub.Service
+
+	// Run retries publishing the pending events until the context is done.
+	Run(ctx context.Context)
+
+	// Depth returns the count of the pending events.
+	Depth() int
+}
+
+// Entry is the pending event stored in the outbox.
+type Entry struct {
+	Event    string    `json:"event"`
+	GroupId  string    `json:"groupId"`
+	UserId   string    `json:"userId"`
+	Created  time.Time `json:"created"`
+	Attempts int       `json:"attempts,omitempty"`
+	Next     time.Time `json:"next"`
+}
+
+type outbox struct {
+	svcPub  pub.Service
+	entries storage.Storage[Entry]
+	cfg     config.OutboxConfig
+	log     *slog.Logger
+}
+
+var ErrExpired = errors.New("outbox event expired")
+
+func NewOutbox(svcPub pub.Service, entries storage.Storage[Entry], cfg config.OutboxConfig, log *slog.Logger) Outbox {
+	return outbox{
+		svcPub:  svcPub,
+		entries: entries,
+		cfg:     cfg,
+		log:     log,
+	}
+}
+
+// Publish makes the first attempt to publish the event and stores it for the background retries when not accepted. The
+// event is stored only on failure, so the successful publishing doesn't touch the storage. The error is returned only
+// when the event could not be stored or is rejected permanently.
+func (o outbox) Publish(ctx context.Context, evt *pb.CloudEvent, groupId, userId string) (err error) {
+	e := newEntry(groupId, userId)
+	err = o.settle(evt, e, o.svcPub.Publish(ctx, evt, groupId, userId))
+	return
+}
+
+// PublishBatch makes the first attempt to publish the events in a single batch and stores the events not acknowledged
+// for the background retries. Only the event the writer stopped at gets the publishing error, so only this one is
+// dropped when rejected, the events following it were not processed and are retried.
+func (o outbox) PublishBatch(ctx context.Context, evts []*pb.CloudEvent, groupId, userId string) (ackCount uint32, err error) {
+	var errPub error
+	ackCount, errPub = o.svcPub.PublishBatch(ctx, evts, groupId, userId)
+	for i, evt := range evts {
+		var errEvt error
+		switch {
+		case uint32(i) < ackCount:
+			continue
+		case uint32(i) == ackCount && errPub != nil:
+			errEvt = errPub
+		default:
+			errEvt = fmt.Errorf("%w: %s", pub.ErrNoAck, evt.Id)
+		}
+		err = errors.Join(err, o.settle(evt, newEntry(groupId, userId), errEvt))
+	}
+	return
+}
+
+func newEntry(groupId, userId string) Entry {
+	return Entry{
+		GroupId: groupId,
+		UserId:  userId,
+		Created: time.Now().UTC(),
+	}
+}
+
+func (o outbox) Run(ctx context.Context) {
+	t := time.NewTicker(o.cfg.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			o.retryDue(ctx)
+		}
+	}
+}
+
+func (o outbox) Depth() (n int) {
+	o.entries.Each(func(_ string, _ Entry) bool {
+		n++
+		return true
+	})
+	return
+}
+
+func (o outbox) retryDue(ctx context.Context) {
+	now := time.Now().UTC()
+	o.entries.Each(func(id string, e Entry) bool {
+		if e.Next.After(now) {
+			return true
+		}
+		var evt pb.CloudEvent
+		err := protojson.Unmarshal([]byte(e.Event), &evt)
+		switch err {
+		case nil:
+			err = o.attempt(ctx, &evt, e)
+		default:
+			err = o.entries.Delete(id) // corrupted, can't be ever published
+		}
+		if err != nil {
+			o.log.Warn(fmt.Sprintf("Outbox event %s dropped: %s", id, err))
+		}
+		return ctx.Err() == nil
+	})
+}
+
+func (o outbox) attempt(ctx context.Context, evt *pb.CloudEvent, e Entry) error {
+	return o.settle(evt, e, o.svcPub.Publish(ctx, evt, e.GroupId, e.UserId))
+}
+
+// settle either removes the event from the outbox or schedules the next attempt depending on the publishing result.
+// The entry not stored yet has no event data, it's stored only when the next attempt is scheduled.
+func (o outbox) settle(evt *pb.CloudEvent, e Entry, errPub error) (err error) {
+	stored := e.Event != ""
+	var delay time.Duration
+	switch {
+	case errPub == nil:
+		if stored {
+			err = o.entries.Delete(evt.Id)
+		}
+		return
+	case errors.Is(errPub, pub.ErrInvalid), errors.Is(errPub, pub.ErrNoAuth):
+		if stored {
+			_ = o.entries.Delete(evt.Id)
+		}
+		err = errPub
+		return
+	case errors.Is(errPub, pub.ErrLimitReached):
+		delay = o.cfg.Delay.Limit // no sense to retry before the limit is reset or increased
+	default:
+		delay = o.cfg.Delay.Min << min(e.Attempts, 16)
+		if delay > o.cfg.Delay.Max {
+			delay = o.cfg.Delay.Max
+		}
+	}
+	now := time.Now().UTC()
+	if now.Sub(e.Created) > o.cfg.AgeMax {
+		_ = o.entries.Delete(evt.Id)
+		err = fmt.Errorf("%w after %d attempts, last error: %s", ErrExpired, e.Attempts+1, errPub)
+		return
+	}
+	if !stored {
+		var data []byte
+		data, err = protojson.Marshal(evt)
+		if err != nil {
+			return
+		}
+		e.Event = string(data)
+	}
+	o.log.Warn(fmt.Sprintf("Outbox event %s publishing failed: %s, retrying in %s", evt.Id, errPub, delay))
+	e.Attempts++
+	e.Next = now.Add(delay)
+	err = o.entries.Set(evt.Id, e)
+	return
+}
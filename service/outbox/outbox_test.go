@@ -0,0 +1,163 @@
+package outbox
+
+import (
+	"context"
+	"github.com/awakari/bot-telegram/api/http/pub"
+	"github.com/awakari/bot-telegram/config"
+	"github.com/awakari/bot-telegram/service/storage"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type pubStub struct {
+	errs map[string]error
+}
+
+func (ps pubStub) Publish(ctx context.Context, evt *pb.CloudEvent, groupId, userId string) (err error) {
+	return ps.errs[evt.Id]
+}
+
+func (ps pubStub) PublishBatch(ctx context.Context, evts []*pb.CloudEvent, groupId, userId string) (ackCount uint32, err error) {
+	for _, evt := range evts {
+		err = ps.errs[evt.Id]
+		if err != nil {
+			break
+		}
+		ackCount++
+	}
+	return
+}
+
+func newTestOutbox(t *testing.T, errs map[string]error) (ob outbox) {
+	store, err := storage.NewFile[Entry](filepath.Join(t.TempDir(), "outbox.json"))
+	assert.Nil(t, err)
+	cfg := config.OutboxConfig{
+		Interval: time.Millisecond,
+		AgeMax:   time.Hour,
+	}
+	cfg.Delay.Min = time.Second
+	cfg.Delay.Max = time.Minute
+	cfg.Delay.Limit = 30 * time.Minute
+	return NewOutbox(pubStub{errs: errs}, store, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil))).(outbox)
+}
+
+func TestOutbox_Publish(t *testing.T) {
+	ob := newTestOutbox(t, map[string]error{
+		"invalid":   pub.ErrInvalid,
+		"transient": pub.ErrNoAck,
+		"limit":     pub.ErrLimitReached,
+	})
+	cases := map[string]struct {
+		err     error
+		pending bool
+		delay   time.Duration
+	}{
+		"ok": {},
+		"invalid": {
+			err: pub.ErrInvalid,
+		},
+		"transient": {
+			pending: true,
+			delay:   time.Second,
+		},
+		"limit": {
+			pending: true,
+			delay:   30 * time.Minute,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			err := ob.Publish(context.TODO(), &pb.CloudEvent{Id: k}, "group0", "user0")
+			assert.ErrorIs(t, err, c.err)
+			e, found := ob.entries.Get(k)
+			assert.Equal(t, c.pending, found)
+			if c.pending {
+				assert.Equal(t, 1, e.Attempts)
+				assert.WithinDuration(t, time.Now().Add(c.delay), e.Next, 100*time.Millisecond)
+			}
+		})
+	}
+	assert.Equal(t, 2, ob.Depth())
+}
+
+func TestOutbox_Publish_NotStored(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	store, err := storage.NewFile[Entry](path)
+	assert.Nil(t, err)
+	ob := NewOutbox(pubStub{}, store, config.OutboxConfig{}, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	assert.Nil(t, ob.Publish(context.TODO(), &pb.CloudEvent{Id: "evt0"}, "group0", "user0"))
+	_, err = os.Stat(path)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestOutbox_PublishBatch(t *testing.T) {
+	ob := newTestOutbox(t, map[string]error{
+		"evt1": pub.ErrNoAck,
+	})
+	evts := []*pb.CloudEvent{
+		{Id: "evt0"},
+		{Id: "evt1"},
+		{Id: "evt2"},
+	}
+	ackCount, err := ob.PublishBatch(context.TODO(), evts, "group0", "user0")
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1), ackCount)
+	_, found := ob.entries.Get("evt0")
+	assert.False(t, found)
+	e1, found := ob.entries.Get("evt1")
+	assert.True(t, found)
+	assert.Equal(t, 1, e1.Attempts)
+	e2, found := ob.entries.Get("evt2")
+	assert.True(t, found)
+	assert.Equal(t, 1, e2.Attempts)
+}
+
+func TestOutbox_PublishBatch_Rejected(t *testing.T) {
+	ob := newTestOutbox(t, map[string]error{
+		"evt1": pub.ErrInvalid,
+	})
+	evts := []*pb.CloudEvent{
+		{Id: "evt0"},
+		{Id: "evt1"},
+		{Id: "evt2"},
+	}
+	ackCount, err := ob.PublishBatch(context.TODO(), evts, "group0", "user0")
+	assert.ErrorIs(t, err, pub.ErrInvalid)
+	assert.Equal(t, uint32(1), ackCount)
+	_, found := ob.entries.Get("evt1")
+	assert.False(t, found)
+	e2, found := ob.entries.Get("evt2")
+	assert.True(t, found)
+	assert.Equal(t, 1, e2.Attempts)
+	assert.Equal(t, 1, ob.Depth())
+}
+
+func TestOutbox_Run(t *testing.T) {
+	errs := map[string]error{
+		"evt0": pub.ErrNoAck,
+		"evt1": pub.ErrNoAck,
+	}
+	ob := newTestOutbox(t, errs)
+	assert.Nil(t, ob.Publish(context.TODO(), &pb.CloudEvent{Id: "evt0"}, "group0", "user0"))
+	assert.Nil(t, ob.Publish(context.TODO(), &pb.CloudEvent{Id: "evt1"}, "group0", "user0"))
+	assert.Equal(t, 2, ob.Depth())
+	//
+	e0, _ := ob.entries.Get("evt0")
+	e0.Next = time.Now().Add(-time.Second)
+	assert.Nil(t, ob.entries.Set("evt0", e0))
+	e1, _ := ob.entries.Get("evt1")
+	e1.Next = time.Now().Add(-time.Second)
+	e1.Created = time.Now().Add(-2 * time.Hour)
+	assert.Nil(t, ob.entries.Set("evt1", e1))
+	delete(errs, "evt0") // recovered
+	//
+	ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	defer cancel()
+	ob.Run(ctx)
+	assert.Equal(t, 0, ob.Depth()) // evt0 published, evt1 expired
+}
@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"github.com/awakari/bot-telegram/model/usage"
 	"github.com/awakari/bot-telegram/service/limits"
+	"github.com/awakari/bot-telegram/service/storage"
 	"github.com/awakari/bot-telegram/util"
 	"gopkg.in/telebot.v3"
+	"strconv"
 	"time"
 )
 
@@ -17,6 +19,18 @@ type PaidChatMemberHandler struct {
 	LimitByChatIdInterests       map[int64]int64
 	LimitByChatIdInterestsPublic map[int64]int64
 	SvcLimits                    limits.Service
+
+	// Members keeps the users seen in the paid channels by the Telegram user id to reconcile their limits later.
+	Members storage.Storage[PaidMember]
+
+	// LimitRecords tells which user limits have been granted by the paid channels.
+	LimitRecords storage.Storage[limits.Record]
+}
+
+// PaidMember is the user seen in any of the paid channels.
+type PaidMember struct {
+	TgUserId int64     `json:"tgUserId"`
+	Updated  time.Time `json:"updated"`
 }
 
 var noExpiration = time.Time{}
@@ -44,6 +58,13 @@ func (h PaidChatMemberHandler) handleChatMember(tgCtx telebot.Context, upd *tele
 			})
 		}
 	}
+	if user != nil && h.Members != nil {
+		errMember := h.Members.Set(strconv.FormatInt(user.ID, 10), PaidMember{
+			TgUserId: user.ID,
+			Updated:  time.Now().UTC(),
+		})
+		err = errors.Join(err, errMember)
+	}
 	err = errors.Join(err, h.ensureLimit(tgCtx, h.LimitByChatIdSubscriptions, user, usage.SubjectSubscriptions))
 	err = errors.Join(err, h.ensureLimit(tgCtx, h.LimitByChatIdInterests, user, usage.SubjectInterests))
 	err = errors.Join(err, h.ensureLimit(tgCtx, h.LimitByChatIdInterestsPublic, user, usage.SubjectInterestsPublic))
@@ -69,6 +90,13 @@ func (h PaidChatMemberHandler) ensureLimit(
 	userId := util.TelegramToAwakariUserId(user.ID)
 	switch len(chanByLimit) {
 	case 0:
+		limitCurr, errLimGet := h.SvcLimits.Get(ctx, h.GroupId, userId, subj)
+		if errLimGet == nil && !limitCurr.Expires.IsZero() {
+			break // not a paid channel limit, e.g. purchased in the bot
+		}
+		if rec, found := h.limitRecord(userId, subj); found && rec.Source != limits.SourcePaidChannel {
+			break // set by the admin
+		}
 		errLimDel := h.SvcLimits.Delete(ctx, h.GroupId, userId, subj)
 		switch errLimDel {
 		case nil:
@@ -124,6 +152,14 @@ func (h PaidChatMemberHandler) ensureLimit(
 	return
 }
 
+// limitRecord returns the record of the user limit set by the bot, if any.
+func (h PaidChatMemberHandler) limitRecord(userId string, subj usage.Subject) (rec limits.Record, found bool) {
+	if h.LimitRecords != nil {
+		rec, found = h.LimitRecords.Get(limits.RecordKey(h.GroupId, userId, subj))
+	}
+	return
+}
+
 func (h PaidChatMemberHandler) memberChannels(
 	bot *telebot.Bot,
 	user *telebot.User,
@@ -141,6 +177,7 @@ func (h PaidChatMemberHandler) memberChannels(
 		member, errMember := bot.ChatMemberOf(chat, user)
 		if errMember != nil {
 			err = errors.Join(err, fmt.Errorf("failed to check if a user %d is member of chat %d: %w", user.ID, chatId, errMember))
+			continue
 		}
 		if member.Role == telebot.Member {
 			chanByLimit[limit] = chat
@@ -0,0 +1,393 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/awakari/bot-telegram/model/usage"
+	"github.com/awakari/bot-telegram/service/limits"
+	"github.com/awakari/bot-telegram/service/storage"
+	"github.com/awakari/bot-telegram/util"
+	"gopkg.in/telebot.v3"
+	"html"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Purchase is the limit increase paid with Telegram Stars, stored by the Telegram payment charge id.
+type Purchase struct {
+	TgUserId int64         `json:"tgUserId"`
+	Subject  usage.Subject `json:"subject"`
+	Limit    int64         `json:"limit"`
+	Stars    int           `json:"stars"`
+	Created  time.Time     `json:"created"`
+	Expires  time.Time     `json:"expires"`
+	Refunded time.Time     `json:"refunded"`
+
+	// Days is the purchased duration, zero for the purchases made before it was recorded.
+	Days int `json:"days,omitempty"`
+}
+
+// Handler sells the limit tiers priced in Telegram Stars.
+type Handler struct {
+	SvcLimits     limits.Service
+	Tiers         usage.Tiers
+	GroupId       string
+	Purchases     storage.Storage[Purchase]
+	SupportChatId int64
+	Log           *slog.Logger
+}
+
+const currencyStars = "XTR"
+const purchasesCountMax = 20
+
+var errPayload = errors.New("invalid invoice payload")
+var errPurchase = errors.New("purchase failure")
+
+// Buy handles the service.CmdLimitBuy callback sending the invoice for the tier.
+func (h Handler) Buy(tgCtx telebot.Context, args ...string) (err error) {
+	if len(args) != 2 {
+		err = fmt.Errorf("%w: %v", errPayload, args)
+		return
+	}
+	var t usage.Tier
+	t, err = h.tier(args[0], args[1])
+	if err == nil {
+		err = tgCtx.Send(&telebot.Invoice{
+			Title:       fmt.Sprintf("%s: %d", t.Subject.Description(), t.Limit),
+			Description: fmt.Sprintf("Increase the %s limit to %d for %d days", t.Subject.Description(), t.Limit, t.Days),
+			Payload:     encodePayload(t),
+			Currency:    currencyStars,
+			Prices: []telebot.Price{
+				{
+					Label:  fmt.Sprintf("%d days", t.Days),
+					Amount: t.Stars,
+				},
+			},
+		})
+	}
+	return
+}
+
+// PreCheckout handles the pre-checkout query: the invoice should still match the configured tier and the tier should
+// increase the current limit.
+func (h Handler) PreCheckout(tgCtx telebot.Context) (err error) {
+	q := tgCtx.PreCheckoutQuery()
+	t, errCheck := h.tierByPayload(q.Payload)
+	if errCheck == nil && (q.Currency != currencyStars || q.Total != t.Stars) {
+		errCheck = fmt.Errorf("%w: price mismatch", errPayload)
+	}
+	if errCheck == nil {
+		var l usage.Limit
+		l, errCheck = h.SvcLimits.Get(context.TODO(), h.GroupId, util.TelegramToAwakariUserId(q.Sender.ID), t.Subject)
+		if errCheck == nil && (l.Count > t.Limit || (l.Count == t.Limit && l.UserId != "" && l.Expires.IsZero())) {
+			errCheck = fmt.Errorf("the current %s limit is already %d", t.Subject.Description(), l.Count)
+		}
+	}
+	switch errCheck {
+	case nil:
+		err = tgCtx.Accept()
+	default:
+		h.Log.Warn(fmt.Sprintf("Pre-checkout query %s from %d declined: %s", q.ID, q.Sender.ID, errCheck))
+		err = tgCtx.Accept(errCheck.Error())
+	}
+	return
+}
+
+// Payment handles the successful payment: sets the limit with the expiration and records the purchase. The payment
+// is refunded when the limit fails to set.
+func (h Handler) Payment(tgCtx telebot.Context) (err error) {
+	p := tgCtx.Message().Payment
+	tgUserId := tgCtx.Sender().ID
+	userId := util.TelegramToAwakariUserId(tgUserId)
+	var t usage.Tier
+	t, err = decodePayload(p.Payload)
+	var l usage.Limit
+	if err == nil {
+		l, err = h.SvcLimits.Get(context.TODO(), h.GroupId, userId, t.Subject)
+	}
+	now := time.Now().UTC()
+	pur := Purchase{
+		TgUserId: tgUserId,
+		Subject:  t.Subject,
+		Limit:    t.Limit,
+		Stars:    p.Total,
+		Created:  now,
+		Expires:  expires(l, t, now),
+		Days:     t.Days,
+	}
+	if err == nil {
+		err = h.SvcLimits.Set(context.TODO(), h.GroupId, userId, t.Subject, pur.Limit, pur.Expires)
+	}
+	if err != nil {
+		h.Log.Error(fmt.Sprintf("Failed to apply the payment %s from %d, refunding: %s", p.TelegramChargeID, tgUserId, err))
+		err = fmt.Errorf("%w: %s", errPurchase, err)
+		errRefund := refund(tgCtx.Bot(), tgUserId, p.TelegramChargeID)
+		if errRefund == nil {
+			pur.Refunded = now
+		}
+		err = errors.Join(err, errRefund)
+	}
+	errStore := h.Purchases.Set(p.TelegramChargeID, pur)
+	if errStore != nil {
+		h.Log.Error(fmt.Sprintf("Failed to store the purchase %s: %+v, %s", p.TelegramChargeID, pur, errStore))
+	}
+	switch {
+	case err == nil:
+		err = tgCtx.Send(
+			fmt.Sprintf(
+				"Thank you! %s limit has been set to %d until %s.",
+				t.Subject.Description(), pur.Limit, pur.Expires.Format(time.DateOnly),
+			),
+		)
+	case !pur.Refunded.IsZero():
+		err = fmt.Errorf("%w, the payment has been refunded", err)
+	default:
+		err = fmt.Errorf("%w, please contact /support with the payment id: %s", err, p.TelegramChargeID)
+	}
+	return
+}
+
+// PurchasesHandlerFunc handles the "/purchases" command listing the own purchase history.
+func (h Handler) PurchasesHandlerFunc(tgCtx telebot.Context) (err error) {
+	tgUserId := tgCtx.Sender().ID
+	type purchaseById struct {
+		id  string
+		pur Purchase
+	}
+	var purs []purchaseById
+	h.Purchases.Each(func(id string, pur Purchase) bool {
+		if pur.TgUserId == tgUserId {
+			purs = append(purs, purchaseById{id, pur})
+		}
+		return true
+	})
+	sort.Slice(purs, func(i, j int) bool {
+		return purs[i].pur.Created.After(purs[j].pur.Created)
+	})
+	if len(purs) > purchasesCountMax {
+		purs = purs[:purchasesCountMax]
+	}
+	var txt strings.Builder
+	txt.WriteString("<b>Purchases</b>\n")
+	if len(purs) == 0 {
+		txt.WriteString("No purchases yet.")
+	}
+	for _, p := range purs {
+		txt.WriteString(renderPurchase(p.id, p.pur))
+		txt.WriteString("\n")
+	}
+	err = tgCtx.Send(txt.String(), telebot.ModeHTML)
+	return
+}
+
+// RefundHandlerFunc handles the "/refund <payment id>" command in the support chat: refunds the Stars and, when the
+// purchase is still active, recomputes the limit from the user's remaining purchases of the same subject.
+func (h Handler) RefundHandlerFunc(tgCtx telebot.Context) (err error) {
+	if tgCtx.Chat().ID != h.SupportChatId {
+		err = errors.New("the command is available in the support chat only")
+		return
+	}
+	args := tgCtx.Args()
+	if len(args) != 1 {
+		err = errors.New("usage: /refund <payment id>")
+		return
+	}
+	id := args[0]
+	pur, found := h.Purchases.Get(id)
+	switch {
+	case !found:
+		err = fmt.Errorf("purchase not found: %s", id)
+	case !pur.Refunded.IsZero():
+		err = fmt.Errorf("purchase already refunded: %s", id)
+	default:
+		err = refund(tgCtx.Bot(), pur.TgUserId, id)
+	}
+	if err != nil {
+		return
+	}
+	now := time.Now().UTC()
+	pur.Refunded = now
+	err = h.Purchases.Set(id, pur)
+	userId := util.TelegramToAwakariUserId(pur.TgUserId)
+	msgUser := fmt.Sprintf("The payment %s has been refunded: %d ⭐.", id, pur.Stars)
+	if pur.Expires.After(now) {
+		l := h.replay(pur.TgUserId, pur.Subject)
+		switch {
+		case l.Expires.After(now):
+			err = errors.Join(err, h.SvcLimits.Set(context.TODO(), h.GroupId, userId, pur.Subject, l.Count, l.Expires))
+			msgUser += fmt.Sprintf(
+				" %s limit is now %d until %s.",
+				pur.Subject.Description(), l.Count, l.Expires.Format(time.DateOnly),
+			)
+		default:
+			err = errors.Join(err, h.SvcLimits.Delete(context.TODO(), h.GroupId, userId, pur.Subject))
+			msgUser += fmt.Sprintf(" %s limit has been reset to default.", pur.Subject.Description())
+		}
+	}
+	tgCtxUser := tgCtx.Bot().NewContext(telebot.Update{
+		Message: &telebot.Message{
+			Chat: &telebot.Chat{
+				ID: pur.TgUserId,
+			},
+		},
+	})
+	_ = tgCtxUser.Send(msgUser)
+	switch err {
+	case nil:
+		err = tgCtx.Send(fmt.Sprintf("Refunded %s: %d ⭐ to %s", id, pur.Stars, userId))
+	default:
+		err = fmt.Errorf("refunded %s, but failed to update the limit or the purchase: %w", id, err)
+	}
+	return
+}
+
+// replay applies the user's purchases of the subject that are not refunded again in the order of creation, so the
+// purchases prolonged by a refunded one lose the refunded days. The changed expiration times are stored.
+func (h Handler) replay(tgUserId int64, subj usage.Subject) (l usage.Limit) {
+	type purchaseById struct {
+		id  string
+		pur Purchase
+	}
+	var purs []purchaseById
+	h.Purchases.Each(func(id string, pur Purchase) bool {
+		if pur.TgUserId == tgUserId && pur.Subject == subj && pur.Refunded.IsZero() {
+			purs = append(purs, purchaseById{id, pur})
+		}
+		return true
+	})
+	sort.Slice(purs, func(i, j int) bool {
+		return purs[i].pur.Created.Before(purs[j].pur.Created)
+	})
+	userId := util.TelegramToAwakariUserId(tgUserId)
+	for _, p := range purs {
+		t := usage.Tier{
+			Subject: subj,
+			Limit:   p.pur.Limit,
+			Days:    h.days(p.pur),
+		}
+		exp := expires(l, t, p.pur.Created)
+		if !exp.Equal(p.pur.Expires) {
+			p.pur.Expires = exp
+			if errStore := h.Purchases.Set(p.id, p.pur); errStore != nil {
+				h.Log.Error(fmt.Sprintf("Failed to update the purchase %s: %+v, %s", p.id, p.pur, errStore))
+			}
+		}
+		l = usage.Limit{
+			Count:   p.pur.Limit,
+			UserId:  userId,
+			Expires: exp,
+		}
+	}
+	return
+}
+
+// days returns the purchased duration, for the older purchases it's taken from the tier.
+func (h Handler) days(pur Purchase) (days int) {
+	days = pur.Days
+	if days <= 0 {
+		if t, found := h.Tiers.Find(pur.Subject, pur.Limit); found && t.Days > 0 {
+			days = t.Days
+		}
+	}
+	if days <= 0 {
+		days = int(pur.Expires.Sub(pur.Created).Hours() / 24)
+	}
+	return
+}
+
+func (h Handler) tier(subjName, limitTxt string) (t usage.Tier, err error) {
+	var subj usage.Subject
+	err = subj.UnmarshalText([]byte(subjName))
+	var limit int64
+	if err == nil {
+		limit, err = strconv.ParseInt(limitTxt, 10, 64)
+	}
+	var found bool
+	if err == nil {
+		t, found = h.Tiers.Find(subj, limit)
+		if !found || t.Stars <= 0 {
+			err = fmt.Errorf("%w: no tier to purchase for %s: %d", errPayload, subj.Description(), limit)
+		}
+	}
+	return
+}
+
+func (h Handler) tierByPayload(payload string) (t usage.Tier, err error) {
+	var paid usage.Tier
+	paid, err = decodePayload(payload)
+	if err == nil {
+		var found bool
+		t, found = h.Tiers.Find(paid.Subject, paid.Limit)
+		if !found || t.Stars <= 0 || t.Stars != paid.Stars || t.Days != paid.Days {
+			err = fmt.Errorf("%w: the tier is not available anymore", errPayload)
+		}
+	}
+	return
+}
+
+// encodePayload returns the invoice payload "<subject> <limit> <stars> <days>" fixing the purchased terms.
+func encodePayload(t usage.Tier) (payload string) {
+	subjName, _ := t.Subject.MarshalText()
+	payload = fmt.Sprintf("%s %d %d %d", subjName, t.Limit, t.Stars, t.Days)
+	return
+}
+
+func decodePayload(payload string) (t usage.Tier, err error) {
+	parts := strings.Split(payload, " ")
+	if len(parts) != 4 {
+		err = fmt.Errorf("%w: %s", errPayload, payload)
+		return
+	}
+	err = t.Subject.UnmarshalText([]byte(parts[0]))
+	if err == nil {
+		t.Limit, err = strconv.ParseInt(parts[1], 10, 64)
+	}
+	if err == nil {
+		t.Stars, err = strconv.Atoi(parts[2])
+	}
+	if err == nil {
+		t.Days, err = strconv.Atoi(parts[3])
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %s", errPayload, err)
+	}
+	return
+}
+
+// expires returns the expiration time of the purchased limit: the active purchase of the same limit is prolonged.
+func expires(curr usage.Limit, t usage.Tier, now time.Time) (exp time.Time) {
+	exp = now
+	if curr.UserId != "" && curr.Count == t.Limit && curr.Expires.After(now) {
+		exp = curr.Expires
+	}
+	exp = exp.AddDate(0, 0, t.Days)
+	return
+}
+
+func refund(bot *telebot.Bot, tgUserId int64, chargeId string) (err error) {
+	_, err = bot.Raw("refundStarPayment", map[string]string{
+		"user_id":                    strconv.FormatInt(tgUserId, 10),
+		"telegram_payment_charge_id": chargeId,
+	})
+	return
+}
+
+func renderPurchase(id string, pur Purchase) (txt string) {
+	txt = fmt.Sprintf(
+		"%s: %s → %d, %d ⭐, until %s",
+		pur.Created.Format(time.DateOnly),
+		pur.Subject.Description(),
+		pur.Limit,
+		pur.Stars,
+		pur.Expires.Format(time.DateOnly),
+	)
+	if !pur.Refunded.IsZero() {
+		txt += fmt.Sprintf(", refunded on %s", pur.Refunded.Format(time.DateOnly))
+	}
+	txt += fmt.Sprintf("\n<code>%s</code>", html.EscapeString(id))
+	return
+}
@@ -0,0 +1,164 @@
+package payments
+
+import (
+	"github.com/awakari/bot-telegram/model/usage"
+	"github.com/awakari/bot-telegram/service/storage"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPayload(t *testing.T) {
+	tier := usage.Tier{
+		Subject: usage.SubjectPublishDaily,
+		Limit:   100,
+		Stars:   50,
+		Days:    30,
+	}
+	payload := encodePayload(tier)
+	assert.Equal(t, "publishDaily 100 50 30", payload)
+	decoded, err := decodePayload(payload)
+	assert.Nil(t, err)
+	assert.Equal(t, tier, decoded)
+	_, err = decodePayload("publishDaily 100 50")
+	assert.ErrorIs(t, err, errPayload)
+	_, err = decodePayload("foo 100 50 30")
+	assert.ErrorIs(t, err, errPayload)
+}
+
+func TestHandler_TierByPayload(t *testing.T) {
+	h := Handler{
+		Tiers: usage.Tiers{
+			{Subject: usage.SubjectPublishDaily, Limit: 100, Stars: 50, Days: 30},
+			{Subject: usage.SubjectSubscriptions, Limit: 10, Url: "https://t.me/tribute/app?startapp=sv5Q"},
+		},
+	}
+	cases := map[string]struct {
+		payload string
+		err     error
+	}{
+		"ok": {
+			payload: "publishDaily 100 50 30",
+		},
+		"price changed": {
+			payload: "publishDaily 100 40 30",
+			err:     errPayload,
+		},
+		"not for stars": {
+			payload: "subscriptions 10 0 0",
+			err:     errPayload,
+		},
+		"unknown tier": {
+			payload: "publishDaily 200 50 30",
+			err:     errPayload,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			_, err := h.tierByPayload(c.payload)
+			assert.ErrorIs(t, err, c.err)
+		})
+	}
+}
+
+func TestExpires(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	tier := usage.Tier{
+		Subject: usage.SubjectPublishDaily,
+		Limit:   100,
+		Stars:   50,
+		Days:    30,
+	}
+	cases := map[string]struct {
+		curr usage.Limit
+		out  time.Time
+	}{
+		"default limit": {
+			curr: usage.Limit{Count: 10},
+			out:  now.AddDate(0, 0, 30),
+		},
+		"prolong the active purchase": {
+			curr: usage.Limit{Count: 100, UserId: "user0", Expires: now.AddDate(0, 0, 5)},
+			out:  now.AddDate(0, 0, 35),
+		},
+		"expired purchase": {
+			curr: usage.Limit{Count: 100, UserId: "user0", Expires: now.AddDate(0, 0, -1)},
+			out:  now.AddDate(0, 0, 30),
+		},
+		"lower user limit": {
+			curr: usage.Limit{Count: 50, UserId: "user0", Expires: now.AddDate(0, 0, 5)},
+			out:  now.AddDate(0, 0, 30),
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			assert.Equal(t, c.out, expires(c.curr, tier, now))
+		})
+	}
+}
+
+func TestHandler_Replay(t *testing.T) {
+	day0 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	day10 := day0.AddDate(0, 0, 10)
+	subj := usage.SubjectPublishDaily
+	cases := map[string]struct {
+		purs map[string]Purchase
+		out  usage.Limit
+		exps map[string]time.Time
+	}{
+		"no purchases left": {
+			purs: map[string]Purchase{
+				"charge0": {TgUserId: 1, Subject: subj, Limit: 100, Days: 30, Created: day0, Expires: day0.AddDate(0, 0, 30), Refunded: day10},
+			},
+		},
+		"prolonged purchase loses the refunded days": {
+			purs: map[string]Purchase{
+				"charge0": {TgUserId: 1, Subject: subj, Limit: 100, Days: 30, Created: day0, Expires: day0.AddDate(0, 0, 30), Refunded: day10},
+				"charge1": {TgUserId: 1, Subject: subj, Limit: 100, Days: 30, Created: day10, Expires: day0.AddDate(0, 0, 60)},
+			},
+			out: usage.Limit{Count: 100, UserId: "tg://user?id=1", Expires: day10.AddDate(0, 0, 30)},
+			exps: map[string]time.Time{
+				"charge1": day10.AddDate(0, 0, 30),
+			},
+		},
+		"earlier purchase is left": {
+			purs: map[string]Purchase{
+				"charge0": {TgUserId: 1, Subject: subj, Limit: 100, Days: 30, Created: day0, Expires: day0.AddDate(0, 0, 30)},
+				"charge1": {TgUserId: 1, Subject: subj, Limit: 100, Days: 30, Created: day10, Expires: day0.AddDate(0, 0, 60), Refunded: day10},
+			},
+			out: usage.Limit{Count: 100, UserId: "tg://user?id=1", Expires: day0.AddDate(0, 0, 30)},
+			exps: map[string]time.Time{
+				"charge0": day0.AddDate(0, 0, 30),
+			},
+		},
+		"older purchase without days, other user and subject": {
+			purs: map[string]Purchase{
+				"charge0": {TgUserId: 1, Subject: subj, Limit: 50, Created: day0, Expires: day0.AddDate(0, 0, 7)},
+				"charge1": {TgUserId: 2, Subject: subj, Limit: 100, Days: 30, Created: day10, Expires: day10.AddDate(0, 0, 30)},
+				"charge2": {TgUserId: 1, Subject: usage.SubjectSubscriptions, Limit: 10, Days: 30, Created: day10, Expires: day10.AddDate(0, 0, 30)},
+			},
+			out: usage.Limit{Count: 50, UserId: "tg://user?id=1", Expires: day0.AddDate(0, 0, 7)},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			purs, err := storage.NewFile[Purchase](filepath.Join(t.TempDir(), "purchases.json"))
+			assert.Nil(t, err)
+			for id, pur := range c.purs {
+				assert.Nil(t, purs.Set(id, pur))
+			}
+			h := Handler{
+				Purchases: purs,
+				Log:       slog.New(slog.NewTextHandler(os.Stdout, nil)),
+			}
+			assert.Equal(t, c.out, h.replay(1, subj))
+			for id, exp := range c.exps {
+				pur, _ := purs.Get(id)
+				assert.Equal(t, exp, pur.Expires)
+			}
+		})
+	}
+}
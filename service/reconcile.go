@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/awakari/bot-telegram/model/usage"
+	"github.com/awakari/bot-telegram/service/limits"
+	"github.com/awakari/bot-telegram/util"
+	"gopkg.in/telebot.v3"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PaidLimitsReconciler periodically re-checks the paid channels membership of the users having the limit granted by a
+// paid channel and of the known PaidMember users, and fixes their limits, e.g. when the "chat_member" updates were
+// missed during the downtime. Only the limits recorded as granted by a paid channel are deleted.
+type PaidLimitsReconciler struct {
+	Handler       PaidChatMemberHandler
+	Bot           *telebot.Bot
+	Interval      time.Duration
+	DryRun        bool
+	SupportChatId int64
+	Log           *slog.Logger
+}
+
+type reconcileAction int
+
+const (
+	reconcileKeep reconcileAction = iota
+	reconcileSet
+	reconcileDelete
+)
+
+// ReconcileReport summarizes a single reconciliation run.
+type ReconcileReport struct {
+	Checked int
+	Set     int
+	Deleted int
+	Failed  int
+	Changes []string
+}
+
+const reconcileReportChangesMax = 50
+
+func (r PaidLimitsReconciler) Run(ctx context.Context) {
+	t := time.NewTicker(r.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			rep := r.Reconcile(ctx)
+			r.Log.Info(fmt.Sprintf(
+				"Paid limits reconciliation (dry run: %t): checked %d, set %d, deleted %d, failed %d",
+				r.DryRun, rep.Checked, rep.Set, rep.Deleted, rep.Failed,
+			))
+			if rep.Set > 0 || rep.Deleted > 0 || rep.Failed > 0 {
+				_, err := r.Bot.Send(telebot.ChatID(r.SupportChatId), rep.render(r.DryRun))
+				if err != nil {
+					r.Log.Warn(fmt.Sprintf("Failed to send the reconciliation report to the support chat: %s", err))
+				}
+			}
+		}
+	}
+}
+
+// Reconcile walks all users having the paid channel limits and all known paid members once.
+func (r PaidLimitsReconciler) Reconcile(ctx context.Context) (rep ReconcileReport) {
+	var tgUserIds []int64
+	seen := map[int64]bool{}
+	if r.Handler.LimitRecords != nil {
+		r.Handler.LimitRecords.Each(func(_ string, rec limits.Record) bool {
+			tgUserId, ok := util.AwakariToTelegramUserId(rec.UserId)
+			if ok && rec.GroupId == r.Handler.GroupId && rec.Source == limits.SourcePaidChannel && !seen[tgUserId] {
+				seen[tgUserId] = true
+				tgUserIds = append(tgUserIds, tgUserId)
+			}
+			return true
+		})
+	}
+	r.Handler.Members.Each(func(_ string, m PaidMember) bool {
+		if !seen[m.TgUserId] {
+			seen[m.TgUserId] = true
+			tgUserIds = append(tgUserIds, m.TgUserId)
+		}
+		return true
+	})
+	limitsByChatId := map[usage.Subject]map[int64]int64{
+		usage.SubjectSubscriptions:   r.Handler.LimitByChatIdSubscriptions,
+		usage.SubjectInterests:       r.Handler.LimitByChatIdInterests,
+		usage.SubjectInterestsPublic: r.Handler.LimitByChatIdInterestsPublic,
+	}
+	for _, tgUserId := range tgUserIds {
+		if ctx.Err() != nil {
+			break
+		}
+		rep.Checked++
+		user := &telebot.User{
+			ID: tgUserId,
+		}
+		userId := util.TelegramToAwakariUserId(tgUserId)
+		var paid, failed bool
+		for subj, limitByChatId := range limitsByChatId {
+			_, limitMax, err := r.Handler.memberChannels(r.Bot, user, limitByChatId)
+			var curr usage.Limit
+			if err == nil {
+				curr, err = r.Handler.SvcLimits.Get(ctx, r.Handler.GroupId, userId, subj)
+			}
+			if err != nil {
+				failed = true
+				rep.Failed++
+				r.Log.Warn(fmt.Sprintf("Paid limits reconciliation failure for %s, %s: %s", userId, subj.Description(), err))
+				continue
+			}
+			paid = paid || limitMax > 0
+			rec, found := r.Handler.limitRecord(userId, subj)
+			granted := found && rec.Source == limits.SourcePaidChannel && rec.Count == curr.Count
+			switch reconcileLimit(curr, limitMax, granted) {
+			case reconcileSet:
+				if !r.DryRun {
+					err = r.Handler.SvcLimits.Set(ctx, r.Handler.GroupId, userId, subj, limitMax, noExpiration)
+				}
+				if err == nil {
+					rep.Set++
+					rep.change(fmt.Sprintf("%s: %s %d → %d", userId, subj.Description(), curr.Count, limitMax))
+				}
+			case reconcileDelete:
+				if !r.DryRun {
+					err = r.Handler.SvcLimits.Delete(ctx, r.Handler.GroupId, userId, subj)
+				}
+				if err == nil {
+					rep.Deleted++
+					rep.change(fmt.Sprintf("%s: %s %d → default", userId, subj.Description(), curr.Count))
+				}
+			}
+			if err != nil {
+				failed = true
+				rep.Failed++
+				r.Log.Error(fmt.Sprintf("Paid limits reconciliation update failure for %s, %s: %s", userId, subj.Description(), err))
+			}
+		}
+		if !paid && !failed && !r.DryRun {
+			// not a member of any paid channel anymore, nothing to reconcile until the next "chat_member" update
+			_ = r.Handler.Members.Delete(strconv.FormatInt(tgUserId, 10))
+		}
+	}
+	return
+}
+
+// reconcileLimit decides what to do with the current limit given the maximum limit of the paid channels the user is
+// a member of. Like the PaidChatMemberHandler, it never decreases the limit. Only the limit granted by a paid channel
+// is deleted, the purchased and the admin ones are kept.
+func reconcileLimit(curr usage.Limit, limitMax int64, granted bool) (a reconcileAction) {
+	switch {
+	case limitMax > curr.Count:
+		a = reconcileSet
+	case limitMax == 0 && granted && curr.UserId != "" && curr.Expires.IsZero():
+		a = reconcileDelete
+	}
+	return
+}
+
+func (rep *ReconcileReport) change(line string) {
+	if len(rep.Changes) < reconcileReportChangesMax {
+		rep.Changes = append(rep.Changes, line)
+	}
+}
+
+func (rep ReconcileReport) render(dryRun bool) (txt string) {
+	var sb strings.Builder
+	sb.WriteString("Paid limits reconciliation")
+	if dryRun {
+		sb.WriteString(" (dry run)")
+	}
+	sb.WriteString(fmt.Sprintf(
+		"\nChecked: %d, set: %d, deleted: %d, failed: %d\n",
+		rep.Checked, rep.Set, rep.Deleted, rep.Failed,
+	))
+	for _, c := range rep.Changes {
+		sb.WriteString(c)
+		sb.WriteString("\n")
+	}
+	if rep.Set+rep.Deleted > len(rep.Changes) {
+		sb.WriteString("...\n")
+	}
+	txt = sb.String()
+	return
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"github.com/awakari/bot-telegram/model/usage"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestReconcileLimit(t *testing.T) {
+	cases := map[string]struct {
+		curr     usage.Limit
+		limitMax int64
+		granted  bool
+		out      reconcileAction
+	}{
+		"default limit, no paid channels": {
+			curr: usage.Limit{Count: 2},
+		},
+		"default limit, paid channel": {
+			curr:     usage.Limit{Count: 2},
+			limitMax: 10,
+			out:      reconcileSet,
+		},
+		"paid channel limit is up to date": {
+			curr:     usage.Limit{Count: 10, UserId: "user0"},
+			limitMax: 10,
+		},
+		"paid channel limit is greater": {
+			curr:     usage.Limit{Count: 5, UserId: "user0"},
+			limitMax: 10,
+			out:      reconcileSet,
+		},
+		"left the paid channel": {
+			curr:    usage.Limit{Count: 10, UserId: "user0"},
+			granted: true,
+			out:     reconcileDelete,
+		},
+		"admin limit is kept": {
+			curr: usage.Limit{Count: 10, UserId: "user0"},
+		},
+		"purchased limit is kept": {
+			curr: usage.Limit{Count: 100, UserId: "user0", Expires: time.Now().Add(time.Hour)},
+		},
+		"purchased limit is greater than paid channel one": {
+			curr:     usage.Limit{Count: 100, UserId: "user0", Expires: time.Now().Add(time.Hour)},
+			limitMax: 10,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			assert.Equal(t, c.out, reconcileLimit(c.curr, c.limitMax, c.granted))
+		})
+	}
+}
+
+func TestReconcileReport_Render(t *testing.T) {
+	rep := ReconcileReport{
+		Checked: 3,
+		Set:     1,
+		Deleted: 1,
+		Changes: []string{
+			"tg://user?id=1: Subscriptions 2 → 10",
+			"tg://user?id=2: Subscriptions 10 → default",
+		},
+	}
+	assert.Equal(
+		t,
+		"Paid limits reconciliation (dry run)\n"+
+			"Checked: 3, set: 1, deleted: 1, failed: 0\n"+
+			"tg://user?id=1: Subscriptions 2 → 10\n"+
+			"tg://user?id=2: Subscriptions 10 → default\n",
+		rep.render(true),
+	)
+}
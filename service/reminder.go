@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/awakari/bot-telegram/model/usage"
+	"github.com/awakari/bot-telegram/service/limits"
+	"github.com/awakari/bot-telegram/service/storage"
+	"github.com/awakari/bot-telegram/util"
+	"gopkg.in/telebot.v3"
+	"log/slog"
+	"time"
+)
+
+// LimitReminder notifies the users in the private chat about the limits expiring soon and about the limits reset to
+// default after the expiration. It walks the limits recorded by the bot having the expiration, whatever the source:
+// purchased, set by the admin for a number of days, etc.
+type LimitReminder struct {
+	SvcLimits limits.Service
+	Tiers     usage.Tiers
+	GroupId   string
+	Records   storage.Storage[limits.Record]
+
+	// Reminded keeps the expiration time the user has been reminded about by the limit record key.
+	Reminded storage.Storage[time.Time]
+
+	Bot      Sender
+	Interval time.Duration
+	Ahead    time.Duration
+	Log      *slog.Logger
+}
+
+// expiresPrecision tolerates the timestamp rounding by the limits service.
+const expiresPrecision = time.Second
+
+func (r LimitReminder) Run(ctx context.Context) {
+	t := time.NewTicker(r.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			r.remind(ctx, time.Now().UTC())
+		}
+	}
+}
+
+func (r LimitReminder) remind(ctx context.Context, now time.Time) {
+	recs := map[string]limits.Record{}
+	r.Records.Each(func(k string, rec limits.Record) bool {
+		if rec.GroupId == r.GroupId && !rec.Expires.IsZero() && !rec.Expires.After(now.Add(r.Ahead)) {
+			recs[k] = rec
+		}
+		return true
+	})
+	for k, rec := range recs {
+		if ctx.Err() != nil {
+			return
+		}
+		tgUserId, ok := util.AwakariToTelegramUserId(rec.UserId)
+		var err error
+		switch {
+		case !ok:
+		case rec.Expires.After(now):
+			if exp, found := r.Reminded.Get(k); !found || !exp.Equal(rec.Expires) {
+				err = r.remindExpiring(ctx, k, rec, tgUserId)
+			}
+		default:
+			err = r.notifyExpired(ctx, k, rec, tgUserId, now)
+		}
+		if err != nil {
+			r.Log.Warn(fmt.Sprintf("Failed to notify the user %s about the %s limit expiration: %s", rec.UserId, rec.Subject.Description(), err))
+		}
+	}
+}
+
+func (r LimitReminder) remindExpiring(ctx context.Context, k string, rec limits.Record, tgUserId int64) (err error) {
+	var l usage.Limit
+	l, err = r.SvcLimits.Get(ctx, r.GroupId, rec.UserId, rec.Subject)
+	if err != nil {
+		return // retry next time
+	}
+	// the limit may be changed not by the bot
+	if l.UserId != "" && l.Count == rec.Count && (l.Expires.Sub(rec.Expires)).Abs() < expiresPrecision {
+		txt := fmt.Sprintf(
+			"%s limit %d expires on %s, then it will be reset to default.",
+			rec.Subject.Description(), rec.Count, rec.Expires.Format(time.DateOnly),
+		)
+		_, err = r.Bot.Send(telebot.ChatID(tgUserId), txt, r.renewMarkup(rec))
+	}
+	if err == nil {
+		err = r.Reminded.Set(k, rec.Expires)
+	}
+	return
+}
+
+func (r LimitReminder) notifyExpired(ctx context.Context, k string, rec limits.Record, tgUserId int64, now time.Time) (err error) {
+	var l usage.Limit
+	l, err = r.SvcLimits.Get(ctx, r.GroupId, rec.UserId, rec.Subject)
+	if err != nil {
+		return // retry next time
+	}
+	// notify only when the limit has fallen back to the default one, not when replaced by another user limit
+	if l.UserId == "" || (!l.Expires.IsZero() && !l.Expires.After(now)) {
+		txt := fmt.Sprintf(
+			"%s limit %d has expired and has been reset to default. Use /usage to see the details.",
+			rec.Subject.Description(), rec.Count,
+		)
+		_, err = r.Bot.Send(telebot.ChatID(tgUserId), txt, r.renewMarkup(rec))
+	}
+	if err == nil {
+		_ = r.Reminded.Delete(k)
+		if curr, found := r.Records.Get(k); found && curr.Updated.Equal(rec.Updated) {
+			err = r.Records.Delete(k)
+		}
+	}
+	return
+}
+
+// renewMarkup returns the button to get the same tier again or nil when there's no such tier.
+func (r LimitReminder) renewMarkup(rec limits.Record) (m *telebot.ReplyMarkup) {
+	t, found := r.Tiers.Find(rec.Subject, rec.Count)
+	if !found {
+		return
+	}
+	btn := telebot.Btn{
+		Text: "Renew",
+	}
+	switch {
+	case t.Url != "":
+		btn.URL = t.Url
+	case t.Stars > 0:
+		btn.Text = fmt.Sprintf("Renew for %d ⭐", t.Stars)
+		btn.Data = LimitBuyData(t)
+	default:
+		return
+	}
+	if t.Price != "" {
+		btn.Text = "Renew for " + t.Price
+	}
+	m = &telebot.ReplyMarkup{}
+	m.Inline(m.Row(btn))
+	return
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"github.com/awakari/bot-telegram/model/usage"
+	"github.com/awakari/bot-telegram/service/limits"
+	"github.com/awakari/bot-telegram/service/storage"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/telebot.v3"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type senderStub struct {
+	msgs *[]string
+}
+
+func (ss senderStub) Send(to telebot.Recipient, what interface{}, opts ...interface{}) (*telebot.Message, error) {
+	*ss.msgs = append(*ss.msgs, to.Recipient()+": "+what.(string))
+	return nil, nil
+}
+
+func TestLimitReminder_Remind(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	userId := "tg://user?id=1"
+	cases := map[string]struct {
+		rec      limits.Record
+		limits   map[usage.Subject]usage.Limit
+		msgs     []string
+		recFound bool
+	}{
+		"far from expiration": {
+			rec: limits.Record{Count: 100, Expires: now.AddDate(0, 0, 10), Source: limits.SourcePurchase},
+			limits: map[usage.Subject]usage.Limit{
+				usage.SubjectPublishDaily: {Count: 100, UserId: userId, Expires: now.AddDate(0, 0, 10)},
+			},
+			recFound: true,
+		},
+		"purchased limit expires soon": {
+			rec: limits.Record{Count: 100, Expires: now.AddDate(0, 0, 2), Source: limits.SourcePurchase},
+			limits: map[usage.Subject]usage.Limit{
+				usage.SubjectPublishDaily: {Count: 100, UserId: userId, Expires: now.AddDate(0, 0, 2)},
+			},
+			msgs: []string{
+				"1: Publishing (daily) limit 100 expires on 2026-10-03, then it will be reset to default.",
+			},
+			recFound: true,
+		},
+		"admin limit expires soon": {
+			rec: limits.Record{Count: 1000, Expires: now.AddDate(0, 0, 1), Source: limits.SourceAdmin},
+			limits: map[usage.Subject]usage.Limit{
+				usage.SubjectPublishDaily: {Count: 1000, UserId: userId, Expires: now.AddDate(0, 0, 1)},
+			},
+			msgs: []string{
+				"1: Publishing (daily) limit 1000 expires on 2026-10-02, then it will be reset to default.",
+			},
+			recFound: true,
+		},
+		"expires soon but changed since": {
+			rec: limits.Record{Count: 100, Expires: now.AddDate(0, 0, 2), Source: limits.SourcePurchase},
+			limits: map[usage.Subject]usage.Limit{
+				usage.SubjectPublishDaily: {Count: 100, UserId: userId, Expires: now.AddDate(0, 0, 32)},
+			},
+			recFound: true,
+		},
+		"no expiration": {
+			rec: limits.Record{Count: 10, Source: limits.SourcePaidChannel},
+			limits: map[usage.Subject]usage.Limit{
+				usage.SubjectPublishDaily: {Count: 10, UserId: userId},
+			},
+			recFound: true,
+		},
+		"expired": {
+			rec: limits.Record{Count: 100, Expires: now.Add(-time.Hour), Source: limits.SourcePurchase},
+			limits: map[usage.Subject]usage.Limit{
+				usage.SubjectPublishDaily: {Count: 10},
+			},
+			msgs: []string{
+				"1: Publishing (daily) limit 100 has expired and has been reset to default. Use /usage to see the details.",
+			},
+		},
+		"expired but replaced by another user limit": {
+			rec: limits.Record{Count: 100, Expires: now.Add(-time.Hour), Source: limits.SourcePurchase},
+			limits: map[usage.Subject]usage.Limit{
+				usage.SubjectPublishDaily: {Count: 200, UserId: userId},
+			},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			records, err := storage.NewFile[limits.Record](filepath.Join(t.TempDir(), "limit-records.json"))
+			assert.Nil(t, err)
+			reminded, err := storage.NewFile[time.Time](filepath.Join(t.TempDir(), "limit-reminders.json"))
+			assert.Nil(t, err)
+			c.rec.GroupId = "group0"
+			c.rec.UserId = userId
+			c.rec.Subject = usage.SubjectPublishDaily
+			key := limits.RecordKey(c.rec.GroupId, c.rec.UserId, c.rec.Subject)
+			assert.Nil(t, records.Set(key, c.rec))
+			var msgs []string
+			r := LimitReminder{
+				SvcLimits: limits.NewMock(c.limits),
+				GroupId:   "group0",
+				Records:   records,
+				Reminded:  reminded,
+				Bot:       senderStub{msgs: &msgs},
+				Ahead:     72 * time.Hour,
+				Log:       slog.New(slog.NewTextHandler(os.Stdout, nil)),
+			}
+			r.remind(context.TODO(), now)
+			assert.Equal(t, c.msgs, msgs)
+			_, found := records.Get(key)
+			assert.Equal(t, c.recFound, found)
+			// the notification is sent once
+			msgs = nil
+			r.remind(context.TODO(), now)
+			assert.Nil(t, msgs)
+		})
+	}
+}
@@ -0,0 +1,8 @@
+package service
+
+import "gopkg.in/telebot.v3"
+
+// Sender is the part of the bot API required to notify the users out of the incoming update context.
+type Sender interface {
+	Send(to telebot.Recipient, what interface{}, opts ...interface{}) (*telebot.Message, error)
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Lease lets only one of the bot instances sharing the storage run the background work at a time.
+type Lease struct {
+	Storage Storage[LeaseHolder]
+	Name    string
+	Holder  string
+	Ttl     time.Duration
+}
+
+// LeaseHolder is the bot instance holding the lease until it expires.
+type LeaseHolder struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+var errLeaseHeld = errors.New("lease is held by another instance")
+
+// Run calls f while the lease is held, the context passed to f is canceled once the lease is lost.
+func (l Lease) Run(ctx context.Context, f func(ctx context.Context)) {
+	t := time.NewTicker(l.Ttl / 3)
+	defer t.Stop()
+	for {
+		if l.acquire(time.Now().UTC()) == nil {
+			l.hold(ctx, t, f)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// hold runs f and keeps prolonging the lease until it's lost or the context is done.
+func (l Lease) hold(ctx context.Context, t *time.Ticker, f func(ctx context.Context)) {
+	ctxHeld, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go f(ctxHeld)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if l.acquire(time.Now().UTC()) != nil {
+				return
+			}
+		}
+	}
+}
+
+// acquire takes the free or the expired lease, or prolongs the own one.
+func (l Lease) acquire(now time.Time) (err error) {
+	err = l.Storage.Update(l.Name, func(h LeaseHolder, found bool) (LeaseHolder, error) {
+		if found && h.Holder != l.Holder && h.Expires.After(now) {
+			return h, errLeaseHeld
+		}
+		return LeaseHolder{
+			Holder:  l.Holder,
+			Expires: now.Add(l.Ttl),
+		}, nil
+	})
+	return
+}
@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLease_Acquire(t *testing.T) {
+	s, err := NewFile[LeaseHolder](filepath.Join(t.TempDir(), "leases.json"))
+	assert.Nil(t, err)
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	l0 := Lease{
+		Storage: s,
+		Name:    "workers",
+		Holder:  "pod0",
+		Ttl:     time.Minute,
+	}
+	l1 := l0
+	l1.Holder = "pod1"
+	assert.Nil(t, l0.acquire(now))
+	assert.ErrorIs(t, l1.acquire(now.Add(30*time.Second)), errLeaseHeld)
+	// prolonged by the holder
+	assert.Nil(t, l0.acquire(now.Add(30*time.Second)))
+	assert.ErrorIs(t, l1.acquire(now.Add(80*time.Second)), errLeaseHeld)
+	// expired
+	assert.Nil(t, l1.acquire(now.Add(2*time.Minute)))
+	assert.ErrorIs(t, l0.acquire(now.Add(2*time.Minute)), errLeaseHeld)
+}
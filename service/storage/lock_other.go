@@ -0,0 +1,13 @@
+//go:build !unix
+
+package storage
+
+import "os"
+
+// lockFile is a no-op where the advisory file locks are not available, the storage is not shared then.
+func lockFile(f *os.File, exclusive bool) (err error) {
+	return
+}
+
+func unlockFile(f *os.File) {
+}
@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bytedance/sonic"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Storage is a small key-value store for the bot's own state. The state survives the restarts and is shared between
+// the bot instances when kept on a shared persistent volume.
+type Storage[T any] interface {
+	Get(key string) (v T, found bool)
+	Set(key string, v T) (err error)
+	Delete(key string) (err error)
+
+	// Each calls f for every stored entry ordered by key until f returns false.
+	Each(f func(key string, v T) bool)
+
+	// Update replaces the entry with the one returned by f atomically, even across the bot instances. Nothing is
+	// changed when f returns an error, the error is returned as it is.
+	Update(key string, f func(v T, found bool) (T, error)) (err error)
+}
+
+type file[T any] struct {
+	path     string
+	lock     *sync.Mutex
+	lockFile *os.File
+	entries  map[string]T
+	info     os.FileInfo
+}
+
+var ErrStorage = errors.New("storage failure")
+
+// NewFile returns the Storage keeping the entries in memory and rewriting the whole file on every change. Every
+// access is guarded by the advisory lock of the ".lock" file next to the storage file, and the entries are reloaded
+// whenever the file has been rewritten by another bot instance.
+func NewFile[T any](path string) (s Storage[T], err error) {
+	f := &file[T]{
+		path:    path,
+		lock:    &sync.Mutex{},
+		entries: map[string]T{},
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o750)
+	if err == nil {
+		f.lockFile, err = os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0o640)
+	}
+	if err == nil {
+		err = f.locked(false, func() error {
+			return nil
+		})
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %s: %s", ErrStorage, path, err)
+	}
+	s = f
+	return
+}
+
+func (f *file[T]) Get(key string) (v T, found bool) {
+	_ = f.locked(false, func() (err error) {
+		v, found = f.entries[key]
+		return
+	})
+	return
+}
+
+func (f *file[T]) Set(key string, v T) (err error) {
+	err = f.Update(key, func(_ T, _ bool) (T, error) {
+		return v, nil
+	})
+	return
+}
+
+func (f *file[T]) Delete(key string) (err error) {
+	err = f.locked(true, func() (err error) {
+		prev, found := f.entries[key]
+		if found {
+			delete(f.entries, key)
+			err = f.flush()
+			if err != nil {
+				f.entries[key] = prev
+			}
+		}
+		return
+	})
+	return
+}
+
+func (f *file[T]) Update(key string, fn func(v T, found bool) (T, error)) (err error) {
+	err = f.locked(true, func() (err error) {
+		prev, found := f.entries[key]
+		var v T
+		v, err = fn(prev, found)
+		if err == nil {
+			f.entries[key] = v
+			err = f.flush()
+			if err != nil {
+				switch found {
+				case true:
+					f.entries[key] = prev
+				default:
+					delete(f.entries, key)
+				}
+			}
+		}
+		return
+	})
+	return
+}
+
+func (f *file[T]) Each(fn func(key string, v T) bool) {
+	var keys []string
+	var entries map[string]T
+	_ = f.locked(false, func() error {
+		keys = make([]string, 0, len(f.entries))
+		entries = make(map[string]T, len(f.entries))
+		for k, v := range f.entries {
+			keys = append(keys, k)
+			entries[k] = v
+		}
+		return nil
+	})
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !fn(k, entries[k]) {
+			break
+		}
+	}
+}
+
+// locked calls fn holding both the in-process and the file locks, after reloading the entries when changed.
+func (f *file[T]) locked(exclusive bool, fn func() error) (err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	err = lockFile(f.lockFile, exclusive)
+	if err == nil {
+		defer unlockFile(f.lockFile)
+		err = f.reload()
+	}
+	if err == nil {
+		err = fn()
+	}
+	return
+}
+
+// reload reads the entries again when the file has been replaced since the last read or write.
+func (f *file[T]) reload() (err error) {
+	var info os.FileInfo
+	info, err = os.Stat(f.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		err = nil
+	case err != nil:
+	case f.info != nil && os.SameFile(f.info, info) && f.info.ModTime().Equal(info.ModTime()):
+	default:
+		var data []byte
+		data, err = os.ReadFile(f.path)
+		entries := map[string]T{}
+		if err == nil && len(data) > 0 {
+			err = sonic.Unmarshal(data, &entries)
+		}
+		if err == nil {
+			f.entries = entries
+			f.info = info
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %s: %s", ErrStorage, f.path, err)
+	}
+	return
+}
+
+// flush writes the entries to a temporary file first, so the storage file is never left partially written.
+func (f *file[T]) flush() (err error) {
+	var data []byte
+	data, err = sonic.Marshal(f.entries)
+	tmp := f.path + ".tmp"
+	if err == nil {
+		err = os.WriteFile(tmp, data, 0o640)
+	}
+	if err == nil {
+		err = os.Rename(tmp, f.path)
+	}
+	if err == nil {
+		f.info, err = os.Stat(f.path)
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %s: %s", ErrStorage, f.path, err)
+	}
+	return
+}
+
+// Count returns the number of the stored entries.
+func Count[T any](s Storage[T]) (n int) {
+	s.Each(func(_ string, _ T) bool {
+		n++
+		return true
+	})
+	return
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type entry struct {
+	Name  string
+	Count int
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "entries.json")
+	s, err := NewFile[entry](path)
+	assert.Nil(t, err)
+	assert.Nil(t, s.Set("b", entry{Name: "bar", Count: 2}))
+	assert.Nil(t, s.Set("a", entry{Name: "foo", Count: 1}))
+	assert.Nil(t, s.Set("c", entry{Name: "baz"}))
+	assert.Nil(t, s.Delete("c"))
+	assert.Nil(t, s.Delete("missing"))
+	//
+	s, err = NewFile[entry](path)
+	assert.Nil(t, err)
+	v, found := s.Get("a")
+	assert.True(t, found)
+	assert.Equal(t, entry{Name: "foo", Count: 1}, v)
+	_, found = s.Get("c")
+	assert.False(t, found)
+	var keys []string
+	s.Each(func(k string, v entry) bool {
+		keys = append(keys, k)
+		return true
+	})
+	assert.Equal(t, []string{"a", "b"}, keys)
+	keys = nil
+	s.Each(func(k string, v entry) bool {
+		keys = append(keys, k)
+		return false
+	})
+	assert.Equal(t, []string{"a"}, keys)
+}
+
+func TestFile_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "entries.json")
+	assert.Nil(t, os.WriteFile(path, []byte("{"), 0o600))
+	_, err := NewFile[entry](path)
+	assert.ErrorIs(t, err, ErrStorage)
+}
+
+func TestFile_Shared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "entries.json")
+	s0, err := NewFile[entry](path)
+	assert.Nil(t, err)
+	s1, err := NewFile[entry](path)
+	assert.Nil(t, err)
+	assert.Nil(t, s0.Set("a", entry{Name: "foo", Count: 1}))
+	assert.Nil(t, s1.Set("b", entry{Name: "bar", Count: 2}))
+	v, found := s0.Get("b")
+	assert.True(t, found)
+	assert.Equal(t, entry{Name: "bar", Count: 2}, v)
+	assert.Equal(t, 2, Count(s1))
+	assert.Nil(t, s0.Delete("a"))
+	_, found = s1.Get("a")
+	assert.False(t, found)
+}
+
+func TestFile_Update(t *testing.T) {
+	s, err := NewFile[entry](filepath.Join(t.TempDir(), "entries.json"))
+	assert.Nil(t, err)
+	inc := func(v entry, found bool) (entry, error) {
+		v.Count++
+		return v, nil
+	}
+	assert.Nil(t, s.Update("a", inc))
+	assert.Nil(t, s.Update("a", inc))
+	errTest := errors.New("test")
+	assert.ErrorIs(t, s.Update("a", func(v entry, found bool) (entry, error) {
+		return entry{}, errTest
+	}), errTest)
+	v, _ := s.Get("a")
+	assert.Equal(t, entry{Count: 2}, v)
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/awakari/bot-telegram/api/http/subscriptions"
+	"github.com/awakari/bot-telegram/model"
+	"github.com/awakari/bot-telegram/service/storage"
+	"google.golang.org/grpc/metadata"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChatSubscriber is the user having the subscriptions linked to the chat, optionally bound to the chat topic.
+type ChatSubscriber struct {
+	ChatId   int64     `json:"chatId"`
+	ThreadId int       `json:"threadId"`
+	UserId   string    `json:"userId"`
+	Created  time.Time `json:"created"`
+}
+
+// ChatSubscribers is the local registry of the chat subscribers. The subscriptions API lists the subscriptions of the
+// requesting user only, so the registry is the way to find all the subscriptions linked to the chat. The subscribers
+// are recorded on subscribe and on delivery, so the subscriptions created before the registry become known once they
+// deliver anything.
+type ChatSubscribers struct {
+	Storage storage.Storage[ChatSubscriber]
+}
+
+// WalkFunc is called for every subscription found with the exact subscription callback url.
+type WalkFunc func(ctx context.Context, userId, interestId, urlCallback string) (err error)
+
+// ErrWalkStop is returned by the WalkFunc to stop walking the subscriptions. The subscription is counted as failed,
+// the error itself is not returned by the walk.
+var ErrWalkStop = errors.New("stop walking")
+
+type trackingSubscriptions struct {
+	subscriptions.Service
+	subscribers     ChatSubscribers
+	urlCallbackBase string
+}
+
+var errCallbackUrlUnresolved = errors.New("subscription callback url not resolved")
+
+// Add records the chat subscriber unless already known.
+func (cs ChatSubscribers) Add(chatId int64, threadId int, userId string) (err error) {
+	k := chatSubscriberKey(chatId, userId, threadId)
+	if _, found := cs.Storage.Get(k); !found {
+		err = cs.Storage.Set(k, ChatSubscriber{
+			ChatId:   chatId,
+			ThreadId: threadId,
+			UserId:   userId,
+			Created:  time.Now().UTC(),
+		})
+	}
+	return
+}
+
+// Users returns the known subscribers of the chat with the topics they are subscribed in.
+func (cs ChatSubscribers) Users(chatId int64) (threadIdsByUserId map[string][]int) {
+	threadIdsByUserId = map[string][]int{}
+	prefix := strconv.FormatInt(chatId, 10) + " "
+	cs.Storage.Each(func(k string, s ChatSubscriber) bool {
+		if strings.HasPrefix(k, prefix) {
+			threadIdsByUserId[s.UserId] = append(threadIdsByUserId[s.UserId], s.ThreadId)
+		}
+		return true
+	})
+	return
+}
+
+// Forget removes the subscriber of the chat in all the chat topics.
+func (cs ChatSubscribers) Forget(chatId int64, userId string) (err error) {
+	prefix := fmt.Sprintf("%d %s ", chatId, userId)
+	var keys []string
+	cs.Storage.Each(func(k string, _ ChatSubscriber) bool {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+		return true
+	})
+	for _, k := range keys {
+		err = errors.Join(err, cs.Storage.Delete(k))
+	}
+	return
+}
+
+// TrackSubscribers returns the subscriptions service recording the ChatSubscribers on every subscribe.
+func TrackSubscribers(svc subscriptions.Service, cs ChatSubscribers, urlCallbackBase string) subscriptions.Service {
+	return trackingSubscriptions{
+		Service:         svc,
+		subscribers:     cs,
+		urlCallbackBase: urlCallbackBase,
+	}
+}
+
+func (ts trackingSubscriptions) Subscribe(ctx context.Context, interestId, groupId, userId, url string, interval time.Duration) (err error) {
+	err = ts.Service.Subscribe(ctx, interestId, groupId, userId, url, interval)
+	if err == nil || errors.Is(err, subscriptions.ErrConflict) {
+		chatId, threadId, _, errUrl := subscriptions.ParseCallbackUrl(ts.urlCallbackBase, url)
+		if errUrl == nil {
+			_ = ts.subscribers.Add(chatId, threadId, userId) // the best effort, the delivery will record it anyway
+		}
+	}
+	return
+}
+
+// WalkChat calls the function for every subscription linked to the chat, regardless of the subscriber.
+func WalkChat(
+	svcSubs subscriptions.Service,
+	cs ChatSubscribers,
+	urlCallbackBase, groupId string,
+	chatId int64,
+	f WalkFunc,
+) (
+	done, failed []string,
+	err error,
+) {
+	users := cs.Users(chatId)
+	userIds := make([]string, 0, len(users))
+	for userId := range users {
+		userIds = append(userIds, userId)
+	}
+	sort.Strings(userIds)
+	for _, userId := range userIds {
+		var stopped bool
+		doneUser, failedUser, errUser := WalkChatUser(svcSubs, cs, urlCallbackBase, groupId, userId, chatId, 0,
+			func(ctx context.Context, userId, interestId, urlCallback string) (err error) {
+				err = f(ctx, userId, interestId, urlCallback)
+				stopped = errors.Is(err, ErrWalkStop)
+				return
+			},
+		)
+		done = append(done, doneUser...)
+		failed = append(failed, failedUser...)
+		err = errors.Join(err, errUser)
+		if stopped {
+			break
+		}
+	}
+	return
+}
+
+// WalkChatUser calls the function for every subscription of the user linked to the chat. Besides the topics known from
+// the ChatSubscribers, the subscriptions bound to the specified topic are looked for, unless it's 0.
+func WalkChatUser(
+	svcSubs subscriptions.Service,
+	cs ChatSubscribers,
+	urlCallbackBase, groupId, userId string,
+	chatId int64,
+	threadId int,
+	f WalkFunc,
+) (
+	done, failed []string,
+	err error,
+) {
+	ctx := context.TODO()
+	groupIdCtx := metadata.AppendToOutgoingContext(ctx, model.KeyGroupId, groupId)
+	cbUrlPrefix := subscriptions.MakeCallbackUrl(urlCallbackBase, chatId, "")
+	urlsCallback := callbackUrls(urlCallbackBase, chatId, userId, append(cs.Users(chatId)[userId], threadId))
+	var errItems error
+	var cursor string
+	var count int
+	var stopped bool
+	for !stopped {
+		var interestIds []string
+		interestIds, err = svcSubs.InterestsByUrl(groupIdCtx, groupId, userId, PageLimit, cbUrlPrefix, cursor)
+		if errors.Is(err, subscriptions.ErrNotFound) {
+			err = nil
+		}
+		if err != nil {
+			break
+		}
+		count += len(interestIds)
+		for _, interestId := range interestIds {
+			errItem := walkSubscription(ctx, svcSubs, groupId, userId, interestId, urlsCallback, f)
+			switch {
+			case errItem == nil:
+				done = append(done, interestId)
+			case errors.Is(errItem, ErrWalkStop):
+				failed = append(failed, interestId)
+				stopped = true
+			default:
+				failed = append(failed, interestId)
+				errItems = errors.Join(errItems, fmt.Errorf("subscription to %s: %w", interestId, errItem))
+			}
+			if stopped {
+				break
+			}
+		}
+		if len(interestIds) < PageLimit {
+			break
+		}
+		cursor = interestIds[len(interestIds)-1]
+	}
+	if err == nil && count == 0 {
+		_ = cs.Forget(chatId, userId) // nothing left in the chat, the best effort
+	}
+	err = errors.Join(err, errItems)
+	return
+}
+
+// walkSubscription calls the function for every candidate callback url the interest is subscribed with. The same
+// interest may be subscribed in several topics of the chat.
+func walkSubscription(
+	ctx context.Context,
+	svcSubs subscriptions.Service,
+	groupId, userId, interestId string,
+	urlsCallback []string,
+	f WalkFunc,
+) (err error) {
+	var found bool
+	for _, urlCallback := range urlsCallback {
+		_, errSub := svcSubs.Subscription(ctx, interestId, groupId, userId, urlCallback)
+		if errSub == nil {
+			found = true
+			errF := f(ctx, userId, interestId, urlCallback)
+			if errors.Is(errF, ErrWalkStop) {
+				err = errF
+				return
+			}
+			err = errors.Join(err, errF)
+		}
+	}
+	if !found {
+		err = errCallbackUrlUnresolved
+	}
+	return
+}
+
+// callbackUrls returns the callback urls a subscription linked to the chat may have: bound to the topics, w/o the
+// topic and the legacy one w/o the user id parameter.
+func callbackUrls(urlCallbackBase string, chatId int64, userId string, threadIds []int) (urls []string) {
+	threadIds = slices.Clone(threadIds)
+	slices.Sort(threadIds)
+	for _, threadId := range slices.Compact(threadIds) {
+		if threadId != 0 {
+			urls = append(urls, subscriptions.MakeTopicCallbackUrl(urlCallbackBase, chatId, threadId, userId))
+		}
+	}
+	urls = append(
+		urls,
+		subscriptions.MakeCallbackUrl(urlCallbackBase, chatId, userId),
+		subscriptions.MakeCallbackUrl(urlCallbackBase, chatId, ""),
+	)
+	return
+}
+
+func chatSubscriberKey(chatId int64, userId string, threadId int) string {
+	return fmt.Sprintf("%d %s %d", chatId, userId, threadId)
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"github.com/awakari/bot-telegram/api/http/subscriptions"
+	"github.com/awakari/bot-telegram/service/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+const urlCallbackBaseTest = "http://bot-telegram:8081/v1/chat"
+
+func newChatSubscribers(t *testing.T) ChatSubscribers {
+	s, err := storage.NewFile[ChatSubscriber](filepath.Join(t.TempDir(), "chat-subscribers.json"))
+	require.Nil(t, err)
+	return ChatSubscribers{
+		Storage: s,
+	}
+}
+
+func TestTrackSubscribers(t *testing.T) {
+	cs := newChatSubscribers(t)
+	svc := TrackSubscribers(subscriptions.NewMock(map[string]map[string][]string{}), cs, urlCallbackBaseTest)
+	ctx := context.TODO()
+	assert.Nil(t, svc.Subscribe(ctx, "interest0", "group0", "user0", subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, "user0"), 0))
+	assert.Nil(t, svc.Subscribe(ctx, "interest1", "group0", "user1", subscriptions.MakeTopicCallbackUrl(urlCallbackBaseTest, -1001, 42, "user1"), 0))
+	assert.Nil(t, svc.Subscribe(ctx, "interest1", "group0", "user1", subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1002, "user1"), 0))
+	assert.Nil(t, svc.Subscribe(ctx, "interest2", "group0", "user2", "https://example.com/callback", 0))
+	assert.Equal(t, map[string][]int{"user0": {0}, "user1": {42}}, cs.Users(-1001))
+	assert.Equal(t, map[string][]int{"user1": {0}}, cs.Users(-1002))
+	assert.Nil(t, cs.Forget(-1001, "user1"))
+	assert.Equal(t, map[string][]int{"user0": {0}}, cs.Users(-1001))
+}
+
+func TestWalkChat(t *testing.T) {
+	cs := newChatSubscribers(t)
+	require.Nil(t, cs.Add(-1001, 0, "user0"))
+	require.Nil(t, cs.Add(-1001, 0, "user1"))
+	require.Nil(t, cs.Add(-1001, 0, "user2"))
+	require.Nil(t, cs.Add(-1001, 0, "user3"))
+	require.Nil(t, cs.Add(-1001, 7, "user4"))
+	svcSubs := subscriptions.NewMock(map[string]map[string][]string{
+		"user0": {
+			"interest0": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, "user0")},
+			"interest1": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1002, "user0")},
+		},
+		"user1": {
+			"interest1": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, "")},
+		},
+		"user2": {
+			"interest2": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -10010, "user2")},
+		},
+		"user4": {
+			"interest3": {
+				subscriptions.MakeTopicCallbackUrl(urlCallbackBaseTest, -1001, 7, "user4"),
+				subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, "user4"),
+			},
+		},
+	})
+	var walked []string
+	done, failed, err := WalkChat(
+		svcSubs, cs, urlCallbackBaseTest, "group0", -1001,
+		func(ctx context.Context, userId, interestId, urlCallback string) (err error) {
+			walked = append(walked, userId+" "+interestId+" "+urlCallback)
+			return
+		},
+	)
+	assert.Equal(t, []string{"interest0", "interest1", "interest3"}, done)
+	assert.Equal(t, []string{"interest2"}, failed)
+	assert.ErrorIs(t, err, errCallbackUrlUnresolved)
+	assert.Equal(
+		t,
+		[]string{
+			"user0 interest0 " + subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, "user0"),
+			"user1 interest1 " + subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, ""),
+			"user4 interest3 " + subscriptions.MakeTopicCallbackUrl(urlCallbackBaseTest, -1001, 7, "user4"),
+			"user4 interest3 " + subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, "user4"),
+		},
+		walked,
+	)
+	// user3 has nothing in the chat and is forgotten
+	assert.Equal(t, map[string][]int{"user0": {0}, "user1": {0}, "user2": {0}, "user4": {7}}, cs.Users(-1001))
+}
+
+func TestWalkChat_Stop(t *testing.T) {
+	cs := newChatSubscribers(t)
+	require.Nil(t, cs.Add(-1001, 0, "user0"))
+	require.Nil(t, cs.Add(-1001, 0, "user1"))
+	svcSubs := subscriptions.NewMock(map[string]map[string][]string{
+		"user0": {
+			"interest0": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, "user0")},
+			"interest1": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, "user0")},
+			"interest2": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, "user0")},
+		},
+		"user1": {
+			"interest3": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, "user1")},
+		},
+	})
+	var walked []string
+	done, failed, err := WalkChat(
+		svcSubs, cs, urlCallbackBaseTest, "group0", -1001,
+		func(ctx context.Context, userId, interestId, urlCallback string) (err error) {
+			walked = append(walked, interestId)
+			if interestId == "interest1" {
+				err = ErrWalkStop
+			}
+			return
+		},
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"interest0"}, done)
+	assert.Equal(t, []string{"interest1"}, failed)
+	assert.Equal(t, []string{"interest0", "interest1"}, walked)
+}
@@ -0,0 +1,442 @@
+package subscriptions
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	protoInterests "github.com/awakari/bot-telegram/api/grpc/interests"
+	"github.com/awakari/bot-telegram/api/http/interests"
+	"github.com/awakari/bot-telegram/model/interest"
+	"github.com/awakari/bot-telegram/model/interest/condition"
+	"github.com/awakari/bot-telegram/model/usage"
+	"github.com/awakari/bot-telegram/service"
+	"github.com/awakari/bot-telegram/util"
+	"github.com/bytedance/sonic"
+	"gopkg.in/telebot.v3"
+	"html"
+	"io"
+	"strings"
+	"time"
+)
+
+const ReqInterestsImport = "interests_import"
+const backupVersion = 1
+const backupFileName = "interests.json"
+const backupFileNameOpml = "interests.opml"
+const backupOutlineType = "awakari-interest"
+const backupCaptionDryRun = "dry"
+const msgInterestsImport = "Importing own interests. Reply the previously exported file (JSON or OPML) to the next message. " +
+	"Add the <code>dry</code> caption to the file to validate it without creating anything."
+
+var errBackupInvalid = errors.New("invalid interests file")
+
+type backupDoc struct {
+	Version   uint32           `json:"version"`
+	Created   time.Time        `json:"created"`
+	Interests []backupInterest `json:"interests"`
+}
+
+type backupInterest struct {
+	Id          string          `json:"id,omitempty"`
+	Description string          `json:"description"`
+	Enabled     bool            `json:"enabled"`
+	Public      bool            `json:"public,omitempty"`
+	Expires     *time.Time      `json:"expires,omitempty"`
+	Condition   backupCondition `json:"condition"`
+}
+
+type backupCondition struct {
+	Not      bool                     `json:"not,omitempty"`
+	Group    *backupGroupCondition    `json:"group,omitempty"`
+	Text     *backupTextCondition     `json:"text,omitempty"`
+	Number   *backupNumberCondition   `json:"number,omitempty"`
+	Semantic *backupSemanticCondition `json:"semantic,omitempty"`
+}
+
+type backupGroupCondition struct {
+	Logic    string            `json:"logic"`
+	Children []backupCondition `json:"children"`
+}
+
+type backupTextCondition struct {
+	Key   string `json:"key,omitempty"`
+	Term  string `json:"term"`
+	Exact bool   `json:"exact,omitempty"`
+}
+
+type backupNumberCondition struct {
+	Key string  `json:"key"`
+	Op  string  `json:"op"`
+	Val float64 `json:"val"`
+}
+
+type backupSemanticCondition struct {
+	Query string `json:"query"`
+}
+
+type backupOpml struct {
+	XMLName xml.Name          `xml:"opml"`
+	Version string            `xml:"version,attr"`
+	Title   string            `xml:"head>title"`
+	Created string            `xml:"head>dateCreated"`
+	Items   []backupOpmlEntry `xml:"body>outline"`
+}
+
+type backupOpmlEntry struct {
+	Text      string `xml:"text,attr"`
+	Type      string `xml:"type,attr"`
+	Id        string `xml:"id,attr,omitempty"`
+	Enabled   bool   `xml:"enabled,attr"`
+	Public    bool   `xml:"public,attr,omitempty"`
+	Expires   string `xml:"expires,attr,omitempty"`
+	Condition string `xml:"condition,attr"`
+}
+
+func ExportInterests(svcInterests interests.Service, groupId string) telebot.HandlerFunc {
+	return func(tgCtx telebot.Context) (err error) {
+		ctx := context.TODO()
+		userId := util.SenderToUserId(tgCtx)
+		doc := backupDoc{
+			Version: backupVersion,
+			Created: time.Now().UTC(),
+		}
+		q := interest.Query{
+			Limit: service.PageLimit,
+		}
+		var cursor condition.Cursor
+		for {
+			var page []*protoInterests.Interest
+			page, err = svcInterests.Search(ctx, groupId, userId, q, cursor)
+			if errors.Is(err, interests.ErrNotFound) {
+				err = nil
+			}
+			if err != nil || len(page) == 0 {
+				break
+			}
+			for _, i := range page {
+				var sd interest.Data
+				sd, err = svcInterests.Read(ctx, groupId, userId, i.Id)
+				if err != nil {
+					err = fmt.Errorf("failed to read the interest %s: %w", i.Id, err)
+					break
+				}
+				doc.Interests = append(doc.Interests, encodeBackupInterest(i.Id, sd))
+			}
+			if err != nil || len(page) < service.PageLimit {
+				break
+			}
+			cursor.Id = page[len(page)-1].Id
+		}
+		var data, dataOpml []byte
+		if err == nil {
+			data, err = sonic.ConfigDefault.MarshalIndent(doc, "", "  ")
+		}
+		if err == nil {
+			dataOpml, err = encodeBackupOpml(doc)
+		}
+		if err == nil {
+			err = tgCtx.Send(&telebot.Document{
+				File:     telebot.FromReader(bytes.NewReader(data)),
+				FileName: backupFileName,
+				MIME:     "application/json",
+				Caption:  fmt.Sprintf("Own interests exported: %d", len(doc.Interests)),
+			})
+		}
+		if err == nil {
+			err = tgCtx.Send(&telebot.Document{
+				File:     telebot.FromReader(bytes.NewReader(dataOpml)),
+				FileName: backupFileNameOpml,
+				MIME:     "text/x-opml",
+			})
+		}
+		return
+	}
+}
+
+func ImportInterestsRequest(tgCtx telebot.Context) (err error) {
+	_ = tgCtx.Send(msgInterestsImport, telebot.ModeHTML)
+	err = tgCtx.Send(ReqInterestsImport, &telebot.ReplyMarkup{
+		ForceReply:  true,
+		Placeholder: backupFileName,
+	})
+	return
+}
+
+func ImportInterestsReplyHandlerFunc(svcInterests interests.Service, limitReached service.LimitReached, groupId string) service.ArgHandlerFunc {
+	return func(tgCtx telebot.Context, args ...string) (err error) {
+		var doc backupDoc
+		doc, err = readBackupDoc(tgCtx)
+		if err != nil {
+			return
+		}
+		dryRun := strings.TrimSpace(tgCtx.Message().Caption) == backupCaptionDryRun
+		var report strings.Builder
+		var countOk, countFailed int
+		var reached bool
+		for _, bi := range doc.Interests {
+			sd, errDecode := decodeBackupInterest(bi)
+			if errDecode == nil {
+				errDecode = validateSubscriptionData(sd)
+			}
+			if errDecode == nil && !sd.Expires.IsZero() && sd.Expires.Before(time.Now()) {
+				errDecode = errors.New("expired")
+			}
+			if errDecode == nil && !dryRun {
+				_, errDecode = create(tgCtx, svcInterests, groupId, sd)
+			}
+			switch errDecode {
+			case nil:
+				countOk++
+				report.WriteString(fmt.Sprintf("✓ %s\n", html.EscapeString(bi.Description)))
+			default:
+				countFailed++
+				report.WriteString(fmt.Sprintf("✗ %s: %s\n", html.EscapeString(bi.Description), html.EscapeString(errDecode.Error())))
+			}
+			if errors.Is(errDecode, errLimitReached) {
+				reached = true
+				break
+			}
+		}
+		summary := "Interests imported"
+		if dryRun {
+			summary = "Dry run, interests valid"
+		}
+		report.WriteString(fmt.Sprintf(msgFmtBulkResult, summary, countOk, countFailed))
+		err = tgCtx.Send(report.String(), telebot.ModeHTML)
+		if err == nil && reached {
+			err = limitReached.Send(tgCtx, util.SenderToUserId(tgCtx), usage.SubjectInterests)
+		}
+		return
+	}
+}
+
+func readBackupDoc(tgCtx telebot.Context) (doc backupDoc, err error) {
+	msg := tgCtx.Message()
+	if msg == nil || msg.Document == nil {
+		err = fmt.Errorf("%w: reply with the exported interests file", errBackupInvalid)
+		return
+	}
+	if msg.Document.FileSize > importFileSizeMax {
+		err = fmt.Errorf("%w: file size %d exceeds the limit %d", errBackupInvalid, msg.Document.FileSize, importFileSizeMax)
+		return
+	}
+	var r io.ReadCloser
+	r, err = tgCtx.Bot().File(&msg.Document.File)
+	var data []byte
+	if err == nil {
+		defer r.Close()
+		data, err = io.ReadAll(io.LimitReader(r, importFileSizeMax))
+	}
+	if err == nil {
+		doc, err = decodeBackupDoc(data)
+	}
+	return
+}
+
+func decodeBackupDoc(data []byte) (doc backupDoc, err error) {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(data, []byte("<")):
+		doc, err = decodeBackupOpml(data)
+	default:
+		err = sonic.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %s", errBackupInvalid, err)
+	}
+	if err == nil && doc.Version != backupVersion {
+		err = fmt.Errorf("%w: unsupported version %d", errBackupInvalid, doc.Version)
+	}
+	return
+}
+
+func encodeBackupOpml(doc backupDoc) (data []byte, err error) {
+	o := backupOpml{
+		Version: "2.0",
+		Title:   fmt.Sprintf("Awakari interests v%d", doc.Version),
+		Created: doc.Created.Format(time.RFC1123Z),
+	}
+	for _, bi := range doc.Interests {
+		var cond []byte
+		cond, err = sonic.Marshal(bi.Condition)
+		if err != nil {
+			break
+		}
+		e := backupOpmlEntry{
+			Text:      bi.Description,
+			Type:      backupOutlineType,
+			Id:        bi.Id,
+			Enabled:   bi.Enabled,
+			Public:    bi.Public,
+			Condition: string(cond),
+		}
+		if bi.Expires != nil {
+			e.Expires = bi.Expires.Format(time.RFC3339)
+		}
+		o.Items = append(o.Items, e)
+	}
+	if err == nil {
+		data, err = xml.MarshalIndent(o, "", "  ")
+	}
+	if err == nil {
+		data = append([]byte(xml.Header), data...)
+	}
+	return
+}
+
+func decodeBackupOpml(data []byte) (doc backupDoc, err error) {
+	var o backupOpml
+	err = xml.Unmarshal(data, &o)
+	if err == nil {
+		doc.Version = backupVersion
+		created, _ := time.Parse(time.RFC1123Z, o.Created)
+		doc.Created = created.UTC()
+	}
+	for _, e := range o.Items {
+		if e.Type != backupOutlineType {
+			continue
+		}
+		bi := backupInterest{
+			Id:          e.Id,
+			Description: e.Text,
+			Enabled:     e.Enabled,
+			Public:      e.Public,
+		}
+		if e.Expires != "" {
+			var expires time.Time
+			expires, err = time.Parse(time.RFC3339, e.Expires)
+			expires = expires.UTC()
+			bi.Expires = &expires
+		}
+		if err == nil {
+			err = sonic.UnmarshalString(e.Condition, &bi.Condition)
+		}
+		if err != nil {
+			err = fmt.Errorf("outline %q: %w", e.Text, err)
+			break
+		}
+		doc.Interests = append(doc.Interests, bi)
+	}
+	return
+}
+
+func encodeBackupInterest(id string, sd interest.Data) (bi backupInterest) {
+	bi = backupInterest{
+		Id:          id,
+		Description: sd.Description,
+		Enabled:     sd.Enabled,
+		Public:      sd.Public,
+		Condition:   encodeBackupCondition(sd.Condition),
+	}
+	if !sd.Expires.IsZero() {
+		expires := sd.Expires.UTC()
+		bi.Expires = &expires
+	}
+	return
+}
+
+func decodeBackupInterest(bi backupInterest) (sd interest.Data, err error) {
+	sd.Description = bi.Description
+	sd.Enabled = bi.Enabled
+	sd.Public = bi.Public
+	if bi.Expires != nil {
+		sd.Expires = *bi.Expires
+	}
+	sd.Condition, err = decodeBackupCondition(bi.Condition)
+	return
+}
+
+func encodeBackupCondition(src condition.Condition) (dst backupCondition) {
+	dst.Not = src.IsNot()
+	switch c := src.(type) {
+	case condition.GroupCondition:
+		dst.Group = &backupGroupCondition{
+			Logic: c.GetLogic().String(),
+		}
+		for _, child := range c.GetGroup() {
+			dst.Group.Children = append(dst.Group.Children, encodeBackupCondition(child))
+		}
+	case condition.TextCondition:
+		dst.Text = &backupTextCondition{
+			Key:   c.GetKey(),
+			Term:  c.GetTerm(),
+			Exact: c.IsExact(),
+		}
+	case condition.NumberCondition:
+		dst.Number = &backupNumberCondition{
+			Key: c.GetKey(),
+			Op:  c.GetOperation().String(),
+			Val: c.GetValue(),
+		}
+	case condition.SemanticCondition:
+		dst.Semantic = &backupSemanticCondition{
+			Query: c.Query(),
+		}
+	}
+	return
+}
+
+func decodeBackupCondition(src backupCondition) (dst condition.Condition, err error) {
+	switch {
+	case src.Group != nil:
+		var logic condition.GroupLogic
+		logic, err = decodeBackupGroupLogic(src.Group.Logic)
+		var children []condition.Condition
+		for _, child := range src.Group.Children {
+			if err != nil {
+				break
+			}
+			var childDst condition.Condition
+			childDst, err = decodeBackupCondition(child)
+			children = append(children, childDst)
+		}
+		if err == nil {
+			dst = condition.NewGroupCondition(condition.NewCondition(src.Not), logic, children)
+		}
+	case src.Text != nil:
+		dst = condition.NewTextCondition(
+			condition.NewKeyCondition(condition.NewCondition(src.Not), src.Text.Key),
+			src.Text.Term,
+			src.Text.Exact,
+		)
+	case src.Number != nil:
+		var op condition.NumOp
+		op, err = decodeBackupNumOp(src.Number.Op)
+		if err == nil {
+			dst = condition.NewNumberCondition(
+				condition.NewKeyCondition(condition.NewCondition(src.Not), src.Number.Key),
+				op,
+				src.Number.Val,
+			)
+		}
+	case src.Semantic != nil:
+		dst = condition.NewSemanticCondition(condition.NewCondition(src.Not), "", src.Semantic.Query)
+	default:
+		err = fmt.Errorf("%w: empty condition", errInvalidCondition)
+	}
+	return
+}
+
+func decodeBackupGroupLogic(src string) (dst condition.GroupLogic, err error) {
+	for _, l := range []condition.GroupLogic{condition.GroupLogicAnd, condition.GroupLogicOr, condition.GroupLogicXor} {
+		if l.String() == src {
+			dst = l
+			return
+		}
+	}
+	err = fmt.Errorf("%w: unknown group logic %q", errInvalidCondition, src)
+	return
+}
+
+func decodeBackupNumOp(src string) (dst condition.NumOp, err error) {
+	for op := condition.NumOpGt; op <= condition.NumOpLt; op++ {
+		if op.String() == src {
+			dst = op
+			return
+		}
+	}
+	err = fmt.Errorf("%w: unknown number operation %q", errInvalidCondition, src)
+	return
+}
@@ -0,0 +1,108 @@
+package subscriptions
+
+import (
+	"github.com/awakari/bot-telegram/model/interest/condition"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestBackupCondition_RoundTrip(t *testing.T) {
+	cases := map[string]condition.Condition{
+		"text": condition.NewTextCondition(
+			condition.NewKeyCondition(condition.NewCondition(false), ""),
+			"tesla iphone",
+			false,
+		),
+		"number": condition.NewNumberCondition(
+			condition.NewKeyCondition(condition.NewCondition(true), "price"),
+			condition.NumOpLte,
+			42.5,
+		),
+		"semantic": condition.NewSemanticCondition(condition.NewCondition(false), "", "electric cars"),
+		"group": condition.NewGroupCondition(
+			condition.NewCondition(false),
+			condition.GroupLogicOr,
+			[]condition.Condition{
+				condition.NewTextCondition(
+					condition.NewKeyCondition(condition.NewCondition(false), "title"),
+					"bitcoin",
+					true,
+				),
+				condition.NewGroupCondition(
+					condition.NewCondition(true),
+					condition.GroupLogicXor,
+					[]condition.Condition{
+						condition.NewNumberCondition(
+							condition.NewKeyCondition(condition.NewCondition(false), "year"),
+							condition.NumOpGt,
+							2020,
+						),
+					},
+				),
+			},
+		),
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			decoded, err := decodeBackupCondition(encodeBackupCondition(c))
+			assert.Nil(t, err)
+			assert.Equal(t, c, decoded)
+		})
+	}
+}
+
+func TestDecodeBackupDoc(t *testing.T) {
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	doc := backupDoc{
+		Version: backupVersion,
+		Created: time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC),
+		Interests: []backupInterest{
+			{
+				Id:          "interest0",
+				Description: "Wishlist <1>",
+				Enabled:     true,
+				Public:      true,
+				Expires:     &expires,
+				Condition: backupCondition{
+					Text: &backupTextCondition{
+						Term: "tesla iphone",
+					},
+				},
+			},
+		},
+	}
+	opml, err := encodeBackupOpml(doc)
+	assert.Nil(t, err)
+	cases := map[string]struct {
+		data []byte
+		doc  backupDoc
+		err  error
+	}{
+		"opml": {
+			data: opml,
+			doc:  doc,
+		},
+		"json": {
+			data: []byte(`{"version":1,"created":"2024-05-06T07:08:09Z","interests":[{"id":"interest0","description":"Wishlist <1>","enabled":true,"public":true,"expires":"2030-01-02T03:04:05Z","condition":{"text":{"term":"tesla iphone"}}}]}`),
+			doc:  doc,
+		},
+		"unsupported version": {
+			data: []byte(`{"version":2}`),
+			err:  errBackupInvalid,
+		},
+		"garbage": {
+			data: []byte(`foo`),
+			err:  errBackupInvalid,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			result, err := decodeBackupDoc(c.data)
+			assert.ErrorIs(t, err, c.err)
+			if c.err == nil {
+				assert.Equal(t, c.doc, result)
+			}
+		})
+	}
+}
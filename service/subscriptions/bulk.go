@@ -0,0 +1,299 @@
+package subscriptions
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/awakari/bot-telegram/api/http/interests"
+	"github.com/awakari/bot-telegram/api/http/subscriptions"
+	"github.com/awakari/bot-telegram/model/usage"
+	"github.com/awakari/bot-telegram/service"
+	"github.com/awakari/bot-telegram/util"
+	"github.com/bytedance/sonic"
+	"gopkg.in/telebot.v3"
+	"html"
+	"io"
+)
+
+const CmdStopAll = "subs_stop_all"
+const CmdCopyAll = "subs_copy"
+const ReqCopyAll = "subs_copy"
+const CmdExport = "subs_export"
+const CmdImport = "subs_import"
+const ReqImport = "subs_import"
+const argConfirm = "yes"
+const exportVersion = 1
+const exportFileName = "subscriptions.json"
+const importFileSizeMax = 1 << 20
+const msgFmtBulkResult = "%s: %d, failed: %d"
+
+type exportDoc struct {
+	Version       uint32         `json:"version"`
+	Subscriptions []exportRecord `json:"subscriptions"`
+}
+
+type exportRecord struct {
+	InterestId  string `json:"interestId"`
+	Description string `json:"description,omitempty"`
+}
+
+var errImportFileMissing = errors.New("reply with the exported subscriptions file")
+var errImportInvalid = errors.New("invalid subscriptions file")
+var errChatNotOwned = errors.New("you should be an administrator of the target chat")
+var errChatBotMissing = errors.New("the bot should be a member of the target chat")
+
+func bulkButtons(m *telebot.ReplyMarkup) []telebot.Row {
+	return []telebot.Row{
+		m.Row(
+			telebot.Btn{
+				Text: "⏹ Stop All",
+				Data: CmdStopAll,
+			},
+			telebot.Btn{
+				Text: "⧉ Copy All",
+				Data: CmdCopyAll,
+			},
+		),
+		m.Row(
+			telebot.Btn{
+				Text: "⬇ Export",
+				Data: CmdExport,
+			},
+			telebot.Btn{
+				Text: "⬆ Import",
+				Data: CmdImport,
+			},
+		),
+	}
+}
+
+func StopAll(
+	svcSubs subscriptions.Service,
+	chatSubscribers service.ChatSubscribers,
+	urlCallbackBase, groupId string,
+) service.ArgHandlerFunc {
+	return func(tgCtx telebot.Context, args ...string) (err error) {
+		if len(args) == 0 || args[0] != argConfirm {
+			err = tgCtx.Send("Stop all subscriptions in this chat?", &telebot.ReplyMarkup{
+				InlineKeyboard: [][]telebot.InlineButton{
+					{
+						telebot.InlineButton{
+							Text: "Yes, stop all",
+							Data: CmdStopAll + " " + argConfirm,
+						},
+					},
+				},
+			})
+			return
+		}
+		userId := util.SenderToUserId(tgCtx)
+		var stopped, failed []string
+		stopped, failed, err = service.WalkChatUser(
+			svcSubs, chatSubscribers, urlCallbackBase, groupId, userId, tgCtx.Chat().ID, service.ThreadId(tgCtx),
+			func(ctx context.Context, userId, interestId, urlCallback string) error {
+				return svcSubs.Unsubscribe(ctx, interestId, groupId, userId, urlCallback)
+			},
+		)
+		errSend := tgCtx.Send(fmt.Sprintf(msgFmtBulkResult, "Subscriptions stopped", len(stopped), len(failed)))
+		err = errors.Join(err, errSend)
+		return
+	}
+}
+
+func CopyAllRequest(tgCtx telebot.Context, args ...string) (err error) {
+	_ = tgCtx.Send("Copying all subscriptions from this chat. Reply the target chat @username or numeric id to the next message.")
+	err = tgCtx.Send(ReqCopyAll, &telebot.ReplyMarkup{
+		ForceReply:  true,
+		Placeholder: "@chat",
+	})
+	return
+}
+
+func CopyAllReplyHandlerFunc(
+	svcSubs subscriptions.Service,
+	chatSubscribers service.ChatSubscribers,
+	limitReached service.LimitReached,
+	urlCallbackBase, groupId string,
+) service.ArgHandlerFunc {
+	return func(tgCtx telebot.Context, args ...string) (err error) {
+		if len(args) < 2 {
+			err = errors.New("target chat is missing")
+			return
+		}
+		var chatDst *telebot.Chat
+		chatDst, err = tgCtx.Bot().ChatByUsername(args[len(args)-1])
+		if err == nil {
+			err = verifyChatOwned(tgCtx, chatDst)
+		}
+		if err != nil {
+			return
+		}
+		userId := util.SenderToUserId(tgCtx)
+		urlCallbackDst := subscriptions.MakeCallbackUrl(urlCallbackBase, chatDst.ID, userId)
+		var copied, failed []string
+		var exhausted bool
+		copied, failed, err = service.WalkChatUser(
+			svcSubs, chatSubscribers, urlCallbackBase, groupId, userId, tgCtx.Chat().ID, service.ThreadId(tgCtx),
+			func(ctx context.Context, userId, interestId, _ string) (err error) {
+				err = svcSubs.Subscribe(ctx, interestId, groupId, userId, urlCallbackDst, 0)
+				switch {
+				case errors.Is(err, subscriptions.ErrConflict):
+					err = nil // already subscribed in the target chat
+				case errors.Is(err, subscriptions.ErrPermitExhausted):
+					exhausted = true
+					err = service.ErrWalkStop // no sense to try the rest
+				}
+				return
+			},
+		)
+		errSend := tgCtx.Send(fmt.Sprintf(msgFmtBulkResult, "Subscriptions copied to "+html.EscapeString(chatDst.Title), len(copied), len(failed)))
+		err = errors.Join(err, errSend)
+		if exhausted {
+			err = errors.Join(err, limitReached.Send(tgCtx, userId, usage.SubjectSubscriptions))
+		}
+		return
+	}
+}
+
+func Export(
+	svcInterests interests.Service,
+	svcSubs subscriptions.Service,
+	chatSubscribers service.ChatSubscribers,
+	urlCallbackBase, groupId string,
+) service.ArgHandlerFunc {
+	return func(tgCtx telebot.Context, args ...string) (err error) {
+		userId := util.SenderToUserId(tgCtx)
+		doc := exportDoc{
+			Version: exportVersion,
+		}
+		_, _, err = service.WalkChatUser(
+			svcSubs, chatSubscribers, urlCallbackBase, groupId, userId, tgCtx.Chat().ID, service.ThreadId(tgCtx),
+			func(ctx context.Context, userId, interestId, _ string) (err error) {
+				rec := exportRecord{
+					InterestId: interestId,
+				}
+				i, errRead := svcInterests.Read(ctx, groupId, userId, interestId)
+				if errRead == nil {
+					rec.Description = i.Description
+				}
+				doc.Subscriptions = append(doc.Subscriptions, rec)
+				return
+			},
+		)
+		var data []byte
+		if err == nil {
+			data, err = sonic.ConfigDefault.MarshalIndent(doc, "", "  ")
+		}
+		if err == nil {
+			err = tgCtx.Send(&telebot.Document{
+				File:     telebot.FromReader(bytes.NewReader(data)),
+				FileName: exportFileName,
+				MIME:     "application/json",
+				Caption:  fmt.Sprintf("Subscriptions exported: %d", len(doc.Subscriptions)),
+			})
+		}
+		return
+	}
+}
+
+func ImportRequest(tgCtx telebot.Context, args ...string) (err error) {
+	_ = tgCtx.Send("Importing subscriptions to this chat. Reply the previously exported file to the next message.")
+	err = tgCtx.Send(ReqImport, &telebot.ReplyMarkup{
+		ForceReply:  true,
+		Placeholder: exportFileName,
+	})
+	return
+}
+
+func ImportReplyHandlerFunc(
+	svcSubs subscriptions.Service,
+	limitReached service.LimitReached,
+	urlCallbackBase, groupId string,
+) service.ArgHandlerFunc {
+	return func(tgCtx telebot.Context, args ...string) (err error) {
+		var doc exportDoc
+		doc, err = readExportDoc(tgCtx)
+		if err != nil {
+			return
+		}
+		ctx := context.TODO()
+		userId := util.SenderToUserId(tgCtx)
+		urlCallback := subscriptions.MakeTopicCallbackUrl(urlCallbackBase, tgCtx.Chat().ID, service.ThreadId(tgCtx), userId)
+		var countImported, countFailed int
+		for _, rec := range doc.Subscriptions {
+			errSub := svcSubs.Subscribe(ctx, rec.InterestId, groupId, userId, urlCallback, 0)
+			switch {
+			case errSub == nil, errors.Is(errSub, subscriptions.ErrConflict):
+				countImported++
+			case errors.Is(errSub, subscriptions.ErrPermitExhausted):
+				err = errors.Join(err, limitReached.Send(tgCtx, userId, usage.SubjectSubscriptions))
+			default:
+				countFailed++
+				err = errors.Join(err, fmt.Errorf("failed to subscribe to %s: %w", rec.InterestId, errSub))
+			}
+			if errors.Is(errSub, subscriptions.ErrPermitExhausted) {
+				break
+			}
+		}
+		errSend := tgCtx.Send(fmt.Sprintf(msgFmtBulkResult, "Subscriptions imported", countImported, countFailed))
+		err = errors.Join(err, errSend)
+		return
+	}
+}
+
+func readExportDoc(tgCtx telebot.Context) (doc exportDoc, err error) {
+	msg := tgCtx.Message()
+	if msg == nil || msg.Document == nil {
+		err = errImportFileMissing
+		return
+	}
+	if msg.Document.FileSize > importFileSizeMax {
+		err = fmt.Errorf("%w: file size %d exceeds the limit %d", errImportInvalid, msg.Document.FileSize, importFileSizeMax)
+		return
+	}
+	var r io.ReadCloser
+	r, err = tgCtx.Bot().File(&msg.Document.File)
+	var data []byte
+	if err == nil {
+		defer r.Close()
+		data, err = io.ReadAll(io.LimitReader(r, importFileSizeMax))
+	}
+	if err == nil {
+		err = sonic.Unmarshal(data, &doc)
+		if err != nil {
+			err = fmt.Errorf("%w: %s", errImportInvalid, err)
+		}
+	}
+	if err == nil && doc.Version != exportVersion {
+		err = fmt.Errorf("%w: unsupported version %d", errImportInvalid, doc.Version)
+	}
+	return
+}
+
+// verifyChatOwned ensures the sender may manage the target chat and the bot is able to deliver messages there.
+func verifyChatOwned(tgCtx telebot.Context, ch *telebot.Chat) (err error) {
+	sender := tgCtx.Sender()
+	switch ch.Type {
+	case telebot.ChatPrivate:
+		if ch.ID != sender.ID {
+			err = errChatNotOwned
+		}
+	case telebot.ChatChannel, telebot.ChatChannelPrivate:
+		err = service.VerifyChannelAdmins(tgCtx, ch)
+	default:
+		bot := tgCtx.Bot()
+		var member *telebot.ChatMember
+		member, err = bot.ChatMemberOf(ch, sender)
+		if err == nil && member.Role != telebot.Creator && member.Role != telebot.Administrator {
+			err = errChatNotOwned
+		}
+		if err == nil {
+			member, err = bot.ChatMemberOf(ch, bot.Me)
+		}
+		if err == nil && (member.Role == telebot.Left || member.Role == telebot.Kicked) {
+			err = errChatBotMissing
+		}
+	}
+	return
+}
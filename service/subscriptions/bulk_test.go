@@ -0,0 +1,168 @@
+package subscriptions
+
+import (
+	"context"
+	"fmt"
+	"github.com/awakari/bot-telegram/api/http/subscriptions"
+	"github.com/awakari/bot-telegram/service"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/telebot.v3"
+	"testing"
+	"time"
+)
+
+type subsPermitted struct {
+	subscriptions.Service
+	countMax int
+	count    *int
+}
+
+func (sp subsPermitted) Subscribe(ctx context.Context, interestId, groupId, userId, url string, interval time.Duration) (err error) {
+	switch {
+	case interestId == "fail":
+		err = fmt.Errorf("%w: unexpected", subscriptions.ErrInternal)
+	case *sp.count >= sp.countMax:
+		err = subscriptions.ErrPermitExhausted
+	default:
+		err = sp.Service.Subscribe(ctx, interestId, groupId, userId, url, interval)
+		*sp.count++
+	}
+	return
+}
+
+func TestStopAll(t *testing.T) {
+	b, sent := newBotTest(t)
+	cs := newChatSubscribersTest(t)
+	urls := map[string]map[string][]string{
+		"tg://user?id=1": {
+			"interest0": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, "tg://user?id=1")},
+			"interest1": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, "")},
+			"interest2": {subscriptions.MakeTopicCallbackUrl(urlCallbackBaseTest, -1001, 7, "tg://user?id=1")},
+			"interest3": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1002, "tg://user?id=1")},
+		},
+		"tg://user?id=2": {
+			"interest0": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, "tg://user?id=2")},
+		},
+	}
+	svcSubs := subscriptions.NewMock(urls)
+	tgCtx := b.NewContext(telebot.Update{
+		Message: &telebot.Message{
+			Sender:       &telebot.User{ID: 1},
+			Chat:         &telebot.Chat{ID: -1001},
+			ThreadID:     7,
+			TopicMessage: true,
+		},
+	})
+	f := StopAll(svcSubs, cs, urlCallbackBaseTest, "group0")
+	assert.Nil(t, f(tgCtx))
+	assert.Equal(t, []sentMessage{{ChatId: "-1001", Text: "Stop all subscriptions in this chat?"}}, sent())
+	assert.Nil(t, f(tgCtx, argConfirm))
+	assert.Equal(t, sentMessage{ChatId: "-1001", Text: "Subscriptions stopped: 3, failed: 0"}, sent()[1])
+	assert.Equal(t, map[string]map[string][]string{
+		"tg://user?id=1": {
+			"interest0": {},
+			"interest1": {},
+			"interest2": {},
+			"interest3": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1002, "tg://user?id=1")},
+		},
+		"tg://user?id=2": {
+			"interest0": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, "tg://user?id=2")},
+		},
+	}, urls)
+}
+
+func TestImportReplyHandlerFunc(t *testing.T) {
+	cases := map[string]struct {
+		doc      string
+		countMax int
+		imported int
+		sent     []string
+		err      error
+	}{
+		"all imported": {
+			doc:      `{"version":1,"subscriptions":[{"interestId":"interest0"},{"interestId":"interest1"}]}`,
+			countMax: 10,
+			imported: 2,
+			sent: []string{
+				"Subscriptions imported: 2, failed: 0",
+			},
+		},
+		"failure and limit reached": {
+			doc:      `{"version":1,"subscriptions":[{"interestId":"interest0"},{"interestId":"fail"},{"interestId":"interest1"}]}`,
+			countMax: 1,
+			imported: 1,
+			sent: []string{
+				"Subscriptions limit reached. Use /usage to see the details.",
+				"Subscriptions imported: 1, failed: 1",
+			},
+			err: subscriptions.ErrInternal,
+		},
+		"unsupported version": {
+			doc: `{"version":2,"subscriptions":[{"interestId":"interest0"}]}`,
+			err: errImportInvalid,
+		},
+		"invalid file": {
+			doc: `interest0`,
+			err: errImportInvalid,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			b, sent := newBotTestFiles(t, map[string][]byte{
+				"file0": []byte(c.doc),
+			})
+			urls := map[string]map[string][]string{}
+			var count int
+			svcSubs := subsPermitted{
+				Service:  subscriptions.NewMock(urls),
+				countMax: c.countMax,
+				count:    &count,
+			}
+			tgCtx := b.NewContext(telebot.Update{
+				Message: &telebot.Message{
+					Sender: &telebot.User{ID: 1},
+					Chat:   &telebot.Chat{ID: -1001},
+					Document: &telebot.Document{
+						File: telebot.File{FileID: "file0", FileSize: int64(len(c.doc))},
+					},
+				},
+			})
+			err := ImportReplyHandlerFunc(svcSubs, service.LimitReached{}, urlCallbackBaseTest, "group0")(tgCtx)
+			assert.ErrorIs(t, err, c.err)
+			assert.Equal(t, c.imported, count)
+			var txts []string
+			for _, msg := range sent() {
+				txts = append(txts, msg.Text)
+			}
+			assert.Equal(t, c.sent, txts)
+		})
+	}
+}
+
+func TestReadExportDoc(t *testing.T) {
+	b, _ := newBotTest(t)
+	cases := map[string]struct {
+		msg *telebot.Message
+		err error
+	}{
+		"no file": {
+			msg: &telebot.Message{Chat: &telebot.Chat{ID: 1}},
+			err: errImportFileMissing,
+		},
+		"file too large": {
+			msg: &telebot.Message{
+				Chat: &telebot.Chat{ID: 1},
+				Document: &telebot.Document{
+					File: telebot.File{FileID: "file0", FileSize: importFileSizeMax + 1},
+				},
+			},
+			err: errImportInvalid,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			_, err := readExportDoc(b.NewContext(telebot.Update{Message: c.msg}))
+			assert.ErrorIs(t, err, c.err)
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	protoInterests "github.com/awakari/bot-telegram/api/grpc/interests"
+	"github.com/awakari/bot-telegram/api/http/interests"
+	"github.com/awakari/bot-telegram/api/http/subscriptions"
+	"github.com/awakari/bot-telegram/model/interest"
+	"github.com/awakari/bot-telegram/model/interest/condition"
+	"github.com/awakari/bot-telegram/model/usage"
+	"github.com/awakari/bot-telegram/service"
+	"github.com/awakari/bot-telegram/util"
+	"gopkg.in/telebot.v3"
+	"html"
+	"strconv"
+	"time"
+)
+
+const ReqChanLink = "chan_link"
+const CmdChanSub = "chan_sub"
+const ReqChanSub = "chan_sub"
+const CmdChanStop = "chan_stop"
+const CmdChanPageNext = "chan_next"
+const msgChanLink = "Delivering interests to a channel you administer. " +
+	"Add this bot to the channel as an administrator allowed to post messages first. " +
+	"Then reply the channel @username or numeric id to the next message."
+const msgFmtChanList = "Own interests list. Select one or more to deliver to the channel %s:"
+const msgFmtChanLinked = "Subscribed to the interest %s in the channel %s. " +
+	"New results will be posted there with a minimum interval of %s."
+
+var errChanPrivateOnly = errors.New("use this command in the private chat with the bot")
+var errInvalidChanArgs = errors.New("invalid channel command arguments")
+
+func ChannelLinkRequest(tgCtx telebot.Context) (err error) {
+	if tgCtx.Chat().Type != telebot.ChatPrivate {
+		err = errChanPrivateOnly
+		return
+	}
+	_ = tgCtx.Send(msgChanLink)
+	err = tgCtx.Send(ReqChanLink, &telebot.ReplyMarkup{
+		ForceReply:  true,
+		Placeholder: "@channel",
+	})
+	return
+}
+
+func ChannelLinkReplyHandlerFunc(
+	svcInterests interests.Service,
+	svcSubs subscriptions.Service,
+	groupId, urlCallbackBase string,
+) service.ArgHandlerFunc {
+	return func(tgCtx telebot.Context, args ...string) (err error) {
+		if len(args) < 2 {
+			err = errors.New("channel is missing")
+			return
+		}
+		var ch *telebot.Chat
+		ch, err = tgCtx.Bot().ChatByUsername(args[len(args)-1])
+		if err == nil {
+			err = service.VerifyChannelAdmins(tgCtx, ch)
+		}
+		var m *telebot.ReplyMarkup
+		if err == nil {
+			m, err = listButtonsChannel(tgCtx, svcInterests, svcSubs, groupId, urlCallbackBase, ch.ID, "")
+		}
+		if err == nil {
+			err = tgCtx.Send(fmt.Sprintf(msgFmtChanList, html.EscapeString(ch.Title)), m, telebot.ModeHTML)
+		}
+		return
+	}
+}
+
+func ChannelPageNext(
+	svcInterests interests.Service,
+	svcSubs subscriptions.Service,
+	groupId, urlCallbackBase string,
+) service.ArgHandlerFunc {
+	return func(tgCtx telebot.Context, args ...string) (err error) {
+		if len(args) < 2 {
+			err = fmt.Errorf("%w: %+v", errInvalidChanArgs, args)
+			return
+		}
+		var chanId int64
+		chanId, err = strconv.ParseInt(args[0], 10, 64)
+		var m *telebot.ReplyMarkup
+		if err == nil {
+			m, err = listButtonsChannel(tgCtx, svcInterests, svcSubs, groupId, urlCallbackBase, chanId, args[1])
+		}
+		if err == nil {
+			err = tgCtx.Send("Interests list page:", m)
+		}
+		return
+	}
+}
+
+// ChannelStartHandler handles both the interest selection callback and the reply with the minimum interval.
+func ChannelStartHandler(
+	svcInterests interests.Service,
+	svcSubs subscriptions.Service,
+	limitReached service.LimitReached,
+	groupId, urlCallbackBase string,
+) service.ArgHandlerFunc {
+	return func(tgCtx telebot.Context, args ...string) (err error) {
+		switch len(args) {
+		case 2:
+			_ = tgCtx.Send("Reply a minimum notification interval to the command below, for example `0`, `1s`, `2m` or `3h`:", telebot.ModeMarkdownV2)
+			err = tgCtx.Send(fmt.Sprintf("%s %s %s", ReqChanSub, args[0], args[1]), &telebot.ReplyMarkup{
+				ForceReply:  true,
+				Placeholder: "0",
+			})
+		case 4:
+			var chanId int64
+			chanId, err = strconv.ParseInt(args[1], 10, 64)
+			var interval time.Duration
+			if err == nil {
+				interval, err = time.ParseDuration(args[3])
+				if err != nil || interval < 0 {
+					err = fmt.Errorf("invalid interval value: %s", args[3])
+				}
+			}
+			if err == nil {
+				err = startChannel(tgCtx, svcInterests, svcSubs, limitReached, groupId, urlCallbackBase, chanId, args[2], interval)
+			}
+		default:
+			err = fmt.Errorf("%w: %+v", errInvalidChanArgs, args)
+		}
+		return
+	}
+}
+
+func ChannelStop(svcSubs subscriptions.Service, groupId, urlCallbackBase string) service.ArgHandlerFunc {
+	return func(tgCtx telebot.Context, args ...string) (err error) {
+		if len(args) < 2 {
+			err = fmt.Errorf("%w: %+v", errInvalidChanArgs, args)
+			return
+		}
+		var ch *telebot.Chat
+		ch, err = service.ChannelById(tgCtx, args[0])
+		if err == nil {
+			err = service.VerifyChannelAdmins(tgCtx, ch)
+		}
+		if err == nil {
+			userId := util.SenderToUserId(tgCtx)
+			urlCallback := subscriptions.MakeCallbackUrl(urlCallbackBase, ch.ID, userId)
+			err = svcSubs.Unsubscribe(context.TODO(), args[1], groupId, userId, urlCallback)
+		}
+		if err == nil {
+			err = tgCtx.Send(fmt.Sprintf("Unsubscribed from the interest in the channel %s", ch.Title))
+		}
+		return
+	}
+}
+
+func startChannel(
+	tgCtx telebot.Context,
+	svcInterests interests.Service,
+	svcSubs subscriptions.Service,
+	limitReached service.LimitReached,
+	groupId, urlCallbackBase string,
+	chanId int64,
+	interestId string,
+	interval time.Duration,
+) (err error) {
+	ctx := context.TODO()
+	var ch *telebot.Chat
+	ch, err = service.ChannelById(tgCtx, strconv.FormatInt(chanId, 10))
+	if err == nil {
+		err = service.VerifyChannelAdmins(tgCtx, ch)
+	}
+	if err != nil {
+		return
+	}
+	userId := util.SenderToUserId(tgCtx)
+	urlCallback := subscriptions.MakeCallbackUrl(urlCallbackBase, ch.ID, userId)
+	err = svcSubs.Subscribe(ctx, interestId, groupId, userId, urlCallback, interval)
+	switch {
+	case err == nil:
+		var subDescr string
+		var subData interest.Data
+		subData, err = svcInterests.Read(ctx, groupId, userId, interestId)
+		switch err {
+		case nil:
+			subDescr = "named \"" + html.EscapeString(subData.Description) + "\""
+		default:
+			subDescr = "id: <code>" + interestId + "</code>"
+		}
+		err = tgCtx.Send(fmt.Sprintf(msgFmtChanLinked, subDescr, html.EscapeString(ch.Title), interval), telebot.ModeHTML, telebot.NoPreview)
+	case errors.Is(err, subscriptions.ErrConflict):
+		err = errors.New("the interest is already delivered to this channel")
+	case errors.Is(err, subscriptions.ErrPermitExhausted):
+		err = limitReached.Send(tgCtx, userId, usage.SubjectSubscriptions)
+	}
+	return
+}
+
+func listButtonsChannel(
+	tgCtx telebot.Context,
+	svcInterests interests.Service,
+	svcSubs subscriptions.Service,
+	groupId, urlCallbackBase string,
+	chanId int64,
+	cursorId string,
+) (m *telebot.ReplyMarkup, err error) {
+	ctx := context.TODO()
+	userId := util.SenderToUserId(tgCtx)
+	q := interest.Query{
+		Limit: service.PageLimit,
+	}
+	var page []*protoInterests.Interest
+	page, err = svcInterests.Search(ctx, groupId, userId, q, condition.Cursor{Id: cursorId})
+	if err == nil {
+		m = &telebot.ReplyMarkup{}
+		var rows []telebot.Row
+		urlCallback := subscriptions.MakeCallbackUrl(urlCallbackBase, chanId, userId)
+		for _, i := range page {
+			btn := telebot.Btn{
+				Text: i.Description,
+			}
+			if i.Public {
+				btn.Text = "👁 " + btn.Text
+			}
+			_, errSub := svcSubs.Subscription(ctx, i.Id, groupId, userId, urlCallback)
+			switch errSub {
+			case nil:
+				btn.Text += " ✓"
+				btn.Data = fmt.Sprintf("%s %d %s", CmdChanStop, chanId, i.Id)
+			default:
+				btn.Data = fmt.Sprintf("%s %d %s", CmdChanSub, chanId, i.Id)
+			}
+			if len(btn.Data) > service.CmdLimit {
+				err = fmt.Errorf("%w: callback data is too long: %s", errInvalidChanArgs, btn.Data)
+				break
+			}
+			rows = append(rows, m.Row(btn))
+		}
+		if err == nil && len(page) == service.PageLimit {
+			cmdData := fmt.Sprintf("%s %d %s", CmdChanPageNext, chanId, page[len(page)-1].Id)
+			if len(cmdData) <= service.CmdLimit {
+				rows = append(rows, m.Row(telebot.Btn{
+					Text: "Next Page >",
+					Data: cmdData,
+				}))
+			}
+		}
+		m.Inline(rows...)
+	}
+	return
+}
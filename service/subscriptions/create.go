@@ -8,6 +8,7 @@ import (
 	"github.com/awakari/bot-telegram/api/http/interests"
 	"github.com/awakari/bot-telegram/model/interest"
 	"github.com/awakari/bot-telegram/model/interest/condition"
+	"github.com/awakari/bot-telegram/model/usage"
 	"github.com/awakari/bot-telegram/service"
 	"github.com/awakari/bot-telegram/util"
 	"gopkg.in/telebot.v3"
@@ -40,7 +41,7 @@ func CreateBasicRequest(tgCtx telebot.Context) (err error) {
 	return
 }
 
-func CreateBasicReplyHandlerFunc(svcInterests interests.Service, groupId string) service.ArgHandlerFunc {
+func CreateBasicReplyHandlerFunc(svcInterests interests.Service, limitReached service.LimitReached, groupId string) service.ArgHandlerFunc {
 	return func(tgCtx telebot.Context, args ...string) (err error) {
 		if len(args) < 2 {
 			err = errCreateSubNotEnoughArgs
@@ -63,6 +64,10 @@ func CreateBasicReplyHandlerFunc(svcInterests interests.Service, groupId string)
 		var subId string
 		if err == nil {
 			subId, err = create(tgCtx, svcInterests, groupId, sd)
+			if errors.Is(err, errLimitReached) {
+				err = limitReached.Send(tgCtx, util.SenderToUserId(tgCtx), usage.SubjectInterests)
+				return
+			}
 		}
 		if err == nil {
 			err = StartIntervalRequest(tgCtx, subId)
@@ -0,0 +1,111 @@
+package subscriptions
+
+import (
+	"errors"
+	"fmt"
+	"github.com/awakari/bot-telegram/api/http/interests"
+	"github.com/awakari/bot-telegram/api/http/subscriptions"
+	"github.com/awakari/bot-telegram/model/interest/condition"
+	"github.com/awakari/bot-telegram/service"
+	"github.com/awakari/bot-telegram/util"
+	"gopkg.in/telebot.v3"
+	"strconv"
+	"strings"
+)
+
+const ReqFind = "find"
+const CmdFindNext = "find_next"
+const msgFindPrefix = "Interests matching: "
+const msgFindSuffix = "\nSelect one or more to subscribe in this chat:"
+const maxFindPatternLength = 256
+
+var errFindPattern = errors.New("invalid search text")
+
+// FindHandlerFunc handles the "/find <text>" command, asks for the text when it's missing.
+func FindHandlerFunc(svcInterests interests.Service, svcSubs subscriptions.Service, groupId, urlCallBackBase string) telebot.HandlerFunc {
+	return func(tgCtx telebot.Context) (err error) {
+		pattern := tgCtx.Message().Payload
+		if strings.TrimSpace(pattern) == "" {
+			_ = tgCtx.Send("Searching own and public interests. Reply a text to find to the next message.")
+			err = tgCtx.Send(ReqFind, &telebot.ReplyMarkup{
+				ForceReply:  true,
+				Placeholder: "text",
+			})
+			return
+		}
+		err = find(tgCtx, svcInterests, svcSubs, groupId, urlCallBackBase, pattern, cursorPublicStart())
+		return
+	}
+}
+
+func FindReplyHandlerFunc(svcInterests interests.Service, svcSubs subscriptions.Service, groupId, urlCallBackBase string) service.ArgHandlerFunc {
+	return func(tgCtx telebot.Context, args ...string) (err error) {
+		if len(args) < 2 {
+			err = fmt.Errorf("%w: empty", errFindPattern)
+			return
+		}
+		err = find(tgCtx, svcInterests, svcSubs, groupId, urlCallBackBase, args[len(args)-1], cursorPublicStart())
+		return
+	}
+}
+
+// FindPageNext restores the search text from the message holding the "Next Page" button.
+func FindPageNext(svcInterests interests.Service, svcSubs subscriptions.Service, groupId, urlCallBackBase string) service.ArgHandlerFunc {
+	return func(tgCtx telebot.Context, args ...string) (err error) {
+		if len(args) < 2 {
+			err = fmt.Errorf("%w: %+v", errFindPattern, args)
+			return
+		}
+		var cursor condition.Cursor
+		cursor.Followers, err = strconv.ParseInt(args[0], 10, 64)
+		cursor.Id = args[1]
+		var pattern string
+		if err == nil {
+			pattern, err = findPatternFromMessage(tgCtx.Callback().Message)
+		}
+		if err == nil {
+			err = find(tgCtx, svcInterests, svcSubs, groupId, urlCallBackBase, pattern, cursor)
+		}
+		return
+	}
+}
+
+func find(
+	tgCtx telebot.Context,
+	svcInterests interests.Service,
+	svcSubs subscriptions.Service,
+	groupId, urlCallBackBase string,
+	pattern string,
+	cursor condition.Cursor,
+) (err error) {
+	pattern = strings.TrimSpace(whiteSpaceRegex.ReplaceAllString(pattern, " "))
+	switch {
+	case pattern == "":
+		err = fmt.Errorf("%w: empty", errFindPattern)
+	case len(pattern) > maxFindPatternLength:
+		err = fmt.Errorf("%w: length is %d, limit is %d", errFindPattern, len(pattern), maxFindPatternLength)
+	}
+	var m *telebot.ReplyMarkup
+	if err == nil {
+		userId := util.SenderToUserId(tgCtx)
+		m, err = listButtons(groupId, userId, svcInterests, svcSubs, tgCtx.Chat().ID, service.ThreadId(tgCtx), CmdStart, cursor, true, pattern, urlCallBackBase)
+	}
+	if err == nil {
+		switch len(m.InlineKeyboard) {
+		case 0:
+			err = tgCtx.Send("No interests found matching: " + pattern)
+		default:
+			err = tgCtx.Send(msgFindPrefix+pattern+msgFindSuffix, m)
+		}
+	}
+	return
+}
+
+func findPatternFromMessage(msg *telebot.Message) (pattern string, err error) {
+	if msg == nil || !strings.HasPrefix(msg.Text, msgFindPrefix) {
+		err = fmt.Errorf("%w: the search results message is not available anymore, repeat the search", errFindPattern)
+		return
+	}
+	pattern, _, _ = strings.Cut(strings.TrimPrefix(msg.Text, msgFindPrefix), "\n")
+	return
+}
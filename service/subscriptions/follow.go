@@ -0,0 +1,144 @@
+package subscriptions
+
+import (
+	"errors"
+	"fmt"
+	"github.com/awakari/bot-telegram/api/http/interests"
+	"github.com/awakari/bot-telegram/api/http/subscriptions"
+	"github.com/awakari/bot-telegram/model/interest"
+	"github.com/awakari/bot-telegram/model/usage"
+	"github.com/awakari/bot-telegram/service"
+	"github.com/awakari/bot-telegram/util"
+	"gopkg.in/telebot.v3"
+	"regexp"
+	"strings"
+)
+
+const ReqFollow = "follow"
+const msgFollow = "Following a Telegram channel. Reply the channel @username optionally followed by keywords to the next message. Example:\n" +
+	"<pre>@durov telegram update</pre>"
+const msgFmtFollowUntracked = "The bot doesn't receive posts from @%s yet. " +
+	"Ask the channel administrator to add @%s to the channel as an administrator, " +
+	"then new posts will be matched against your interest."
+
+var errFollowChannel = errors.New("invalid channel name")
+var followChanNameRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{4,31}$`)
+
+// FollowHandlerFunc handles the "/follow @channel [keywords]" command.
+func FollowHandlerFunc(
+	svcInterests interests.Service,
+	svcSubs subscriptions.Service,
+	limitReached service.LimitReached,
+	urlCallbackBase, groupId string,
+) telebot.HandlerFunc {
+	return func(tgCtx telebot.Context) (err error) {
+		args := tgCtx.Args()
+		if len(args) == 0 {
+			_ = tgCtx.Send(msgFollow, telebot.ModeHTML)
+			err = tgCtx.Send(ReqFollow, &telebot.ReplyMarkup{
+				ForceReply:  true,
+				Placeholder: "@channel keyword1 keyword2 ...",
+			})
+			return
+		}
+		err = follow(tgCtx, svcInterests, svcSubs, limitReached, urlCallbackBase, groupId, args)
+		return
+	}
+}
+
+func FollowReplyHandlerFunc(
+	svcInterests interests.Service,
+	svcSubs subscriptions.Service,
+	limitReached service.LimitReached,
+	urlCallbackBase, groupId string,
+) service.ArgHandlerFunc {
+	return func(tgCtx telebot.Context, args ...string) (err error) {
+		if len(args) < 2 {
+			err = fmt.Errorf("%w: empty", errFollowChannel)
+			return
+		}
+		err = follow(tgCtx, svcInterests, svcSubs, limitReached, urlCallbackBase, groupId, strings.Fields(args[len(args)-1]))
+		return
+	}
+}
+
+func follow(
+	tgCtx telebot.Context,
+	svcInterests interests.Service,
+	svcSubs subscriptions.Service,
+	limitReached service.LimitReached,
+	urlCallbackBase, groupId string,
+	args []string,
+) (err error) {
+	if len(args) == 0 {
+		err = fmt.Errorf("%w: empty, expected: @channel [keywords]", errFollowChannel)
+		return
+	}
+	var ch *telebot.Chat
+	ch, err = resolveChannel(tgCtx, args[0])
+	if err != nil {
+		return
+	}
+	s := fwdSuggestion{
+		Source:   fmt.Sprintf(fmtTgChanUrl, ch.Username),
+		Keywords: args[1:],
+	}
+	sd := interest.Data{
+		Description: s.Description(),
+		Enabled:     true,
+		Condition:   s.Condition(),
+	}
+	err = validateSubscriptionData(sd)
+	var interestId string
+	if err == nil {
+		interestId, err = create(tgCtx, svcInterests, groupId, sd)
+	}
+	if errors.Is(err, errLimitReached) {
+		err = limitReached.Send(tgCtx, util.SenderToUserId(tgCtx), usage.SubjectInterests)
+		return
+	}
+	if err == nil {
+		err = Start(tgCtx, svcInterests, svcSubs, limitReached, urlCallbackBase, interestId, groupId, 0)
+	}
+	if err == nil && !channelTracked(tgCtx, ch) {
+		err = tgCtx.Send(fmt.Sprintf(msgFmtFollowUntracked, ch.Username, tgCtx.Bot().Me.Username))
+	}
+	return
+}
+
+func parseChannelName(src string) (name string, err error) {
+	name = strings.TrimPrefix(src, "@")
+	name = strings.TrimPrefix(name, "https://")
+	name = strings.TrimPrefix(name, "t.me/")
+	if !followChanNameRegex.MatchString(name) {
+		err = fmt.Errorf("%w: %s", errFollowChannel, src)
+	}
+	return
+}
+
+// resolveChannel returns the public channel by the name the user typed. The channel posts source is built from the
+// canonical channel username, the same way the channel posts are published, so the letter case typed doesn't matter.
+func resolveChannel(tgCtx telebot.Context, src string) (ch *telebot.Chat, err error) {
+	var chanName string
+	chanName, err = parseChannelName(src)
+	if err == nil {
+		ch, err = tgCtx.Bot().ChatByUsername("@" + chanName)
+		if err != nil {
+			err = fmt.Errorf("%w: not found: %s, %s", errFollowChannel, src, err)
+		}
+	}
+	if err == nil && (ch.Type != telebot.ChatChannel || ch.Username == "") {
+		err = fmt.Errorf("%w: not a public channel: %s", errFollowChannel, src)
+	}
+	return
+}
+
+// channelTracked reports whether the bot receives the channel posts, i.e. it's the channel administrator.
+func channelTracked(tgCtx telebot.Context, ch *telebot.Chat) (tracked bool) {
+	bot := tgCtx.Bot()
+	member, err := bot.ChatMemberOf(ch, bot.Me)
+	if err == nil {
+		tracked = member.Role == telebot.Administrator
+	}
+	return
+}
@@ -0,0 +1,114 @@
+package subscriptions
+
+import (
+	"github.com/awakari/bot-telegram/service"
+	"github.com/bytedance/sonic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/telebot.v3"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseChannelName(t *testing.T) {
+	cases := map[string]struct {
+		name string
+		err  error
+	}{
+		"@durov": {
+			name: "durov",
+		},
+		"durov": {
+			name: "durov",
+		},
+		"https://t.me/awakari_news": {
+			name: "awakari_news",
+		},
+		"t.me/awakari_news": {
+			name: "awakari_news",
+		},
+		"@abc": {
+			err: errFollowChannel,
+		},
+		"@1channel": {
+			err: errFollowChannel,
+		},
+		"https://example.com/durov": {
+			err: errFollowChannel,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			name, err := parseChannelName(k)
+			assert.ErrorIs(t, err, c.err)
+			if c.err == nil {
+				assert.Equal(t, c.name, name)
+			}
+		})
+	}
+}
+
+func TestResolveChannel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		_ = sonic.ConfigDefault.NewDecoder(r.Body).Decode(&req)
+		switch req["chat_id"] {
+		case "@durov":
+			_, _ = w.Write([]byte(`{"ok":true,"result":{"id":-1001,"type":"channel","username":"Durov"}}`))
+		case "@somegroup":
+			_, _ = w.Write([]byte(`{"ok":true,"result":{"id":-1002,"type":"supergroup","username":"somegroup"}}`))
+		default:
+			_, _ = w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`))
+		}
+	}))
+	defer srv.Close()
+	b, err := telebot.NewBot(telebot.Settings{
+		URL:     srv.URL,
+		Offline: true,
+	})
+	require.Nil(t, err)
+	tgCtx := b.NewContext(telebot.Update{})
+	cases := map[string]struct {
+		username string
+		err      error
+	}{
+		"@durov": {
+			username: "Durov",
+		},
+		"https://t.me/durov": {
+			username: "Durov",
+		},
+		"@somegroup": {
+			err: errFollowChannel,
+		},
+		"@missing": {
+			err: errFollowChannel,
+		},
+		"@abc": {
+			err: errFollowChannel,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			ch, err := resolveChannel(tgCtx, k)
+			assert.ErrorIs(t, err, c.err)
+			if c.err == nil {
+				assert.Equal(t, c.username, ch.Username)
+			}
+		})
+	}
+}
+
+func TestFollowReplyHandlerFunc_Empty(t *testing.T) {
+	b, _ := newBotTest(t)
+	tgCtx := b.NewContext(telebot.Update{
+		Message: &telebot.Message{
+			Chat: &telebot.Chat{ID: 1},
+		},
+	})
+	f := FollowReplyHandlerFunc(nil, nil, service.LimitReached{}, urlCallbackBaseTest, "group0")
+	for _, txt := range []string{"", " \n "} {
+		assert.ErrorIs(t, f(tgCtx, ReqFollow, txt), errFollowChannel)
+	}
+}
@@ -0,0 +1,231 @@
+package subscriptions
+
+import (
+	"errors"
+	"fmt"
+	"github.com/awakari/bot-telegram/api/http/interests"
+	"github.com/awakari/bot-telegram/api/http/subscriptions"
+	"github.com/awakari/bot-telegram/model"
+	"github.com/awakari/bot-telegram/model/interest"
+	"github.com/awakari/bot-telegram/model/interest/condition"
+	"github.com/awakari/bot-telegram/model/usage"
+	"github.com/awakari/bot-telegram/service"
+	"github.com/awakari/bot-telegram/util"
+	"gopkg.in/telebot.v3"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const CmdFwdSub = "fwd_sub"
+const fwdKeywordsCountMax = 4
+const fwdKeywordLenMin = 4
+const fwdLinksCountMax = 3
+const fwdLinePrefixSource = "Source: "
+const fwdLinePrefixKeywords = "Keywords: "
+const fwdLinePrefixLinks = "Links: "
+const msgFwdSuggestion = "Suggested interest for the forwarded message:"
+const fmtTgChanUrl = "https://t.me/%s"
+
+var errFwdNothing = errors.New("nothing to build an interest from in the forwarded message")
+
+type fwdSuggestion struct {
+	Source   string
+	Keywords []string
+	Links    []string
+}
+
+// ForwardHandlerFunc proposes an interest built from the forwarded message source and keywords.
+func ForwardHandlerFunc(tgCtx telebot.Context) (err error) {
+	s := suggestFromMessage(tgCtx.Message())
+	if s.Source == "" && len(s.Keywords) == 0 {
+		err = errFwdNothing
+		return
+	}
+	m := &telebot.ReplyMarkup{}
+	m.Inline(m.Row(telebot.Btn{
+		Text: "Create and subscribe",
+		Data: CmdFwdSub,
+	}))
+	err = tgCtx.Send(s.String(), m, telebot.NoPreview)
+	return
+}
+
+// ForwardSubscribe creates the interest suggested in the message holding the button and subscribes to it in this chat.
+func ForwardSubscribe(
+	svcInterests interests.Service,
+	svcSubs subscriptions.Service,
+	limitReached service.LimitReached,
+	urlCallbackBase, groupId string,
+) service.ArgHandlerFunc {
+	return func(tgCtx telebot.Context, args ...string) (err error) {
+		var s fwdSuggestion
+		if msg := tgCtx.Callback().Message; msg != nil {
+			s = parseSuggestion(msg.Text)
+		}
+		sd := interest.Data{
+			Description: s.Description(),
+			Enabled:     true,
+			Condition:   s.Condition(),
+		}
+		if sd.Condition == nil {
+			err = errFwdNothing
+		}
+		if err == nil {
+			err = validateSubscriptionData(sd)
+		}
+		var interestId string
+		if err == nil {
+			interestId, err = create(tgCtx, svcInterests, groupId, sd)
+		}
+		if errors.Is(err, errLimitReached) {
+			err = limitReached.Send(tgCtx, util.SenderToUserId(tgCtx), usage.SubjectInterests)
+			return
+		}
+		if err == nil {
+			err = Start(tgCtx, svcInterests, svcSubs, limitReached, urlCallbackBase, interestId, groupId, 0)
+		}
+		return
+	}
+}
+
+func suggestFromMessage(msg *telebot.Message) (s fwdSuggestion) {
+	if msg.OriginalChat != nil && msg.OriginalChat.Username != "" {
+		s.Source = fmt.Sprintf(fmtTgChanUrl, msg.OriginalChat.Username)
+	}
+	txt := msg.Text
+	entities := msg.Entities
+	if txt == "" {
+		txt = msg.Caption
+		entities = msg.CaptionEntities
+	}
+	skip := map[string]bool{}
+	for _, e := range entities {
+		et := msg.EntityText(e)
+		switch e.Type {
+		case telebot.EntityHashtag, telebot.EntityCashtag:
+			kw := strings.ToLower(strings.TrimLeft(et, "#$"))
+			if utf8.RuneCountInString(kw) >= minTextCondTermsLength && !skip[kw] && len(s.Keywords) < fwdKeywordsCountMax {
+				s.Keywords = append(s.Keywords, kw)
+			}
+			skip[kw] = true
+		case telebot.EntityURL:
+			s.Links = appendLink(s.Links, et)
+			skip[strings.ToLower(et)] = true
+		case telebot.EntityTextLink:
+			s.Links = appendLink(s.Links, e.URL)
+		case telebot.EntityMention, telebot.EntityEmail:
+			skip[strings.ToLower(et)] = true
+		}
+	}
+	s.Keywords = append(s.Keywords, topKeywords(txt, skip, fwdKeywordsCountMax-len(s.Keywords))...)
+	return
+}
+
+func appendLink(links []string, link string) []string {
+	if link != "" && len(links) < fwdLinksCountMax {
+		links = append(links, link)
+	}
+	return links
+}
+
+// topKeywords returns the most frequent words, the earlier occurrence wins when the frequencies are equal.
+func topKeywords(txt string, skip map[string]bool, limit int) (kws []string) {
+	if limit <= 0 {
+		return
+	}
+	counts := map[string]int{}
+	var order []string
+	for _, w := range strings.Fields(txt) {
+		if skip[strings.ToLower(w)] {
+			continue
+		}
+		w = strings.ToLower(strings.TrimFunc(w, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}))
+		if utf8.RuneCountInString(w) < fwdKeywordLenMin || skip[w] || strings.ContainsFunc(w, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-'
+		}) {
+			continue
+		}
+		if counts[w] == 0 {
+			order = append(order, w)
+		}
+		counts[w]++
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return counts[order[i]] > counts[order[j]]
+	})
+	if len(order) > limit {
+		order = order[:limit]
+	}
+	kws = order
+	return
+}
+
+func (s fwdSuggestion) String() string {
+	lines := []string{
+		msgFwdSuggestion,
+	}
+	if s.Source != "" {
+		lines = append(lines, fwdLinePrefixSource+s.Source)
+	}
+	if len(s.Keywords) > 0 {
+		lines = append(lines, fwdLinePrefixKeywords+strings.Join(s.Keywords, " "))
+	}
+	if len(s.Links) > 0 {
+		lines = append(lines, fwdLinePrefixLinks+strings.Join(s.Links, " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func parseSuggestion(txt string) (s fwdSuggestion) {
+	for _, line := range strings.Split(txt, "\n") {
+		switch {
+		case strings.HasPrefix(line, fwdLinePrefixSource):
+			s.Source = strings.TrimPrefix(line, fwdLinePrefixSource)
+		case strings.HasPrefix(line, fwdLinePrefixKeywords):
+			s.Keywords = strings.Fields(strings.TrimPrefix(line, fwdLinePrefixKeywords))
+		case strings.HasPrefix(line, fwdLinePrefixLinks):
+			s.Links = strings.Fields(strings.TrimPrefix(line, fwdLinePrefixLinks))
+		}
+	}
+	return
+}
+
+func (s fwdSuggestion) Description() (descr string) {
+	var parts []string
+	if s.Source != "" {
+		parts = append(parts, "@"+strings.TrimPrefix(s.Source, fmt.Sprintf(fmtTgChanUrl, "")))
+	}
+	parts = append(parts, s.Keywords...)
+	descr = strings.Join(parts, " ")
+	return
+}
+
+// Condition returns the source condition ANDed with any of the keywords, or just one of them when the other is missing.
+func (s fwdSuggestion) Condition() (cond condition.Condition) {
+	var children []condition.Condition
+	if s.Source != "" {
+		children = append(children, sourceCondition(s.Source))
+	}
+	if len(s.Keywords) > 0 {
+		children = append(children, condition.NewBuilder().AnyOfWords(strings.Join(s.Keywords, " ")).BuildTextCondition())
+	}
+	switch len(children) {
+	case 0:
+	case 1:
+		cond = children[0]
+	default:
+		cond = condition.NewBuilder().All(children).BuildGroupCondition()
+	}
+	return
+}
+
+func sourceCondition(src string) condition.Condition {
+	return condition.NewBuilder().
+		AttributeKey(model.CeKeySource).
+		TextEquals(src).
+		BuildTextCondition()
+}
@@ -0,0 +1,86 @@
+package subscriptions
+
+import (
+	"github.com/awakari/bot-telegram/model/interest/condition"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/telebot.v3"
+	"testing"
+)
+
+func TestSuggestFromMessage(t *testing.T) {
+	cases := map[string]struct {
+		msg *telebot.Message
+		s   fwdSuggestion
+	}{
+		"channel post with tags and links": {
+			msg: &telebot.Message{
+				OriginalChat: &telebot.Chat{
+					Username: "news",
+				},
+				Text: "#Tesla announced the new battery. Battery prices drop, see https://foo.com/a and $TSLA battery",
+				Entities: telebot.Entities{
+					{
+						Type:   telebot.EntityHashtag,
+						Offset: 0,
+						Length: 6,
+					},
+					{
+						Type:   telebot.EntityURL,
+						Offset: 59,
+						Length: 17,
+					},
+					{
+						Type:   telebot.EntityCashtag,
+						Offset: 81,
+						Length: 5,
+					},
+				},
+			},
+			s: fwdSuggestion{
+				Source:   "https://t.me/news",
+				Keywords: []string{"tesla", "tsla", "battery", "announced"},
+				Links:    []string{"https://foo.com/a"},
+			},
+		},
+		"user message caption": {
+			msg: &telebot.Message{
+				OriginalSender: &telebot.User{},
+				Caption:        "Cheap flights to Lisbon, cheap hotels",
+			},
+			s: fwdSuggestion{
+				Keywords: []string{"cheap", "flights", "lisbon", "hotels"},
+			},
+		},
+		"empty": {
+			msg: &telebot.Message{
+				OriginalSender: &telebot.User{},
+				Text:           "ok",
+			},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			s := suggestFromMessage(c.msg)
+			assert.Equal(t, c.s, s)
+			assert.Equal(t, s, parseSuggestion(s.String()))
+		})
+	}
+}
+
+func TestFwdSuggestion_Condition(t *testing.T) {
+	s := fwdSuggestion{
+		Source:   "https://t.me/news",
+		Keywords: []string{"tesla", "battery"},
+	}
+	assert.Equal(t, "@news tesla battery", s.Description())
+	gc, ok := s.Condition().(condition.GroupCondition)
+	assert.True(t, ok)
+	assert.Equal(t, condition.GroupLogic(condition.GroupLogicAnd), gc.GetLogic())
+	assert.Len(t, gc.GetGroup(), 2)
+	src := gc.GetGroup()[0].(condition.TextCondition)
+	assert.Equal(t, "source", src.GetKey())
+	assert.Equal(t, "https://t.me/news", src.GetTerm())
+	assert.True(t, src.IsExact())
+	assert.Equal(t, "tesla battery", gc.GetGroup()[1].(condition.TextCondition).GetTerm())
+	assert.Nil(t, fwdSuggestion{}.Condition())
+}
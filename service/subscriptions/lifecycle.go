@@ -0,0 +1,80 @@
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/awakari/bot-telegram/api/http/subscriptions"
+	"github.com/awakari/bot-telegram/service"
+	"github.com/awakari/bot-telegram/util"
+	"gopkg.in/telebot.v3"
+	"html"
+	"sort"
+)
+
+const msgFmtBotRemoved = "The bot has been removed from the chat %s, your subscriptions stopped there: %d"
+
+// BotRemoved stops all the chat subscriptions of every subscriber when the bot is kicked from the chat or blocked by
+// the user.
+func BotRemoved(
+	svcSubs subscriptions.Service,
+	chatSubscribers service.ChatSubscribers,
+	urlCallbackBase, groupId string,
+) telebot.HandlerFunc {
+	return func(tgCtx telebot.Context) (err error) {
+		upd := tgCtx.ChatMember()
+		if upd == nil || upd.NewChatMember == nil || upd.Chat == nil {
+			return
+		}
+		switch upd.NewChatMember.Role {
+		case telebot.Left, telebot.Kicked:
+		default:
+			return
+		}
+		countByUserId := map[string]int{}
+		_, _, err = service.WalkChat(
+			svcSubs, chatSubscribers, urlCallbackBase, groupId, upd.Chat.ID,
+			func(ctx context.Context, userId, interestId, urlCallback string) (err error) {
+				err = svcSubs.Unsubscribe(ctx, interestId, groupId, userId, urlCallback)
+				if err == nil {
+					countByUserId[userId]++
+				}
+				return
+			},
+		)
+		if err != nil {
+			err = fmt.Errorf("failed to stop the subscriptions in the chat %d: %w", upd.Chat.ID, err)
+		}
+		if upd.Chat.Type != telebot.ChatPrivate {
+			err = errors.Join(err, notifyBotRemoved(tgCtx.Bot(), upd.Chat, countByUserId))
+		}
+		return
+	}
+}
+
+// notifyBotRemoved notifies the subscribers in their private chats with the bot. A subscriber may have no private chat
+// with the bot, so the notification is the best effort.
+func notifyBotRemoved(bot *telebot.Bot, chat *telebot.Chat, countByUserId map[string]int) (err error) {
+	chatName := chat.Title
+	if chatName == "" {
+		chatName = fmt.Sprintf("%d", chat.ID)
+	}
+	userIds := make([]string, 0, len(countByUserId))
+	for userId := range countByUserId {
+		userIds = append(userIds, userId)
+	}
+	sort.Strings(userIds)
+	for _, userId := range userIds {
+		tgUserId, ok := util.AwakariToTelegramUserId(userId)
+		if !ok || tgUserId <= 0 {
+			continue // legacy subscription owned by the chat itself
+		}
+		_, errSend := bot.Send(
+			telebot.ChatID(tgUserId),
+			fmt.Sprintf(msgFmtBotRemoved, html.EscapeString(chatName), countByUserId[userId]),
+			telebot.ModeHTML,
+		)
+		err = errors.Join(err, errSend)
+	}
+	return
+}
@@ -0,0 +1,81 @@
+package subscriptions
+
+import (
+	"github.com/awakari/bot-telegram/api/http/subscriptions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/telebot.v3"
+	"testing"
+)
+
+func TestBotRemoved(t *testing.T) {
+	cases := map[string]struct {
+		chat *telebot.Chat
+		role telebot.MemberStatus
+		urls map[string]map[string][]string
+		sent []sentMessage
+	}{
+		"kicked from the group": {
+			chat: &telebot.Chat{ID: -1001, Type: telebot.ChatSuperGroup, Title: "Group <1>"},
+			role: telebot.Kicked,
+			urls: map[string]map[string][]string{
+				"tg://user?id=1": {
+					"interest2": {},
+				},
+				"tg://user?id=2":     {},
+				"tg://user?id=-1001": {},
+			},
+			sent: []sentMessage{
+				{ChatId: "1", Text: "The bot has been removed from the chat Group &lt;1&gt;, your subscriptions stopped there: 2"},
+				{ChatId: "2", Text: "The bot has been removed from the chat Group &lt;1&gt;, your subscriptions stopped there: 1"},
+			},
+		},
+		"blocked in the private chat": {
+			chat: &telebot.Chat{ID: 1, Type: telebot.ChatPrivate},
+			role: telebot.Kicked,
+			urls: map[string]map[string][]string{
+				"tg://user?id=1": {},
+			},
+		},
+		"promoted": {
+			chat: &telebot.Chat{ID: -1001, Type: telebot.ChatSuperGroup, Title: "Group <1>"},
+			role: telebot.Administrator,
+			urls: map[string]map[string][]string{
+				"tg://user?id=1": {},
+			},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			b, sent := newBotTest(t)
+			cs := newChatSubscribersTest(t)
+			for userId, urlsByInterestId := range c.urls {
+				urlsByInterestId["interest0"] = []string{subscriptions.MakeCallbackUrl(urlCallbackBaseTest, c.chat.ID, userId)}
+				require.Nil(t, cs.Add(c.chat.ID, 0, userId))
+			}
+			if urlsUser1, found := c.urls["tg://user?id=1"]; found {
+				urlsUser1["interest1"] = []string{subscriptions.MakeCallbackUrl(urlCallbackBaseTest, c.chat.ID, "")}
+			}
+			tgCtx := b.NewContext(telebot.Update{
+				MyChatMember: &telebot.ChatMemberUpdate{
+					Chat:          c.chat,
+					Sender:        &telebot.User{ID: 2},
+					NewChatMember: &telebot.ChatMember{Role: c.role},
+				},
+			})
+			err := BotRemoved(subscriptions.NewMock(c.urls), cs, urlCallbackBaseTest, "group0")(tgCtx)
+			assert.Nil(t, err)
+			assert.Equal(t, c.sent, sent())
+			for userId, urlsByInterestId := range c.urls {
+				for interestId, urls := range urlsByInterestId {
+					switch c.role {
+					case telebot.Kicked:
+						assert.Empty(t, urls, userId+" "+interestId)
+					default:
+						assert.NotEmpty(t, urls, userId+" "+interestId)
+					}
+				}
+			}
+		})
+	}
+}
@@ -25,7 +25,7 @@ func ListOnGroupStartHandlerFunc(svcInterests interests.Service, svcSubs subscri
 		userId := util.SenderToUserId(tgCtx)
 		cursor := condition.Cursor{}
 		var m *telebot.ReplyMarkup
-		m, err = listButtons(groupId, userId, svcInterests, svcSubs, tgCtx.Chat().ID, CmdStart, cursor, false, urlCallBackBase)
+		m, err = listButtons(groupId, userId, svcInterests, svcSubs, tgCtx.Chat().ID, service.ThreadId(tgCtx), CmdStart, cursor, false, "", urlCallBackBase)
 		if err == nil {
 			err = tgCtx.Send("Own interests list. Select one or more to subscribe in this chat:", m)
 		}
@@ -36,12 +36,9 @@ func ListOnGroupStartHandlerFunc(svcInterests interests.Service, svcSubs subscri
 func ListPublicHandlerFunc(svcInterests interests.Service, svcSubs subscriptions.Service, groupId, urlCallBackBase string) telebot.HandlerFunc {
 	return func(tgCtx telebot.Context) (err error) {
 		userId := util.SenderToUserId(tgCtx)
-		cursor := condition.Cursor{
-			Id:        "zzzzzzzz-zzzz-zzzz-zzzz-zzzzzzzzzzzz",
-			Followers: math.MaxInt64,
-		}
+		cursor := cursorPublicStart()
 		var m *telebot.ReplyMarkup
-		m, err = listButtons(groupId, userId, svcInterests, svcSubs, tgCtx.Chat().ID, CmdStart, cursor, true, urlCallBackBase)
+		m, err = listButtons(groupId, userId, svcInterests, svcSubs, tgCtx.Chat().ID, service.ThreadId(tgCtx), CmdStart, cursor, true, "", urlCallBackBase)
 		if err == nil {
 			err = tgCtx.Send("Available interests list. Select one or more to subscribe in this chat:", m)
 		}
@@ -62,7 +59,7 @@ func PageNext(svcInterests interests.Service, svcSubs subscriptions.Service, gro
 			public = true
 		}
 		var m *telebot.ReplyMarkup
-		m, err = listButtons(groupId, userId, svcInterests, svcSubs, tgCtx.Chat().ID, args[0], cursor, public, urlCallBackBase)
+		m, err = listButtons(groupId, userId, svcInterests, svcSubs, tgCtx.Chat().ID, service.ThreadId(tgCtx), args[0], cursor, public, "", urlCallBackBase)
 		if err == nil {
 			err = tgCtx.Send("Interests list page:", m, telebot.ModeHTML)
 		}
@@ -70,20 +67,31 @@ func PageNext(svcInterests interests.Service, svcSubs subscriptions.Service, gro
 	}
 }
 
+// cursorPublicStart returns the cursor to list the public interests from the most followed ones.
+func cursorPublicStart() condition.Cursor {
+	return condition.Cursor{
+		Id:        "zzzzzzzz-zzzz-zzzz-zzzz-zzzzzzzzzzzz",
+		Followers: math.MaxInt64,
+	}
+}
+
 func listButtons(
 	groupId string,
 	userId string,
 	svcInterests interests.Service,
 	svcSubs subscriptions.Service,
 	chatId int64,
+	threadId int,
 	btnCmd string,
 	cursor condition.Cursor,
 	public bool,
+	pattern string,
 	urlCallBackBase string,
 ) (m *telebot.ReplyMarkup, err error) {
 	var page []*protoInterests.Interest
 	q := interest.Query{
-		Limit: service.PageLimit,
+		Limit:   service.PageLimit,
+		Pattern: pattern,
 	}
 	if public {
 		q.Order = interest.OrderDesc
@@ -98,7 +106,10 @@ func listButtons(
 		for _, i := range page {
 			var subLinkedHere bool
 			lastFollowers = i.Followers
-			_, err = svcSubs.Subscription(context.TODO(), i.Id, groupId, userId, subscriptions.MakeCallbackUrl(urlCallBackBase, chatId, userId))
+			_, err = svcSubs.Subscription(context.TODO(), i.Id, groupId, userId, subscriptions.MakeTopicCallbackUrl(urlCallBackBase, chatId, threadId, userId))
+			if err != nil && threadId != 0 {
+				_, err = svcSubs.Subscription(context.TODO(), i.Id, groupId, userId, subscriptions.MakeCallbackUrl(urlCallBackBase, chatId, userId))
+			}
 			if err != nil {
 				_, err = svcSubs.Subscription(context.TODO(), i.Id, groupId, userId, subscriptions.MakeCallbackUrl(urlCallBackBase, chatId, ""))
 			}
@@ -144,6 +155,10 @@ func listButtons(
 			if public {
 				cmdData += " public"
 			}
+			if pattern != "" {
+				// the pattern doesn't fit the callback data, it's restored from the list message text
+				cmdData = fmt.Sprintf("%s %d %s", CmdFindNext, lastFollowers, page[len(page)-1].Id)
+			}
 			if len(cmdData) > service.CmdLimit {
 				cmdData = cmdData[:service.CmdLimit]
 			}
@@ -199,7 +214,6 @@ func listButtonsFollowing(
 	cbUrl := subscriptions.MakeCallbackUrl(urlCallBackBase, chatId, "") // makes a prefix w/o user id appended
 	var interestIds []string
 	interestIds, err = svcSubs.InterestsByUrl(groupIdCtx, groupId, userId, service.PageLimit, cbUrl, cursor)
-	interestIds = append(interestIds)
 	if err == nil {
 		m = &telebot.ReplyMarkup{}
 		var sub interest.Data
@@ -234,6 +248,9 @@ func listButtonsFollowing(
 				Data: cmdData,
 			}))
 		}
+		if cursor == "" {
+			rows = append(rows, bulkButtons(m)...)
+		}
 		m.Inline(rows...)
 	}
 	return
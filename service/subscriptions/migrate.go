@@ -0,0 +1,50 @@
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/awakari/bot-telegram/api/http/subscriptions"
+	"github.com/awakari/bot-telegram/service"
+	"gopkg.in/telebot.v3"
+	"strings"
+)
+
+const msgFmtMigrated = "This group has been upgraded to a supergroup. Subscriptions moved: %d"
+const msgFmtMigratedFailed = "\nFailed to move: %s"
+
+// Migrate handles the group to supergroup upgrade: Telegram changes the chat id, so every subscription callback
+// registered for the old chat id is re-registered for the new one, regardless of the subscriber.
+func Migrate(
+	svcSubs subscriptions.Service,
+	chatSubscribers service.ChatSubscribers,
+	urlCallbackBase, groupId string,
+) telebot.HandlerFunc {
+	return func(tgCtx telebot.Context) (err error) {
+		chatIdOld, chatIdNew := tgCtx.Migration()
+		var moved, failed []string
+		moved, failed, err = service.WalkChat(
+			svcSubs, chatSubscribers, urlCallbackBase, groupId, chatIdOld,
+			func(ctx context.Context, userId, interestId, urlCallbackOld string) (err error) {
+				urlCallbackNew := subscriptions.MakeCallbackUrl(urlCallbackBase, chatIdNew, userId)
+				err = svcSubs.Subscribe(ctx, interestId, groupId, userId, urlCallbackNew, 0)
+				if errors.Is(err, subscriptions.ErrConflict) {
+					err = nil // already moved, e.g. both the legacy and the user callbacks exist
+				}
+				if err == nil {
+					err = svcSubs.Unsubscribe(ctx, interestId, groupId, userId, urlCallbackOld)
+				}
+				return
+			},
+		)
+		if len(moved) > 0 || len(failed) > 0 {
+			msg := fmt.Sprintf(msgFmtMigrated, len(moved))
+			if len(failed) > 0 {
+				msg += fmt.Sprintf(msgFmtMigratedFailed, strings.Join(failed, ", "))
+			}
+			_, errSend := tgCtx.Bot().Send(&telebot.Chat{ID: chatIdNew}, msg)
+			err = errors.Join(err, errSend)
+		}
+		return
+	}
+}
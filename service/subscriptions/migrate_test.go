@@ -0,0 +1,123 @@
+package subscriptions
+
+import (
+	"context"
+	"fmt"
+	"github.com/awakari/bot-telegram/api/http/subscriptions"
+	"github.com/awakari/bot-telegram/service"
+	"github.com/awakari/bot-telegram/service/storage"
+	"github.com/bytedance/sonic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/telebot.v3"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const urlCallbackBaseTest = "http://bot-telegram:8081/v1/chat"
+
+type sentMessage struct {
+	ChatId string `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+// newBotTest returns the bot talking to the stub Telegram API recording the sent messages.
+func newBotTest(t *testing.T) (b *telebot.Bot, sent func() []sentMessage) {
+	return newBotTestFiles(t, nil)
+}
+
+// newBotTestFiles is the same as newBotTest but also serves the files content by the file id.
+func newBotTestFiles(t *testing.T, files map[string][]byte) (b *telebot.Bot, sent func() []sentMessage) {
+	var lock sync.Mutex
+	var msgs []sentMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/getFile"):
+			var f telebot.File
+			_ = sonic.ConfigDefault.NewDecoder(r.Body).Decode(&f)
+			_, _ = fmt.Fprintf(w, `{"ok":true,"result":{"file_id":"%s","file_path":"%s"}}`, f.FileID, f.FileID)
+		case strings.Contains(r.URL.Path, "/file/"):
+			_, _ = w.Write(files[path.Base(r.URL.Path)])
+		default:
+			var msg sentMessage
+			_ = sonic.ConfigDefault.NewDecoder(r.Body).Decode(&msg)
+			lock.Lock()
+			msgs = append(msgs, msg)
+			lock.Unlock()
+			_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":1,"chat":{"id":1}}}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	b, err := telebot.NewBot(telebot.Settings{
+		URL:     srv.URL,
+		Offline: true,
+	})
+	require.Nil(t, err)
+	sent = func() []sentMessage {
+		lock.Lock()
+		defer lock.Unlock()
+		return slices.Clone(msgs)
+	}
+	return
+}
+
+func newChatSubscribersTest(t *testing.T) service.ChatSubscribers {
+	s, err := storage.NewFile[service.ChatSubscriber](filepath.Join(t.TempDir(), "chat-subscribers.json"))
+	require.Nil(t, err)
+	return service.ChatSubscribers{
+		Storage: s,
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	b, sent := newBotTest(t)
+	cs := newChatSubscribersTest(t)
+	require.Nil(t, cs.Add(-1001, 0, "user0"))
+	require.Nil(t, cs.Add(-1001, 0, "user1"))
+	urls := map[string]map[string][]string{
+		"user0": {
+			"interest0": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, "user0")},
+			"interest1": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1003, "user0")},
+		},
+		"user1": {
+			"interest1": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1001, "")},
+		},
+	}
+	svcSubs := service.TrackSubscribers(subscriptions.NewMock(urls), cs, urlCallbackBaseTest)
+	tgCtx := b.NewContext(telebot.Update{
+		Message: &telebot.Message{
+			Chat: &telebot.Chat{
+				ID: -1001,
+			},
+			MigrateFrom: -1001,
+			MigrateTo:   -1002,
+		},
+	})
+	err := Migrate(svcSubs, cs, urlCallbackBaseTest, "group0")(tgCtx)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]map[string][]string{
+		"user0": {
+			"interest0": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1002, "user0")},
+			"interest1": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1003, "user0")},
+		},
+		"user1": {
+			"interest1": {subscriptions.MakeCallbackUrl(urlCallbackBaseTest, -1002, "user1")},
+		},
+	}, urls)
+	assert.Equal(t, map[string][]int{"user0": {0}, "user1": {0}}, cs.Users(-1002))
+	assert.Equal(t, []sentMessage{{ChatId: "-1002", Text: "This group has been upgraded to a supergroup. Subscriptions moved: 2"}}, sent())
+	_, _, err = service.WalkChat(
+		svcSubs, cs, urlCallbackBaseTest, "group0", -1001,
+		func(ctx context.Context, userId, interestId, urlCallback string) error {
+			return nil
+		},
+	)
+	assert.Nil(t, err)
+	assert.Empty(t, cs.Users(-1001))
+}
@@ -10,7 +10,6 @@ import (
 	"github.com/awakari/bot-telegram/model/usage"
 	"github.com/awakari/bot-telegram/service"
 	"github.com/awakari/bot-telegram/service/chats"
-	"github.com/awakari/bot-telegram/service/limits"
 	"github.com/awakari/bot-telegram/util"
 	"gopkg.in/telebot.v3"
 	"html"
@@ -26,7 +25,7 @@ const MsgFmtChatLinked = "Subscribed to the interest %s in this chat. " +
 func StartHandler(
 	svcInterests interests.Service,
 	svcSubs subscriptions.Service,
-	svcLimits limits.Service,
+	limitReached service.LimitReached,
 	urlCallbackBase string,
 	groupId string,
 ) service.ArgHandlerFunc {
@@ -46,7 +45,7 @@ func StartHandler(
 			}
 			if err == nil {
 				interestId := args[1]
-				err = Start(tgCtx, svcInterests, svcSubs, svcLimits, urlCallbackBase, interestId, groupId, interval)
+				err = Start(tgCtx, svcInterests, svcSubs, limitReached, urlCallbackBase, interestId, groupId, interval)
 			}
 		default:
 			err = errors.New(fmt.Sprintf("invalid response: expected 1-3 arguments, got %d: %+v", len(args), args))
@@ -68,7 +67,7 @@ func Start(
 	tgCtx telebot.Context,
 	svcInterests interests.Service,
 	svcSubs subscriptions.Service,
-	svcLimits limits.Service,
+	limitReached service.LimitReached,
 	urlCallbackBase string,
 	interestId string,
 	groupId string,
@@ -82,7 +81,7 @@ func Start(
 	default:
 		userId = util.SenderToUserId(tgCtx)
 	}
-	urlCallback := subscriptions.MakeCallbackUrl(urlCallbackBase, tgCtx.Chat().ID, userId)
+	urlCallback := subscriptions.MakeTopicCallbackUrl(urlCallbackBase, tgCtx.Chat().ID, service.ThreadId(tgCtx), userId)
 	err = svcSubs.Subscribe(ctx, interestId, groupId, userId, urlCallback, interval)
 	if errors.Is(err, chats.ErrAlreadyExists) {
 		// might be not an error, so try to re-link the subscription
@@ -109,50 +108,7 @@ func Start(
 		}
 		err = tgCtx.Send(fmt.Sprintf(MsgFmtChatLinked, subDescr, interval), telebot.ModeHTML, telebot.NoPreview)
 	case errors.Is(err, subscriptions.ErrPermitExhausted):
-		var l usage.Limit
-		l, err = svcLimits.Get(ctx, groupId, userId, usage.SubjectSubscriptions)
-		switch err {
-		case nil:
-			switch {
-			case l.Count < 5:
-				err = tgCtx.Send(fmt.Sprintf("Subscription count limit reached: %d", l.Count), &telebot.ReplyMarkup{
-					InlineKeyboard: [][]telebot.InlineButton{
-						{
-							telebot.InlineButton{
-								Text: "Increase to 5",
-								URL:  "https://t.me/tribute/app?startapp=svd8",
-							},
-						},
-					},
-				})
-			case l.Count < 10:
-				err = tgCtx.Send(fmt.Sprintf("Subscription count limit reached: %d", l.Count), &telebot.ReplyMarkup{
-					InlineKeyboard: [][]telebot.InlineButton{
-						{
-							telebot.InlineButton{
-								Text: "Increase to 10",
-								URL:  "https://t.me/tribute/app?startapp=sv5Q",
-							},
-						},
-					},
-				})
-			case l.Count < 20:
-				err = tgCtx.Send(fmt.Sprintf("Subscription count limit reached: %d", l.Count), &telebot.ReplyMarkup{
-					InlineKeyboard: [][]telebot.InlineButton{
-						{
-							telebot.InlineButton{
-								Text: "Increase to 20",
-								URL:  "https://t.me/tribute/app?startapp=svaR",
-							},
-						},
-					},
-				})
-			default:
-				err = tgCtx.Send(fmt.Sprintf("Subscription count limit reached"))
-			}
-		default:
-			_ = tgCtx.Send(fmt.Sprintf("Subscription count limit reached"))
-		}
+		err = limitReached.Send(tgCtx, userId, usage.SubjectSubscriptions)
 	default:
 		err = tgCtx.Send("Unexpected failure", telebot.ModeHTML, telebot.NoPreview)
 	}
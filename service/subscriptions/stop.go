@@ -15,8 +15,14 @@ func Stop(svcSubs subscriptions.Service, urlCallbackBase, groupId string) servic
 		ctx := context.TODO()
 		interestId := args[0]
 		userId := util.SenderToUserId(tgCtx)
-		urlCallback := subscriptions.MakeCallbackUrl(urlCallbackBase, tgCtx.Chat().ID, userId)
+		threadId := service.ThreadId(tgCtx)
+		urlCallback := subscriptions.MakeTopicCallbackUrl(urlCallbackBase, tgCtx.Chat().ID, threadId, userId)
 		_, err = svcSubs.Subscription(ctx, interestId, groupId, userId, urlCallback)
+		if err != nil && threadId != 0 {
+			// the subscription may be linked to the whole chat rather than to this topic
+			urlCallback = subscriptions.MakeCallbackUrl(urlCallbackBase, tgCtx.Chat().ID, userId)
+			_, err = svcSubs.Subscription(ctx, interestId, groupId, userId, urlCallback)
+		}
 		switch err {
 		case nil:
 			if err == nil {
@@ -1,26 +1,253 @@
 package support
 
 import (
+	"errors"
 	"fmt"
 	"github.com/awakari/bot-telegram/service"
+	"github.com/awakari/bot-telegram/service/storage"
 	"gopkg.in/telebot.v3"
+	"html"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Handler struct {
 	SupportChatId int64
+
+	// Tickets keeps the support tickets by id.
+	Tickets storage.Storage[Ticket]
+
+	// Messages resolves the ticket id by the related message key, see msgKey. Both the support chat messages and the
+	// answers relayed to the user are indexed, so a reply to any of them is attributed to the ticket.
+	Messages storage.Storage[string]
+
+	Log *slog.Logger
 }
 
+const ticketsCountMax = 50
+
+var errTicket = errors.New("invalid ticket")
+
 func (sh Handler) Request(tgCtx telebot.Context, args ...string) (err error) {
-	tgCtxSupport := tgCtx.Bot().NewContext(telebot.Update{
-		Message: &telebot.Message{
-			Chat: &telebot.Chat{
-				ID: sh.SupportChatId,
-			},
-		},
+	msg := tgCtx.Message()
+	sender := tgCtx.Sender()
+	now := time.Now().UTC()
+	t := Ticket{
+		TgUserId: sender.ID,
+		Username: sender.Username,
+		Name:     strings.TrimSpace(sender.FirstName + " " + sender.LastName),
+		ChatId:   tgCtx.Chat().ID,
+		Text:     args[len(args)-1],
+		Status:   StatusOpen,
+		Created:  now,
+		Updated:  now,
+	}
+	if t.Text == "" {
+		t.Text = msg.Caption
+	}
+	id := sh.newTicketId(now)
+	bot := tgCtx.Bot()
+	var header *telebot.Message
+	header, err = bot.Send(telebot.ChatID(sh.SupportChatId), t.render(id), telebot.ModeHTML)
+	if err == nil {
+		t.HeaderMsgId = header.ID
+		err = sh.Tickets.Set(id, t)
+	}
+	if err == nil {
+		err = sh.Messages.Set(msgKey(sh.SupportChatId, header.ID), id)
+	}
+	if err == nil && msg.Media() != nil {
+		err = sh.copyToSupport(bot, id, t, msg)
+	}
+	if err == nil {
+		_, err = service.DonationMessage(tgCtx, fmt.Sprintf("Support request #%s submitted and will be processed as soon as possible.", id))
+	}
+	return
+}
+
+// Relay routes the replies to the ticket messages: the support chat reply is relayed to the user as the answer, the
+// user reply to the answer is added to the ticket. Other messages in the support chat are ignored, the rest are
+// passed to the next handler.
+func (sh Handler) Relay(next telebot.HandlerFunc) telebot.HandlerFunc {
+	return func(tgCtx telebot.Context) (err error) {
+		msg := tgCtx.Message()
+		chatId := tgCtx.Chat().ID
+		var id string
+		var found bool
+		if msg.IsReply() {
+			id, found = sh.Messages.Get(msgKey(chatId, msg.ReplyTo.ID))
+		}
+		var t Ticket
+		if found {
+			t, found = sh.Tickets.Get(id)
+		}
+		switch {
+		case found && chatId == sh.SupportChatId:
+			err = sh.answer(tgCtx, id, t)
+		case found && chatId == t.ChatId:
+			err = sh.followUp(tgCtx, id, t)
+		case chatId == sh.SupportChatId:
+		default:
+			err = next(tgCtx)
+		}
+		return
+	}
+}
+
+// ListHandlerFunc handles the "/tickets" command in the support chat listing the tickets not closed yet.
+func (sh Handler) ListHandlerFunc(tgCtx telebot.Context) (err error) {
+	if tgCtx.Chat().ID != sh.SupportChatId {
+		err = errors.New("the command is available in the support chat only")
+		return
+	}
+	type ticketById struct {
+		id string
+		t  Ticket
+	}
+	var tickets []ticketById
+	sh.Tickets.Each(func(id string, t Ticket) bool {
+		if t.Status != StatusClosed {
+			tickets = append(tickets, ticketById{id, t})
+		}
+		return true
+	})
+	sort.Slice(tickets, func(i, j int) bool {
+		return tickets[i].t.Updated.Before(tickets[j].t.Updated)
 	})
-	err = tgCtxSupport.Send(fmt.Sprintf("Support request from @%s:\n%s", tgCtx.Sender().Username, args[len(args)-1]))
+	var txt strings.Builder
+	txt.WriteString(fmt.Sprintf("<b>Tickets not closed</b>: %d\n", len(tickets)))
+	for i, tbi := range tickets {
+		if i == ticketsCountMax {
+			txt.WriteString("...\n")
+			break
+		}
+		txt.WriteString(fmt.Sprintf(
+			"#%s %s, %s, updated %s\n",
+			tbi.id, tbi.t.Status, html.EscapeString(tbi.t.from()), tbi.t.Updated.Format(time.DateTime),
+		))
+	}
+	err = tgCtx.Send(txt.String(), telebot.ModeHTML)
+	return
+}
+
+// CloseHandlerFunc handles the "/ticket_close <id>" command in the support chat.
+func (sh Handler) CloseHandlerFunc(tgCtx telebot.Context) (err error) {
+	if tgCtx.Chat().ID != sh.SupportChatId {
+		err = errors.New("the command is available in the support chat only")
+		return
+	}
+	args := tgCtx.Args()
+	if len(args) != 1 {
+		err = fmt.Errorf("%w, expected: /ticket_close <id>", errTicket)
+		return
+	}
+	id := strings.TrimPrefix(args[0], "#")
+	t, found := sh.Tickets.Get(id)
+	switch {
+	case !found:
+		err = fmt.Errorf("%w: not found: %s", errTicket, id)
+	case t.Status == StatusClosed:
+		err = fmt.Errorf("%w: already closed: %s", errTicket, id)
+	default:
+		bot := tgCtx.Bot()
+		err = sh.setStatus(bot, id, t, StatusClosed)
+		if err == nil {
+			_, err = bot.Send(telebot.ChatID(t.ChatId), fmt.Sprintf("Your support request #%s has been closed.", id))
+		}
+	}
+	return
+}
+
+// answer relays the support chat reply to the user.
+func (sh Handler) answer(tgCtx telebot.Context, id string, t Ticket) (err error) {
+	bot := tgCtx.Bot()
+	var header, answer *telebot.Message
+	header, err = bot.Send(
+		telebot.ChatID(t.ChatId),
+		fmt.Sprintf("Support answer to your request #%s, reply to continue:", id),
+	)
+	if err == nil {
+		answer, err = bot.Copy(telebot.ChatID(t.ChatId), tgCtx.Message())
+	}
+	if err == nil {
+		err = errors.Join(
+			sh.Messages.Set(msgKey(t.ChatId, header.ID), id),
+			sh.Messages.Set(msgKey(t.ChatId, answer.ID), id),
+			sh.Messages.Set(msgKey(sh.SupportChatId, tgCtx.Message().ID), id),
+		)
+	}
+	if err == nil {
+		err = sh.setStatus(bot, id, t, StatusAnswered)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to relay the answer to the ticket #%s: %w", id, err)
+	}
+	return
+}
+
+// followUp adds the user reply to the ticket and reopens it.
+func (sh Handler) followUp(tgCtx telebot.Context, id string, t Ticket) (err error) {
+	bot := tgCtx.Bot()
+	err = sh.copyToSupport(bot, id, t, tgCtx.Message())
+	if err == nil {
+		err = sh.setStatus(bot, id, t, StatusOpen)
+	}
 	if err == nil {
-		_, err = service.DonationMessage(tgCtx, "Support request submitted and will be processed as soon as possible.")
+		err = tgCtx.Send(fmt.Sprintf("Added to the support request #%s.", id))
 	}
 	return
 }
+
+// copyToSupport copies the user message to the support chat as a reply to the ticket header message.
+func (sh Handler) copyToSupport(bot *telebot.Bot, id string, t Ticket, msg *telebot.Message) (err error) {
+	var cp *telebot.Message
+	cp, err = bot.Copy(
+		telebot.ChatID(sh.SupportChatId),
+		msg,
+		&telebot.SendOptions{
+			ReplyTo:           &telebot.Message{ID: t.HeaderMsgId},
+			AllowWithoutReply: true,
+		},
+	)
+	if err == nil {
+		err = sh.Messages.Set(msgKey(sh.SupportChatId, cp.ID), id)
+	}
+	return
+}
+
+// setStatus updates the ticket and its header message in the support chat.
+func (sh Handler) setStatus(bot *telebot.Bot, id string, t Ticket, status Status) (err error) {
+	statusPrev := t.Status
+	t.Status = status
+	t.Updated = time.Now().UTC()
+	err = sh.Tickets.Set(id, t)
+	if err == nil && statusPrev != status {
+		header := telebot.StoredMessage{
+			MessageID: strconv.Itoa(t.HeaderMsgId),
+			ChatID:    sh.SupportChatId,
+		}
+		_, errEdit := bot.Edit(header, t.render(id), telebot.ModeHTML)
+		if errEdit != nil {
+			sh.Log.Warn(fmt.Sprintf("Failed to update the ticket #%s header status: %s", id, errEdit))
+		}
+	}
+	return
+}
+
+// newTicketId returns the short unique ticket id derived from the current time.
+func (sh Handler) newTicketId(now time.Time) (id string) {
+	for n := now.UnixMilli(); ; n++ {
+		id = strings.ToUpper(strconv.FormatInt(n, 36))
+		if _, found := sh.Tickets.Get(id); !found {
+			break
+		}
+	}
+	return
+}
+
+func msgKey(chatId int64, msgId int) string {
+	return fmt.Sprintf("%d %d", chatId, msgId)
+}
@@ -0,0 +1,86 @@
+package support
+
+import (
+	"github.com/awakari/bot-telegram/service/storage"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/telebot.v3"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTicket_From(t *testing.T) {
+	assert.Equal(t, "John Doe @johndoe", Ticket{TgUserId: 1, Username: "johndoe", Name: "John Doe"}.from())
+	assert.Equal(t, "John Doe", Ticket{TgUserId: 1, Name: "John Doe"}.from())
+	assert.Equal(t, "12345", Ticket{TgUserId: 12345}.from())
+}
+
+func TestHandler_NewTicketId(t *testing.T) {
+	tickets, err := storage.NewFile[Ticket](filepath.Join(t.TempDir(), "tickets.json"))
+	assert.Nil(t, err)
+	sh := Handler{
+		Tickets: tickets,
+	}
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	id0 := sh.newTicketId(now)
+	assert.Nil(t, tickets.Set(id0, Ticket{}))
+	id1 := sh.newTicketId(now)
+	assert.NotEqual(t, id0, id1)
+	assert.LessOrEqual(t, len(id1), 9)
+}
+
+func TestHandler_Relay(t *testing.T) {
+	b, err := telebot.NewBot(telebot.Settings{
+		Offline: true,
+	})
+	assert.Nil(t, err)
+	dir := t.TempDir()
+	tickets, err := storage.NewFile[Ticket](filepath.Join(dir, "tickets.json"))
+	assert.Nil(t, err)
+	msgs, err := storage.NewFile[string](filepath.Join(dir, "messages.json"))
+	assert.Nil(t, err)
+	sh := Handler{
+		SupportChatId: -100,
+		Tickets:       tickets,
+		Messages:      msgs,
+	}
+	cases := map[string]struct {
+		chatId  int64
+		replyTo *telebot.Message
+		next    bool
+	}{
+		"user message": {
+			chatId: 42,
+			next:   true,
+		},
+		"user reply to another message": {
+			chatId:  42,
+			replyTo: &telebot.Message{ID: 1},
+			next:    true,
+		},
+		"support chat message": {
+			chatId: -100,
+		},
+		"support chat reply to another message": {
+			chatId:  -100,
+			replyTo: &telebot.Message{ID: 1},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			var next bool
+			f := sh.Relay(func(tgCtx telebot.Context) error {
+				next = true
+				return nil
+			})
+			err = f(b.NewContext(telebot.Update{
+				Message: &telebot.Message{
+					Chat:    &telebot.Chat{ID: c.chatId},
+					ReplyTo: c.replyTo,
+				},
+			}))
+			assert.Nil(t, err)
+			assert.Equal(t, c.next, next)
+		})
+	}
+}
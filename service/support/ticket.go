@@ -0,0 +1,62 @@
+package support
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusOpen     Status = "open"
+	StatusAnswered Status = "answered"
+	StatusClosed   Status = "closed"
+)
+
+// Ticket is the support request with the conversation happening in the support chat.
+type Ticket struct {
+	TgUserId int64  `json:"tgUserId"`
+	Username string `json:"username,omitempty"`
+	Name     string `json:"name,omitempty"`
+
+	// ChatId is the chat where the request is submitted and the answers are relayed to.
+	ChatId int64 `json:"chatId"`
+
+	// Text is the initial request text.
+	Text string `json:"text"`
+
+	Status  Status    `json:"status"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+
+	// HeaderMsgId is the support chat message describing the ticket, all other ticket messages reply to it.
+	HeaderMsgId int `json:"headerMsgId"`
+}
+
+// from describes the user, who may have no username.
+func (t Ticket) from() (s string) {
+	s = t.Name
+	if t.Username != "" {
+		s += " @" + t.Username
+	}
+	if s == "" {
+		s = strconv.FormatInt(t.TgUserId, 10)
+	}
+	return
+}
+
+// render returns the header message text for the support chat.
+func (t Ticket) render(id string) string {
+	return fmt.Sprintf(
+		"<b>Support request #%s</b> [%s]\n"+
+			"From: <a href=\"tg://user?id=%d\">%s</a>, user id: <code>%d</code>, chat id: <code>%d</code>\n"+
+			"Reply to this message to answer, /ticket_close <code>%s</code> to close.\n\n"+
+			"%s",
+		id, t.Status,
+		t.TgUserId, html.EscapeString(t.from()), t.TgUserId, t.ChatId,
+		id,
+		html.EscapeString(t.Text),
+	)
+}
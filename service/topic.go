@@ -0,0 +1,96 @@
+package service
+
+import (
+	"errors"
+	"gopkg.in/telebot.v3"
+)
+
+type topicContext struct {
+	telebot.Context
+	threadId int
+}
+
+// ThreadId returns the forum topic id of the incoming message, 0 when the message is not sent to a topic.
+func ThreadId(tgCtx telebot.Context) (threadId int) {
+	msg := tgCtx.Message()
+	if msg != nil && msg.TopicMessage {
+		threadId = msg.ThreadID
+	}
+	return
+}
+
+// TopicContext returns the context that sends everything to the specified forum topic.
+func TopicContext(tgCtx telebot.Context, threadId int) telebot.Context {
+	if threadId == 0 {
+		return tgCtx
+	}
+	return topicContext{
+		Context:  tgCtx,
+		threadId: threadId,
+	}
+}
+
+// TopicHandlerFunc makes the bot to respond in the same forum topic where the incoming message is from.
+func TopicHandlerFunc(next telebot.HandlerFunc) telebot.HandlerFunc {
+	return func(tgCtx telebot.Context) error {
+		return next(TopicContext(tgCtx, ThreadId(tgCtx)))
+	}
+}
+
+func (tc topicContext) Send(what any, opts ...any) error {
+	return tc.Context.Send(what, tc.threadOpts(opts)...)
+}
+
+func (tc topicContext) SendAlbum(a telebot.Album, opts ...any) error {
+	return tc.Context.SendAlbum(a, tc.threadOpts(opts)...)
+}
+
+func (tc topicContext) Reply(what any, opts ...any) error {
+	return tc.Context.Reply(what, tc.threadOpts(opts)...)
+}
+
+func (tc topicContext) Forward(msg telebot.Editable, opts ...any) error {
+	return tc.Context.Forward(msg, tc.threadOpts(opts)...)
+}
+
+func (tc topicContext) EditOrSend(what any, opts ...any) (err error) {
+	err = tc.Edit(what, opts...)
+	if errors.Is(err, telebot.ErrBadContext) {
+		err = tc.Send(what, opts...)
+	}
+	return
+}
+
+func (tc topicContext) EditOrReply(what any, opts ...any) (err error) {
+	err = tc.Edit(what, opts...)
+	if errors.Is(err, telebot.ErrBadContext) {
+		err = tc.Reply(what, opts...)
+	}
+	return
+}
+
+func (tc topicContext) Notify(action telebot.ChatAction) error {
+	return tc.Bot().Notify(tc.Recipient(), action, tc.threadId)
+}
+
+// threadOpts adds the thread id to the send options. A *telebot.SendOptions replaces all the options preceding it, so
+// the thread id is merged into the one the caller passed, otherwise the own send options go first.
+func (tc topicContext) threadOpts(opts []any) (out []any) {
+	out = make([]any, 0, len(opts)+1)
+	var merged bool
+	for _, opt := range opts {
+		if so, ok := opt.(*telebot.SendOptions); ok && so != nil {
+			soThread := *so
+			if soThread.ThreadID == 0 {
+				soThread.ThreadID = tc.threadId
+			}
+			opt = &soThread
+			merged = true
+		}
+		out = append(out, opt)
+	}
+	if !merged {
+		out = append([]any{&telebot.SendOptions{ThreadID: tc.threadId}}, out...)
+	}
+	return
+}
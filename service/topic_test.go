@@ -0,0 +1,93 @@
+package service
+
+import (
+	"github.com/bytedance/sonic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/telebot.v3"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTopicContext(t *testing.T) {
+	var reqs []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := map[string]any{}
+		_ = sonic.ConfigDefault.NewDecoder(r.Body).Decode(&req)
+		reqs = append(reqs, req)
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":1,"chat":{"id":-1001}}}`))
+	}))
+	defer srv.Close()
+	b, err := telebot.NewBot(telebot.Settings{
+		URL:     srv.URL,
+		Offline: true,
+	})
+	require.Nil(t, err)
+	tgCtx := TopicContext(b.NewContext(telebot.Update{
+		Message: &telebot.Message{
+			ID:   2,
+			Chat: &telebot.Chat{ID: -1001},
+		},
+	}), 42)
+	cases := map[string]struct {
+		send      func() error
+		parseMode any
+		markup    bool
+	}{
+		"send": {
+			send: func() error {
+				return tgCtx.Send("text")
+			},
+		},
+		"send with parse mode": {
+			send: func() error {
+				return tgCtx.Send("text", telebot.ModeHTML)
+			},
+			parseMode: "HTML",
+		},
+		"send with own options": {
+			send: func() error {
+				return tgCtx.Send("text", &telebot.SendOptions{
+					ParseMode:   telebot.ModeHTML,
+					ReplyMarkup: &telebot.ReplyMarkup{ForceReply: true},
+				})
+			},
+			parseMode: "HTML",
+			markup:    true,
+		},
+		"send with markup": {
+			send: func() error {
+				return tgCtx.Send("text", &telebot.ReplyMarkup{ForceReply: true})
+			},
+			markup: true,
+		},
+		"reply": {
+			send: func() error {
+				return tgCtx.Reply("text")
+			},
+		},
+		"edit or send": {
+			send: func() error {
+				return tgCtx.EditOrSend("text", telebot.ModeHTML)
+			},
+			parseMode: "HTML",
+		},
+		"edit or reply": {
+			send: func() error {
+				return tgCtx.EditOrReply("text")
+			},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			reqs = nil
+			assert.Nil(t, c.send())
+			require.Len(t, reqs, 1)
+			assert.Equal(t, "42", reqs[0]["message_thread_id"])
+			assert.Equal(t, c.parseMode, reqs[0]["parse_mode"])
+			_, markup := reqs[0]["reply_markup"]
+			assert.Equal(t, c.markup, markup)
+		})
+	}
+}
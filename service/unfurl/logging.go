@@ -0,0 +1,30 @@
+package unfurl
+
+import (
+	"context"
+	"fmt"
+	"github.com/awakari/bot-telegram/util"
+	"log/slog"
+)
+
+type logging struct {
+	svc Service
+	log *slog.Logger
+}
+
+func NewLogging(svc Service, log *slog.Logger) Service {
+	return logging{
+		svc: svc,
+		log: log,
+	}
+}
+
+func (l logging) Unfurl(ctx context.Context, addr string) (p Preview, err error) {
+	p, err = l.svc.Unfurl(ctx, addr)
+	ll := util.LogLevel(err)
+	if err != nil {
+		ll = slog.LevelWarn // the link may be just unavailable, publishing proceeds anyway
+	}
+	l.log.Log(ctx, ll, fmt.Sprintf("unfurl.Unfurl(%s): %+v, err=%s", addr, p, err))
+	return
+}
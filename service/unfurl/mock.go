@@ -0,0 +1,26 @@
+package unfurl
+
+import (
+	"context"
+	"fmt"
+)
+
+type mock struct {
+	previews map[string]Preview
+}
+
+// NewMock returns the stub unfurling the given links, unknown links fail.
+func NewMock(previews map[string]Preview) Service {
+	return mock{
+		previews: previews,
+	}
+}
+
+func (m mock) Unfurl(ctx context.Context, addr string) (p Preview, err error) {
+	var found bool
+	p, found = m.previews[addr]
+	if !found {
+		err = fmt.Errorf("%w %s", ErrUnfurl, addr)
+	}
+	return
+}
@@ -0,0 +1,160 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/net/html"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Preview is the linked page summary taken from the OpenGraph meta tags, falling back to the standard HTML ones.
+type Preview struct {
+	Url         string
+	Title       string
+	Description string
+	Image       string
+}
+
+type Service interface {
+	Unfurl(ctx context.Context, addr string) (p Preview, err error)
+}
+
+type service struct {
+	clientHttp *http.Client
+	sizeMax    int64
+}
+
+var ErrUnfurl = errors.New("failed to unfurl the link")
+var errAddrNotPublic = errors.New("not a public address")
+
+func NewService(clientHttp *http.Client, sizeMax int64) Service {
+	return service{
+		clientHttp: clientHttp,
+		sizeMax:    sizeMax,
+	}
+}
+
+// NewClientHttp returns the HTTP client refusing to connect to the loopback, private and link-local addresses, so the
+// links posted by users can not be used to reach the internal services.
+func NewClientHttp(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) (err error) {
+			var host string
+			host, _, err = net.SplitHostPort(address)
+			ip := net.ParseIP(host)
+			if err == nil && (ip == nil || !ip.IsGlobalUnicast() || ip.IsPrivate()) {
+				err = fmt.Errorf("%w: %s", errAddrNotPublic, address)
+			}
+			return
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+		},
+	}
+}
+
+func (svc service) Unfurl(ctx context.Context, addr string) (p Preview, err error) {
+
+	var req *http.Request
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, addr, nil)
+
+	var resp *http.Response
+	if err == nil {
+		req.Header.Add("Accept", "text/html")
+		resp, err = svc.clientHttp.Do(req)
+	}
+
+	if err == nil {
+		defer resp.Body.Close()
+		switch {
+		case resp.StatusCode != http.StatusOK:
+			err = fmt.Errorf("response status: %d", resp.StatusCode)
+		case !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html"):
+			err = fmt.Errorf("unexpected content type: %s", resp.Header.Get("Content-Type"))
+		}
+	}
+
+	if err == nil {
+		p = parse(io.LimitReader(resp.Body, svc.sizeMax))
+		if p.Url == "" {
+			p.Url = resp.Request.URL.String() // after the redirects
+		}
+		if p.Title == "" && p.Description == "" {
+			err = errors.New("no title and description found")
+		}
+	}
+
+	if err != nil {
+		err = fmt.Errorf("%w %s: %s", ErrUnfurl, addr, err)
+	}
+	return
+}
+
+func parse(r io.Reader) (p Preview) {
+	var title, descr string
+	z := html.NewTokenizer(r)
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			// EOF or the size limit reached
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			switch tok.Data {
+			case "meta":
+				var k, v string
+				for _, a := range tok.Attr {
+					switch a.Key {
+					case "property", "name":
+						k = strings.ToLower(a.Val)
+					case "content":
+						v = strings.TrimSpace(a.Val)
+					}
+				}
+				switch k {
+				case "og:title":
+					p.Title = v
+				case "og:description":
+					p.Description = v
+				case "og:image":
+					p.Image = v
+				case "og:url":
+					p.Url = v
+				case "description":
+					descr = v
+				}
+			case "title":
+				if z.Next() == html.TextToken {
+					title = strings.TrimSpace(string(z.Text()))
+				}
+			case "body":
+				tt = html.ErrorToken // no meta tags expected after the head
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "head" {
+				tt = html.ErrorToken
+			}
+		}
+		if tt == html.ErrorToken {
+			break
+		}
+	}
+	if p.Title == "" {
+		p.Title = title
+	}
+	if p.Description == "" {
+		p.Description = descr
+	}
+	return
+}
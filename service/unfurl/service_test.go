@@ -0,0 +1,93 @@
+package unfurl
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestService_Unfurl(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/og":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte(`<!DOCTYPE html><html><head>
+<title>Fallback title</title>
+<meta property="og:title" content=" Article title "/>
+<meta property="og:description" content="Article description">
+<meta property="og:image" content="https://example.com/img.png">
+<meta property="og:url" content="https://example.com/article">
+</head><body><meta property="og:title" content="ignored"></body></html>`))
+		case "/plain":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(`<html><head><title>Page title</title><meta name="description" content="Page description"></head></html>`))
+		case "/redirect":
+			http.Redirect(w, r, "/plain", http.StatusFound)
+		case "/empty":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(`<html><head></head><body>foo</body></html>`))
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	svc := NewService(srv.Client(), 1<<20)
+	cases := map[string]struct {
+		path string
+		p    Preview
+		err  error
+	}{
+		"open graph": {
+			path: "/og",
+			p: Preview{
+				Url:         "https://example.com/article",
+				Title:       "Article title",
+				Description: "Article description",
+				Image:       "https://example.com/img.png",
+			},
+		},
+		"fallback after redirect": {
+			path: "/redirect",
+			p: Preview{
+				Url:         srv.URL + "/plain",
+				Title:       "Page title",
+				Description: "Page description",
+			},
+		},
+		"no meta": {
+			path: "/empty",
+			err:  ErrUnfurl,
+		},
+		"not html": {
+			path: "/json",
+			err:  ErrUnfurl,
+		},
+		"not found": {
+			path: "/missing",
+			err:  ErrUnfurl,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			p, err := svc.Unfurl(context.TODO(), srv.URL+c.path)
+			assert.ErrorIs(t, err, c.err)
+			if c.err == nil {
+				assert.Equal(t, c.p, p)
+			}
+		})
+	}
+}
+
+func TestNewClientHttp(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	_, err := NewService(NewClientHttp(time.Second), 1<<20).Unfurl(context.TODO(), srv.URL)
+	assert.ErrorIs(t, err, ErrUnfurl)
+	assert.ErrorContains(t, err, errAddrNotPublic.Error())
+}
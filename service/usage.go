@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/awakari/bot-telegram/api/http/interests"
+	"github.com/awakari/bot-telegram/api/http/pub"
+	"github.com/awakari/bot-telegram/api/http/subscriptions"
+	"github.com/awakari/bot-telegram/model"
+	"github.com/awakari/bot-telegram/model/interest"
+	"github.com/awakari/bot-telegram/model/interest/condition"
+	"github.com/awakari/bot-telegram/model/usage"
+	"github.com/awakari/bot-telegram/service/limits"
+	"github.com/awakari/bot-telegram/service/storage"
+	"github.com/awakari/bot-telegram/util"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"google.golang.org/grpc/metadata"
+	"gopkg.in/telebot.v3"
+	"sync"
+	"time"
+)
+
+var usageSubjects = []usage.Subject{
+	usage.SubjectPublishHourly,
+	usage.SubjectPublishDaily,
+	usage.SubjectInterests,
+	usage.SubjectInterestsPublic,
+	usage.SubjectSubscriptions,
+}
+
+// usageWarnRatio is the share of the limit after which the user is warned before publishing.
+const usageWarnRatio = 0.8
+
+const usageCountPageLimit = 100
+
+// PublishCount is the count of the messages published by the user via the bot in the current hour and day (UTC).
+type PublishCount struct {
+	Hour   time.Time `json:"hour"`
+	Hourly int64     `json:"hourly"`
+	Day    time.Time `json:"day"`
+	Daily  int64     `json:"daily"`
+}
+
+// Count returns the count of the publishing subject at the specified time.
+func (pc PublishCount) Count(subj usage.Subject, now time.Time) (count int64) {
+	switch {
+	case subj == usage.SubjectPublishHourly && pc.Hour.Equal(now.Truncate(time.Hour)):
+		count = pc.Hourly
+	case subj == usage.SubjectPublishDaily && pc.Day.Equal(now.Truncate(24*time.Hour)):
+		count = pc.Daily
+	}
+	return
+}
+
+func (pc PublishCount) add(n int64, now time.Time) PublishCount {
+	pc.Hourly = pc.Count(usage.SubjectPublishHourly, now) + n
+	pc.Hour = now.Truncate(time.Hour)
+	pc.Daily = pc.Count(usage.SubjectPublishDaily, now) + n
+	pc.Day = now.Truncate(24 * time.Hour)
+	return pc
+}
+
+type countingPub struct {
+	pub.Service
+	counts storage.Storage[PublishCount]
+	lock   *sync.Mutex
+}
+
+// CountPublished returns the publishing service counting the acknowledged events by the user id.
+func CountPublished(svc pub.Service, counts storage.Storage[PublishCount]) pub.Service {
+	return countingPub{
+		Service: svc,
+		counts:  counts,
+		lock:    &sync.Mutex{},
+	}
+}
+
+func (cp countingPub) Publish(ctx context.Context, evt *pb.CloudEvent, groupId, userId string) (err error) {
+	err = cp.Service.Publish(ctx, evt, groupId, userId)
+	if err == nil {
+		cp.add(userId, 1)
+	}
+	return
+}
+
+func (cp countingPub) PublishBatch(ctx context.Context, evts []*pb.CloudEvent, groupId, userId string) (ackCount uint32, err error) {
+	ackCount, err = cp.Service.PublishBatch(ctx, evts, groupId, userId)
+	if ackCount > 0 {
+		cp.add(userId, int64(ackCount))
+	}
+	return
+}
+
+func (cp countingPub) add(userId string, n int64) {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+	pc, _ := cp.counts.Get(userId)
+	_ = cp.counts.Set(userId, pc.add(n, time.Now().UTC())) // the best effort, the count is informational only
+}
+
+// Usage counts the current usage from what the bot can see: the own interests, the subscriptions delivered to
+// Telegram and the messages published via the bot. The usage via other clients is not counted.
+type Usage struct {
+	SvcLimits       limits.Service
+	SvcInterests    interests.Service
+	SvcSubs         subscriptions.Service
+	Published       storage.Storage[PublishCount]
+	UrlCallbackBase string
+	GroupId         string
+}
+
+// UsageHandlerFunc handles the "/usage" command showing the current usage and limits.
+func (u Usage) UsageHandlerFunc(tgCtx telebot.Context) (err error) {
+	ctx := context.TODO()
+	userId := util.SenderToUserId(tgCtx)
+	now := time.Now().UTC()
+	txt := "<b>Usage</b>\n"
+	for _, subj := range usageSubjects {
+		l, errLim := u.SvcLimits.Get(ctx, u.GroupId, userId, subj)
+		var count int64
+		var errCount error
+		if errLim == nil {
+			count, errCount = u.Count(ctx, userId, subj, now)
+		}
+		switch {
+		case errLim != nil:
+			txt += fmt.Sprintf("%s: unavailable\n", subj.Description())
+		default:
+			switch errCount {
+			case nil:
+				txt += fmt.Sprintf("%s: %d of %d", subj.Description(), count, l.Count)
+			default:
+				txt += fmt.Sprintf("%s: ? of %d", subj.Description(), l.Count)
+			}
+			if l.UserId != "" && !l.Expires.IsZero() {
+				txt += fmt.Sprintf(", the limit expires on %s", l.Expires.UTC().Format(time.DateOnly))
+			}
+			txt += "\n"
+		}
+	}
+	txt += "<i>Only the messages published via this bot are counted.</i>"
+	err = tgCtx.Send(txt, telebot.ModeHTML)
+	return
+}
+
+// Count returns the current usage count of the subject by the user.
+func (u Usage) Count(ctx context.Context, userId string, subj usage.Subject, now time.Time) (count int64, err error) {
+	switch subj {
+	case usage.SubjectPublishHourly, usage.SubjectPublishDaily:
+		pc, _ := u.Published.Get(userId)
+		count = pc.Count(subj, now)
+	case usage.SubjectInterests, usage.SubjectInterestsPublic:
+		count, err = u.countInterests(ctx, userId, subj == usage.SubjectInterestsPublic)
+	case usage.SubjectSubscriptions:
+		count, err = u.countSubscriptions(ctx, userId)
+	default:
+		err = fmt.Errorf("unsupported usage subject: %s", subj.Description())
+	}
+	return
+}
+
+func (u Usage) countInterests(ctx context.Context, userId string, publicOnly bool) (count int64, err error) {
+	q := interest.Query{
+		Limit: usageCountPageLimit,
+	}
+	var cursor condition.Cursor
+	for {
+		page, errPage := u.SvcInterests.Search(ctx, u.GroupId, userId, q, cursor)
+		if errors.Is(errPage, interests.ErrNotFound) {
+			break
+		}
+		if errPage != nil {
+			err = errPage
+			break
+		}
+		for _, i := range page {
+			if !publicOnly || i.Public {
+				count++
+			}
+		}
+		if len(page) < usageCountPageLimit {
+			break
+		}
+		cursor.Id = page[len(page)-1].Id
+	}
+	return
+}
+
+func (u Usage) countSubscriptions(ctx context.Context, userId string) (count int64, err error) {
+	groupIdCtx := metadata.AppendToOutgoingContext(ctx, model.KeyGroupId, u.GroupId)
+	var cursor string
+	for {
+		page, errPage := u.SvcSubs.InterestsByUrl(groupIdCtx, u.GroupId, userId, usageCountPageLimit, u.UrlCallbackBase+"/", cursor)
+		if errors.Is(errPage, subscriptions.ErrNotFound) {
+			break
+		}
+		if errPage != nil {
+			err = errPage
+			break
+		}
+		count += int64(len(page))
+		if len(page) < usageCountPageLimit {
+			break
+		}
+		cursor = page[len(page)-1]
+	}
+	return
+}
+
+// Warning returns the warning when the usage of any of the subjects is close to the limit, otherwise empty string.
+// The subjects failed to check are skipped: the final check is done by the service anyway.
+func (u Usage) Warning(ctx context.Context, userId string, now time.Time, subjs ...usage.Subject) (warn string) {
+	for _, subj := range subjs {
+		l, err := u.SvcLimits.Get(ctx, u.GroupId, userId, subj)
+		var count int64
+		if err == nil {
+			count, err = u.Count(ctx, userId, subj, now)
+		}
+		if err == nil && l.Count > 0 && float64(count+1) >= usageWarnRatio*float64(l.Count) {
+			warn = fmt.Sprintf("⚠ %s usage is close to the limit: %d of %d. Use /usage to see the details.", subj.Description(), count, l.Count)
+			break
+		}
+	}
+	return
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"github.com/awakari/bot-telegram/model/usage"
+	"github.com/awakari/bot-telegram/service/limits"
+	"github.com/awakari/bot-telegram/service/storage"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPublishCount(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 30, 0, 0, time.UTC)
+	var pc PublishCount
+	pc = pc.add(1, now)
+	pc = pc.add(2, now.Add(10*time.Minute))
+	assert.Equal(t, int64(3), pc.Count(usage.SubjectPublishHourly, now))
+	assert.Equal(t, int64(3), pc.Count(usage.SubjectPublishDaily, now))
+	// next hour
+	now = now.Add(time.Hour)
+	assert.Equal(t, int64(0), pc.Count(usage.SubjectPublishHourly, now))
+	assert.Equal(t, int64(3), pc.Count(usage.SubjectPublishDaily, now))
+	pc = pc.add(1, now)
+	assert.Equal(t, int64(1), pc.Count(usage.SubjectPublishHourly, now))
+	assert.Equal(t, int64(4), pc.Count(usage.SubjectPublishDaily, now))
+	// next day
+	now = now.AddDate(0, 0, 1)
+	assert.Equal(t, int64(0), pc.Count(usage.SubjectPublishHourly, now))
+	assert.Equal(t, int64(0), pc.Count(usage.SubjectPublishDaily, now))
+}
+
+func TestUsage_Warning(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 30, 0, 0, time.UTC)
+	lims := map[usage.Subject]usage.Limit{
+		usage.SubjectPublishHourly: {Count: 10},
+		usage.SubjectPublishDaily:  {Count: 100},
+	}
+	cases := map[string]struct {
+		pc   PublishCount
+		warn string
+	}{
+		"nothing published": {},
+		"far from limits": {
+			pc: PublishCount{}.add(5, now),
+		},
+		"close to hourly limit": {
+			pc:   PublishCount{}.add(8, now),
+			warn: "⚠ Publishing (hourly) usage is close to the limit: 8 of 10. Use /usage to see the details.",
+		},
+		"close to daily limit": {
+			pc:   PublishCount{}.add(85, now.Add(-2*time.Hour)).add(1, now),
+			warn: "⚠ Publishing (daily) usage is close to the limit: 86 of 100. Use /usage to see the details.",
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			published, err := storage.NewFile[PublishCount](filepath.Join(t.TempDir(), "publish-counts.json"))
+			assert.Nil(t, err)
+			assert.Nil(t, published.Set("user0", c.pc))
+			u := Usage{
+				SvcLimits: limits.NewMock(lims),
+				Published: published,
+			}
+			warn := u.Warning(context.TODO(), "user0", now, usage.SubjectPublishHourly, usage.SubjectPublishDaily)
+			assert.Equal(t, c.warn, warn)
+		})
+	}
+}
+
+func TestLimitReached_PublishSubject(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 30, 0, 0, time.UTC)
+	lims := map[usage.Subject]usage.Limit{
+		usage.SubjectPublishHourly: {Count: 10},
+		usage.SubjectPublishDaily:  {Count: 100},
+	}
+	cases := map[string]struct {
+		pc   PublishCount
+		subj usage.Subject
+	}{
+		"unknown": {
+			subj: usage.SubjectPublishDaily,
+		},
+		"hourly": {
+			pc:   PublishCount{}.add(10, now),
+			subj: usage.SubjectPublishHourly,
+		},
+		"daily": {
+			pc:   PublishCount{}.add(99, now.Add(-2*time.Hour)).add(1, now),
+			subj: usage.SubjectPublishDaily,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			published, err := storage.NewFile[PublishCount](filepath.Join(t.TempDir(), "publish-counts.json"))
+			assert.Nil(t, err)
+			assert.Nil(t, published.Set("user0", c.pc))
+			lr := LimitReached{
+				SvcLimits: limits.NewMock(lims),
+				Published: published,
+			}
+			assert.Equal(t, c.subj, lr.publishSubject(context.TODO(), "user0", now))
+		})
+	}
+}
@@ -3,6 +3,7 @@ package util
 import (
 	"gopkg.in/telebot.v3"
 	"strconv"
+	"strings"
 )
 
 const prefixUserId = "tg://user?id="
@@ -19,3 +20,14 @@ func TelegramToAwakariUserId(tgUserId int64) (id string) {
 	id = prefixUserId + strconv.FormatInt(tgUserId, 10)
 	return
 }
+
+// AwakariToTelegramUserId is the reverse of TelegramToAwakariUserId, returns false when the user is not a Telegram one.
+func AwakariToTelegramUserId(id string) (tgUserId int64, ok bool) {
+	idRaw, found := strings.CutPrefix(id, prefixUserId)
+	if found {
+		var err error
+		tgUserId, err = strconv.ParseInt(idRaw, 10, 64)
+		ok = err == nil
+	}
+	return
+}